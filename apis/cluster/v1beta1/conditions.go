@@ -0,0 +1,248 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TypePendingApproval indicates whether a Workspace is waiting for its plan
+// to be approved before it is applied.
+const TypePendingApproval xpv1.ConditionType = "PendingApproval"
+
+// TypeReferencesResolved indicates whether the outputs of other Workspaces
+// that a Workspace's variables reference have been resolved.
+const TypeReferencesResolved xpv1.ConditionType = "ReferencesResolved"
+
+// TypeModuleSourceAllowed indicates whether a Workspace's ProviderConfig
+// allows the source of its module.
+const TypeModuleSourceAllowed xpv1.ConditionType = "ModuleSourceAllowed"
+
+// TypeTofuVersionSupported indicates whether the provider supports the version
+// of tofu that runs a Workspace.
+const TypeTofuVersionSupported xpv1.ConditionType = "TofuVersionSupported"
+
+// TypeTofuSucceeded indicates whether the most recent tofu operation on a
+// Workspace succeeded. Its reason categorizes the failure if it didn't.
+const TypeTofuSucceeded xpv1.ConditionType = "TofuSucceeded"
+
+// TypeDrifted indicates whether any of a Workspace's resources changed
+// outside of tofu.
+const TypeDrifted xpv1.ConditionType = "Drifted"
+
+// Reasons a Workspace is or is not pending approval.
+const (
+	ReasonAwaitingApproval    xpv1.ConditionReason = "AwaitingApproval"
+	ReasonPlanApproved        xpv1.ConditionReason = "PlanApproved"
+	ReasonApprovalNotRequired xpv1.ConditionReason = "ApprovalNotRequired"
+)
+
+// Reasons a Workspace's references are or are not resolved.
+const (
+	ReasonWaitingForWorkspace xpv1.ConditionReason = "WaitingForWorkspace"
+	ReasonResolved            xpv1.ConditionReason = "Resolved"
+)
+
+// Reasons a Workspace's module source is or is not allowed.
+const (
+	ReasonModuleSourceDenied       xpv1.ConditionReason = "ModuleSourceDenied"
+	ReasonModuleSourceAllowed      xpv1.ConditionReason = "ModuleSourceAllowed"
+	ReasonModuleSourceUnrestricted xpv1.ConditionReason = "Unrestricted"
+)
+
+// Reasons a Workspace's tofu version is or is not supported.
+const (
+	ReasonTofuVersionUnsupported xpv1.ConditionReason = "UnsupportedVersion"
+	ReasonTofuVersionSupported   xpv1.ConditionReason = "SupportedVersion"
+)
+
+// Reasons the most recent tofu operation on a Workspace did or did not
+// succeed.
+const (
+	ReasonTofuSucceeded   xpv1.ConditionReason = "Succeeded"
+	ReasonConfigInvalid   xpv1.ConditionReason = "ConfigInvalid"
+	ReasonAuthFailed      xpv1.ConditionReason = "AuthFailed"
+	ReasonStateLocked     xpv1.ConditionReason = "StateLocked"
+	ReasonProviderCrashed xpv1.ConditionReason = "ProviderCrashed"
+	ReasonRateLimited     xpv1.ConditionReason = "RateLimited"
+	ReasonNetworkError    xpv1.ConditionReason = "NetworkError"
+	ReasonQuotaExceeded   xpv1.ConditionReason = "QuotaExceeded"
+	ReasonTofuFailed      xpv1.ConditionReason = "TofuFailed"
+)
+
+// Reasons a Workspace has or has not drifted.
+const (
+	ReasonDriftDetected xpv1.ConditionReason = "DriftDetected"
+	ReasonNoDrift       xpv1.ConditionReason = "NoDrift"
+)
+
+// PendingApproval returns a condition indicating that the plan with the
+// supplied hash will not be applied until it is approved.
+func PendingApproval(hash, summary string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePendingApproval,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAwaitingApproval,
+		Message:            fmt.Sprintf("Plan %s (%s) requires approval. Set the %s annotation to the plan hash to apply it.", hash, summary, AnnotationKeyApprovedPlan),
+	}
+}
+
+// PlanApproved returns a condition indicating that the plan with the supplied
+// hash was approved and applied.
+func PlanApproved(hash string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePendingApproval,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPlanApproved,
+		Message:            fmt.Sprintf("Plan %s was approved and applied.", hash),
+	}
+}
+
+// ApprovalNotRequired returns a condition indicating that a Workspace no
+// longer has a plan that requires approval.
+func ApprovalNotRequired() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePendingApproval,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonApprovalNotRequired,
+	}
+}
+
+// WaitingForWorkspace returns a condition indicating that a Workspace is
+// waiting for another Workspace it references to become ready, or to produce
+// an output.
+func WaitingForWorkspace(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeReferencesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonWaitingForWorkspace,
+		Message:            msg,
+	}
+}
+
+// ReferencesResolved returns a condition indicating that all the Workspace
+// outputs a Workspace references have been resolved.
+func ReferencesResolved() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeReferencesResolved,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonResolved,
+	}
+}
+
+// ModuleSourceDenied returns a condition indicating that a Workspace's
+// ProviderConfig does not allow the source of its module.
+func ModuleSourceDenied(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeModuleSourceAllowed,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonModuleSourceDenied,
+		Message:            msg,
+	}
+}
+
+// ModuleSourceAllowed returns a condition indicating that a Workspace's
+// ProviderConfig allows the source of its module.
+func ModuleSourceAllowed() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeModuleSourceAllowed,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonModuleSourceAllowed,
+	}
+}
+
+// ModuleSourceUnrestricted returns a condition indicating that a Workspace's
+// ProviderConfig allows modules from any source.
+func ModuleSourceUnrestricted() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeModuleSourceAllowed,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonModuleSourceUnrestricted,
+	}
+}
+
+// TofuVersionUnsupported returns a condition indicating that the provider
+// does not support the version of tofu that runs a Workspace.
+func TofuVersionUnsupported(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeTofuVersionSupported,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonTofuVersionUnsupported,
+		Message:            msg,
+	}
+}
+
+// TofuVersionSupported returns a condition indicating that the provider
+// supports the supplied version of tofu, which runs a Workspace.
+func TofuVersionSupported(version string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeTofuVersionSupported,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonTofuVersionSupported,
+		Message:            fmt.Sprintf("tofu %s", version),
+	}
+}
+
+// TofuSucceeded returns a condition indicating that the most recent tofu
+// operation on a Workspace succeeded.
+func TofuSucceeded() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeTofuSucceeded,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonTofuSucceeded,
+	}
+}
+
+// TofuFailed returns a condition indicating that the most recent tofu
+// operation on a Workspace failed for the supplied reason.
+func TofuFailed(reason xpv1.ConditionReason, msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeTofuSucceeded,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}
+}
+
+// Drifted returns a condition indicating that some of a Workspace's resources
+// changed outside of tofu.
+func Drifted(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDrifted,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDriftDetected,
+		Message:            msg,
+	}
+}
+
+// NotDrifted returns a condition indicating that none of a Workspace's
+// resources changed outside of tofu.
+func NotDrifted() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDrifted,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoDrift,
+	}
+}
@@ -37,6 +37,127 @@ type ProviderConfigSpec struct {
 	// +optional
 	// +kubebuilder:default=true
 	PluginCache *bool `json:"pluginCache,omitempty"`
+
+	// AllowedModuleSources restricts the remote and OCI modules that
+	// Workspaces using this provider config may use. A pattern is a host,
+	// a path such as github.com/example-org, or a URL prefix such as
+	// https://github.com/example-org. A pattern matches its location and
+	// every location within it, and may use glob wildcards that don't match
+	// across a '/'. Modules from any source are allowed when this is empty.
+	// Only the root module of a Workspace is checked. Modules it calls with
+	// module blocks, and modules that init arguments such as -from-module
+	// refer to, are not restricted.
+	// +optional
+	AllowedModuleSources []string `json:"allowedModuleSources,omitempty"`
+
+	// DeniedModuleSources are patterns of remote and OCI modules that
+	// Workspaces using this provider config may not use, even if they match
+	// an allowed module source.
+	// +optional
+	DeniedModuleSources []string `json:"deniedModuleSources,omitempty"`
+
+	// ForbidInlineModules forbids Workspaces using this provider config from
+	// using modules whose content is written by their author, i.e. modules
+	// with the Inline or ConfigMap source.
+	// +optional
+	ForbidInlineModules bool `json:"forbidInlineModules,omitempty"`
+
+	// GitSSH configures how git authenticates to repositories over SSH,
+	// both when getting remote modules and when tofu init gets the modules
+	// they call.
+	// +optional
+	GitSSH *GitSSHConfig `json:"gitSSH,omitempty"`
+
+	// TofuVersion is the version of tofu that runs the workspaces that use
+	// this provider config, e.g. 1.10.0, unless they specify their own. The
+	// version of tofu installed in the provider image is used by default.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.]+)?$`
+	TofuVersion string `json:"tofuVersion,omitempty"`
+
+	// StateLock configures how the workspaces that use this provider config
+	// handle locks on their tofu state.
+	// +optional
+	StateLock *StateLockPolicy `json:"stateLock,omitempty"`
+}
+
+// A StateLockPolicy configures how workspaces handle locks on their tofu
+// state.
+type StateLockPolicy struct {
+	// Timeout is how long tofu waits to acquire a state lock before it
+	// fails, e.g. 30s. Tofu fails immediately by default.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// ForceUnlockAfter is how old a state lock must be before it is presumed
+	// stale and force unlocked, e.g. 1h. It should be longer than any tofu
+	// operation takes. Only locks acquired by tofu run by this provider,
+	// including by previous revisions of it, are force unlocked. Locks are
+	// never force unlocked by default.
+	// +optional
+	ForceUnlockAfter *metav1.Duration `json:"forceUnlockAfter,omitempty"`
+}
+
+// StrictHostKeyChecking determines whether git may connect to SSH servers
+// whose host keys are unknown.
+type StrictHostKeyChecking string
+
+// Strict host key checking policies.
+const (
+	// StrictHostKeyCheckingYes only allows connections to SSH servers whose
+	// host keys are known.
+	StrictHostKeyCheckingYes StrictHostKeyChecking = "Yes"
+
+	// StrictHostKeyCheckingAcceptNew trusts the host keys of SSH servers
+	// that are unknown, and remembers them for the Workspace. Connections to
+	// known servers whose host key changed are not allowed.
+	StrictHostKeyCheckingAcceptNew StrictHostKeyChecking = "AcceptNew"
+)
+
+// GitSSHConfig configures how git authenticates to repositories over SSH.
+type GitSSHConfig struct {
+	// Keys are SSH private keys that authenticate to git servers. Each
+	// server is authenticated to using the first key whose host pattern
+	// matches it.
+	// +optional
+	Keys []GitSSHKey `json:"keys,omitempty"`
+
+	// KnownHosts is a known_hosts file with the host keys of git servers.
+	// +optional
+	KnownHosts *GitSSHCredentials `json:"knownHosts,omitempty"`
+
+	// StrictHostKeyChecking determines whether git may connect to servers
+	// whose host keys aren't in KnownHosts. Yes, the default, doesn't allow
+	// it. AcceptNew trusts unknown host keys, and remembers them for the
+	// Workspace.
+	// +optional
+	// +kubebuilder:validation:Enum=Yes;AcceptNew
+	// +kubebuilder:default=Yes
+	StrictHostKeyChecking StrictHostKeyChecking `json:"strictHostKeyChecking,omitempty"`
+}
+
+// A GitSSHKey is an SSH private key for the git servers that match a host
+// pattern.
+type GitSSHKey struct {
+	// Host is an SSH host pattern, e.g. github.com or *.example.org, that
+	// selects the servers this key authenticates to. Patterns may be negated
+	// with a '!'. The key authenticates to every server by default.
+	// +optional
+	// +kubebuilder:default="*"
+	// +kubebuilder:validation:Pattern=`^[^\s"]+$`
+	Host string `json:"host,omitempty"`
+
+	GitSSHCredentials `json:",inline"`
+}
+
+// GitSSHCredentials are the source of an SSH private key or known_hosts
+// file.
+type GitSSHCredentials struct {
+	// Source of the credentials.
+	// +kubebuilder:validation:Enum=None;Secret;Environment;Filesystem
+	Source xpv1.CredentialsSource `json:"source"`
+
+	xpv1.CommonCredentialSelectors `json:",inline"`
 }
 
 // ProviderCredentials required to authenticate.
@@ -14,10 +14,65 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
-// A Var represents a tofu configuration variable.
+// A Var represents a tofu configuration variable. A plain value is passed on
+// the command line. JSON and HCL values, and values read from a Secret,
+// ConfigMap or Workspace output, are written to generated var files, so
+// sensitive values don't appear in the process list. If more than one value
+// is set valueFrom takes precedence over hclValue, which takes precedence over
+// jsonValue, which takes precedence over value.
 type Var struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key string `json:"key"`
+
+	// ValueFrom reads the variable's value from a Secret or ConfigMap key.
+	// +optional
+	ValueFrom *VarSource `json:"valueFrom,omitempty"`
+
+	// Value of the variable. It is passed to tofu as is, so tofu parses it as
+	// an HCL expression if the variable's type is not a string.
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// JSONValue of the variable. Any JSON value is supported, including
+	// lists, objects, numbers and booleans.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	JSONValue *runtime.RawExtension `json:"jsonValue,omitempty"`
+
+	// HCLValue of the variable, as an HCL expression. For example
+	// ["a", "b"] or { size = 3 }.
+	// +optional
+	HCLValue string `json:"hclValue,omitempty"`
+}
+
+// A VarSource specifies a Secret or ConfigMap key to read a variable's value
+// from.
+type VarSource struct {
+	// A ConfigMap key containing the variable's value.
+	// +optional
+	ConfigMapKeyReference *KeyReference `json:"configMapKeyRef,omitempty"`
+
+	// A Secret key containing the variable's value. Values read from a
+	// Secret are sensitive; they are redacted from tofu's output.
+	// +optional
+	SecretKeyReference *KeyReference `json:"secretKeyRef,omitempty"`
+
+	// An output of another Workspace containing the variable's value. The
+	// Workspace waits until the referenced Workspace is ready and has the
+	// output.
+	// +optional
+	WorkspaceOutputReference *WorkspaceOutputReference `json:"workspaceOutputRef,omitempty"`
+}
+
+// A WorkspaceOutputReference references a non-sensitive output of another
+// Workspace in the same namespace.
+type WorkspaceOutputReference struct {
+	// Name of the referenced Workspace.
+	Name string `json:"name"`
+
+	// Output of the referenced Workspace, as reported in its
+	// status.atProvider.outputs.
+	Output string `json:"output"`
 }
 
 // A VarFileSource specifies the source of a Terraform vars file.
@@ -81,32 +136,266 @@ type KeyReference struct {
 	Key string `json:"key"`
 }
 
+// An InlineFile is a file of an inline module.
+type InlineFile struct {
+	// Content of the file.
+	Content string `json:"content"`
+
+	// Format of the file. Files in JSON format must be valid JSON. The
+	// format of files named *.json defaults to JSON, and the content of
+	// files with no format is written as is.
+	// +optional
+	Format *FileFormat `json:"format,omitempty"`
+}
+
 // A ModuleSource represents the source of a Terraform module.
-// +kubebuilder:validation:Enum=Remote;Inline
+// +kubebuilder:validation:Enum=Remote;Inline;ConfigMap;OCI
 type ModuleSource string
 
 // Module sources.
 const (
-	ModuleSourceRemote ModuleSource = "Remote"
-	ModuleSourceInline ModuleSource = "Inline"
+	ModuleSourceRemote    ModuleSource = "Remote"
+	ModuleSourceInline    ModuleSource = "Inline"
+	ModuleSourceConfigMap ModuleSource = "ConfigMap"
+	ModuleSourceOCI       ModuleSource = "OCI"
+)
+
+// A ModuleFilesKind is a kind of object that may contain module files.
+// +kubebuilder:validation:Enum=ConfigMap;Secret
+type ModuleFilesKind string
+
+// Kinds of objects that may contain module files.
+const (
+	ModuleFilesKindConfigMap ModuleFilesKind = "ConfigMap"
+	ModuleFilesKindSecret    ModuleFilesKind = "Secret"
+)
+
+// A ModuleFilesReference references a ConfigMap or Secret whose keys are
+// files of a module. Keys may encode nested paths by separating directories
+// with a double underscore; the key modules__vpc__main.tf is written to
+// modules/vpc/main.tf. Keys that start or end with a double underscore, or
+// contain more than two consecutive underscores, are ambiguous and rejected.
+type ModuleFilesReference struct {
+	// Kind of the referenced object.
+	// +kubebuilder:default=ConfigMap
+	// +optional
+	Kind ModuleFilesKind `json:"kind,omitempty"`
+
+	// Name of the referenced object.
+	Name string `json:"name"`
+
+	// Path of the directory, relative to the root module, to which the
+	// referenced object's files are written. Files are written to the root
+	// module when this is omitted.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// An Import adopts an existing resource into a workspace's tofu state.
+type Import struct {
+	// Address of the resource in the workspace's configuration to import
+	// into, e.g. aws_vpc.main or module.network.aws_vpc.main["a"]. The
+	// resource must be declared by the configuration.
+	// +kubebuilder:validation:Pattern=`^(module\.[A-Za-z_][A-Za-z0-9_-]*(\[("[^"]*"|[0-9]+)\])?\.)*[A-Za-z_][A-Za-z0-9_-]*\.[A-Za-z_][A-Za-z0-9_-]*(\[("[^"]*"|[0-9]+)\])?$`
+	Address string `json:"address"`
+
+	// ID of the existing resource, in the format its provider imports it
+	// from, e.g. vpc-0123456789abcdef0.
+	// +kubebuilder:validation:MinLength=1
+	ID string `json:"id"`
+
+	// Provider configuration to import the resource with, e.g. aws.west. The
+	// resource's provider configuration is used by default.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_-]*(\.[A-Za-z_][A-Za-z0-9_-]*)?$`
+	Provider string `json:"provider,omitempty"`
+}
+
+// An ImportResult is the result of an import.
+type ImportResult string
+
+// Import results.
+const (
+	// ImportResultPending imports will be imported when the workspace is
+	// applied.
+	ImportResultPending ImportResult = "Pending"
+
+	// ImportResultImported imports are in the workspace's tofu state.
+	ImportResultImported ImportResult = "Imported"
+
+	// ImportResultFailed imports could not be planned.
+	ImportResultFailed ImportResult = "Failed"
+)
+
+// An ImportStatus reports the result of an import.
+type ImportStatus struct {
+	// Address of the resource the import adopts.
+	Address string `json:"address"`
+
+	// ID of the existing resource.
+	ID string `json:"id"`
+
+	// Result of the import.
+	Result ImportResult `json:"result"`
+
+	// Message describes why the import failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// An ApprovalPolicy determines which plans must be approved before they are
+// applied.
+// +kubebuilder:validation:Enum=Auto;RequireForDestroy;Always
+type ApprovalPolicy string
+
+// Approval policies.
+const (
+	// ApprovalPolicyAuto applies all plans without approval.
+	ApprovalPolicyAuto ApprovalPolicy = "Auto"
+
+	// ApprovalPolicyRequireForDestroy requires approval of plans that
+	// delete or replace resources.
+	ApprovalPolicyRequireForDestroy ApprovalPolicy = "RequireForDestroy"
+
+	// ApprovalPolicyAlways requires approval of all plans that make changes.
+	ApprovalPolicyAlways ApprovalPolicy = "Always"
+)
+
+// AnnotationKeyApprovedPlan is the annotation used to approve a plan. Its
+// value must be the hash of the plan, as reported by the PendingApproval
+// condition.
+const AnnotationKeyApprovedPlan = "opentofu.upbound.io/approved-plan"
+
+// A ReadinessCheckType specifies how an output is checked to determine
+// whether a Workspace is ready.
+// +kubebuilder:validation:Enum=MatchTrue;MatchValue;NonEmpty
+type ReadinessCheckType string
+
+// Readiness check types.
+const (
+	// ReadinessCheckTypeMatchTrue is ready when the output is the boolean
+	// true.
+	ReadinessCheckTypeMatchTrue ReadinessCheckType = "MatchTrue"
+
+	// ReadinessCheckTypeMatchValue is ready when the output equals the
+	// check's value.
+	ReadinessCheckTypeMatchValue ReadinessCheckType = "MatchValue"
+
+	// ReadinessCheckTypeNonEmpty is ready when the output exists and is not
+	// null, an empty string, an empty list or an empty object.
+	ReadinessCheckTypeNonEmpty ReadinessCheckType = "NonEmpty"
+)
+
+// A ReadinessCheck derives the readiness of a Workspace from one of its
+// outputs.
+type ReadinessCheck struct {
+	// OutputName is the name of the output to check.
+	OutputName string `json:"outputName"`
+
+	// Type of check to perform on the output.
+	// +kubebuilder:default=NonEmpty
+	// +optional
+	Type ReadinessCheckType `json:"type,omitempty"`
+
+	// Value the output must equal when the check's type is MatchValue.
+	// Outputs that are not strings are compared using their JSON encoding,
+	// e.g. "3" or "[\"a\",\"b\"]".
+	// +optional
+	Value string `json:"value,omitempty"`
+}
+
+// A ConnectionDetailEncoding specifies how an output is encoded when it is
+// written to the connection secret.
+// +kubebuilder:validation:Enum=Raw;Base64
+type ConnectionDetailEncoding string
+
+// Connection detail encodings.
+const (
+	// ConnectionDetailEncodingRaw writes string values as is, and values of
+	// any other type as JSON.
+	ConnectionDetailEncodingRaw ConnectionDetailEncoding = "Raw"
+
+	// ConnectionDetailEncodingBase64 writes the base64 encoding of the raw
+	// value.
+	ConnectionDetailEncodingBase64 ConnectionDetailEncoding = "Base64"
 )
 
+// A ConnectionDetail writes an output, or a value within an output, to the
+// connection secret.
+type ConnectionDetail struct {
+	// OutputName is the name of the output to write.
+	OutputName string `json:"outputName"`
+
+	// Key of the connection secret to write the value to. Defaults to the
+	// name of the output.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// FieldPath of the value to write within an object or tuple output, for
+	// example "endpoint.host" or "[0].address". The whole output is written
+	// when it is omitted.
+	// +optional
+	FieldPath string `json:"fieldPath,omitempty"`
+
+	// Encoding of the value written to the connection secret.
+	// +kubebuilder:default=Raw
+	// +optional
+	Encoding ConnectionDetailEncoding `json:"encoding,omitempty"`
+}
+
+// An OutputsConfigMapReference references a ConfigMap in the Workspace's
+// namespace that is kept in sync with the Workspace's non-sensitive outputs.
+type OutputsConfigMapReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Outputs to write to the ConfigMap. All non-sensitive outputs are
+	// written when this is omitted. Sensitive outputs are never written.
+	// +optional
+	Outputs []string `json:"outputs,omitempty"`
+
+	// Flatten object outputs into one ConfigMap key per field, named
+	// <output>.<field>. Object outputs are written as JSON when this is
+	// false.
+	// +optional
+	Flatten bool `json:"flatten,omitempty"`
+}
+
 // WorkspaceParameters are the configurable fields of a Workspace.
 type WorkspaceParameters struct {
 	// The root module of this workspace; i.e. the module containing its main.tf
 	// file. When the workspace's source is 'Remote' (the default) this can be
 	// any address supported by tofu init -from-module, for example a git
-	// repository or an S3 bucket. When the workspace's source is 'Inline' the
-	// content of a simple main.tf or main.tf.json file may be written inline.
+	// repository or an S3 bucket. When the workspace's source is 'OCI' this is
+	// a reference to an OCI artifact, for example
+	// registry.example.org/modules/vpc:1.0.0 or
+	// registry.example.org/modules/vpc@sha256:<digest>. When the workspace's
+	// source is 'Inline' the content of a simple main.tf or main.tf.json file
+	// may be written inline.
+	// It may be omitted if the workspace's inline files include a main.tf.
+	// +optional
 	Module string `json:"module"`
 
 	// Specifies the format of the inline Terraform content
 	// if Source is 'Inline'
 	InlineFormat FileFormat `json:"inlineFormat,omitempty"`
 
+	// Files of the root module, keyed by their path relative to the root
+	// module. Files are written alongside the inline module when the
+	// workspace's source is 'Inline', and are ignored otherwise. Paths must
+	// not be absolute or refer to the parent directory.
+	// +optional
+	Files map[string]InlineFile `json:"files,omitempty"`
+
 	// Source of the root module of this workspace.
 	Source ModuleSource `json:"source"`
 
+	// ModuleRefs reference the ConfigMaps and Secrets that contain the files
+	// of the root module when the workspace's source is 'ConfigMap'. Every
+	// key of every referenced object is written as a file.
+	// +optional
+	ModuleRefs []ModuleFilesReference `json:"moduleRefs,omitempty"`
+
 	// Entrypoint for `tofu init` within the module
 	// +kubebuilder:default=""
 	// +optional
@@ -129,27 +418,144 @@ type WorkspaceParameters struct {
 	// +optional
 	VarFiles []VarFile `json:"varFiles,omitempty"`
 
+	// Imports adopt existing infrastructure into the workspace's tofu
+	// state. Each import is planned, and then applied along with the rest of
+	// the workspace. Resources that are already in the state are not imported
+	// again.
+	// +optional
+	// +listType=map
+	// +listMapKey=address
+	Imports []Import `json:"imports,omitempty"`
+
 	// Arguments to be included in the tofu init CLI command
 	InitArgs []string `json:"initArgs,omitempty"`
 
 	// Arguments to be included in the tofu plan CLI command
 	PlanArgs []string `json:"planArgs,omitempty"`
 
-	// Arguments to be included in the tofu apply CLI command
+	// Arguments to be included in the tofu apply CLI command. The plan that
+	// was observed is applied as-is unless apply arguments are supplied, in
+	// which case the workspace is planned again when it is applied.
 	ApplyArgs []string `json:"applyArgs,omitempty"`
 
 	// Arguments to be included in the tofu destroy CLI command
 	DestroyArgs []string `json:"destroyArgs,omitempty"`
 
+	// ConnectionDetails to write to the connection secret. All outputs are
+	// written, keyed by their names, when this is omitted. Only the listed
+	// outputs are written when it is set.
+	// +optional
+	ConnectionDetails []ConnectionDetail `json:"connectionDetails,omitempty"`
+
+	// OutputsConfigMapRef references a ConfigMap that is kept in sync with
+	// the Workspace's non-sensitive outputs. The ConfigMap is owned by, and
+	// deleted along with, the Workspace. An existing ConfigMap that isn't
+	// controlled by the Workspace is never overwritten.
+	// +optional
+	OutputsConfigMapRef *OutputsConfigMapReference `json:"outputsConfigMapRef,omitempty"`
+
+	// ReadinessCheck derives the readiness of the Workspace from one of its
+	// outputs. When it is omitted the Workspace is ready once its plan has
+	// been applied.
+	// +optional
+	ReadinessCheck *ReadinessCheck `json:"readinessCheck,omitempty"`
+
+	// Approval determines which plans must be approved before they are
+	// applied. A plan that requires approval is not applied until the
+	// workspace is annotated with opentofu.upbound.io/approved-plan set to
+	// the plan's hash. Approved plans are applied exactly as they were
+	// planned; applyArgs are ignored.
+	// +kubebuilder:default=Auto
+	// +optional
+	Approval *ApprovalPolicy `json:"approval,omitempty"`
+
 	// Boolean value to indicate CLI logging of tofu execution is enabled or not
 	// +optional
 	EnableTofuCLILogging bool `json:"enableTofuCLILogging,omitempty"`
+
+	// TofuVersion is the version of tofu that runs this workspace, e.g.
+	// 1.10.0. It overrides the tofu version of the provider config. The
+	// version of tofu installed in the provider image is used by default.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.]+)?$`
+	TofuVersion string `json:"tofuVersion,omitempty"`
 }
 
 // WorkspaceObservation are the observable fields of a Workspace.
 type WorkspaceObservation struct {
 	Checksum string                       `json:"checksum,omitempty"`
 	Outputs  map[string]extensionsV1.JSON `json:"outputs,omitempty"`
+
+	// PlanSummary summarizes the changes the most recently observed plan
+	// would make, for example "3 to add, 1 to change, 0 to destroy".
+	// +optional
+	PlanSummary string `json:"planSummary,omitempty"`
+
+	// ModuleRevision is the revision of the remote module that was most
+	// recently observed. It is the commit ID of a module from a git source,
+	// the manifest digest of a module from an OCI source, or the sha256
+	// digest of the content of a module from any other remote source.
+	// +optional
+	ModuleRevision string `json:"moduleRevision,omitempty"`
+
+	// AppliedModuleRevision is the revision of the remote module that was
+	// most recently applied, or that the Workspace was observed to be up to
+	// date with. A Workspace whose applied revision differs from
+	// its observed revision has not yet applied the latest module.
+	// +optional
+	AppliedModuleRevision string `json:"appliedModuleRevision,omitempty"`
+
+	// TofuVersion is the version of tofu that most recently observed or
+	// applied the workspace. It is empty if the version of the tofu
+	// installed in the provider image is unknown.
+	// +optional
+	TofuVersion string `json:"tofuVersion,omitempty"`
+
+	// StateLock is the lock on the workspace's state that most recently
+	// prevented tofu from applying or destroying it.
+	// +optional
+	StateLock *StateLock `json:"stateLock,omitempty"`
+
+	// Drift lists the resources that changed outside of tofu since tofu last
+	// recorded them in the workspace's state, as of the most recent plan.
+	// +optional
+	Drift []DriftedResource `json:"drift,omitempty"`
+
+	// Imports reports the result of each of the workspace's imports.
+	// +optional
+	Imports []ImportStatus `json:"imports,omitempty"`
+}
+
+// A DriftedResource changed outside of tofu.
+type DriftedResource struct {
+	// Address of the resource instance, e.g. aws_s3_bucket.example.
+	Address string `json:"address"`
+
+	// Deleted is true if the resource no longer exists.
+	// +optional
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Attributes whose values changed. Only top level attributes are listed.
+	// +optional
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// A StateLock on a workspace's tofu state.
+type StateLock struct {
+	// ID of the lock.
+	ID string `json:"id"`
+
+	// Holder of the lock, as user@host.
+	// +optional
+	Holder string `json:"holder,omitempty"`
+
+	// Operation the holder acquired the lock for, e.g. OperationTypeApply.
+	// +optional
+	Operation string `json:"operation,omitempty"`
+
+	// Created is when the holder acquired the lock.
+	// +optional
+	Created *metav1.Time `json:"created,omitempty"`
 }
 
 // A WorkspaceSpec defines the desired state of a Workspace.
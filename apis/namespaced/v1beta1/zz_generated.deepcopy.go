@@ -9,7 +9,8 @@
 package v1beta1
 
 import (
-	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -72,6 +73,41 @@ func (in *ClusterProviderConfigList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionDetail) DeepCopyInto(out *ConnectionDetail) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionDetail.
+func (in *ConnectionDetail) DeepCopy() *ConnectionDetail {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionDetail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftedResource) DeepCopyInto(out *DriftedResource) {
+	*out = *in
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftedResource.
+func (in *DriftedResource) DeepCopy() *DriftedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnvVar) DeepCopyInto(out *EnvVar) {
 	*out = *in
@@ -97,6 +133,115 @@ func (in *EnvVar) DeepCopy() *EnvVar {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSSHConfig) DeepCopyInto(out *GitSSHConfig) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]GitSSHKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KnownHosts != nil {
+		in, out := &in.KnownHosts, &out.KnownHosts
+		*out = new(GitSSHCredentials)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSSHConfig.
+func (in *GitSSHConfig) DeepCopy() *GitSSHConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSSHConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSSHCredentials) DeepCopyInto(out *GitSSHCredentials) {
+	*out = *in
+	in.CommonCredentialSelectors.DeepCopyInto(&out.CommonCredentialSelectors)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSSHCredentials.
+func (in *GitSSHCredentials) DeepCopy() *GitSSHCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSSHCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSSHKey) DeepCopyInto(out *GitSSHKey) {
+	*out = *in
+	in.GitSSHCredentials.DeepCopyInto(&out.GitSSHCredentials)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSSHKey.
+func (in *GitSSHKey) DeepCopy() *GitSSHKey {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSSHKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Import) DeepCopyInto(out *Import) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Import.
+func (in *Import) DeepCopy() *Import {
+	if in == nil {
+		return nil
+	}
+	out := new(Import)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportStatus) DeepCopyInto(out *ImportStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportStatus.
+func (in *ImportStatus) DeepCopy() *ImportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InlineFile) DeepCopyInto(out *InlineFile) {
+	*out = *in
+	if in.Format != nil {
+		in, out := &in.Format, &out.Format
+		*out = new(FileFormat)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InlineFile.
+func (in *InlineFile) DeepCopy() *InlineFile {
+	if in == nil {
+		return nil
+	}
+	out := new(InlineFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeyReference) DeepCopyInto(out *KeyReference) {
 	*out = *in
@@ -112,6 +257,41 @@ func (in *KeyReference) DeepCopy() *KeyReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModuleFilesReference) DeepCopyInto(out *ModuleFilesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleFilesReference.
+func (in *ModuleFilesReference) DeepCopy() *ModuleFilesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ModuleFilesReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputsConfigMapReference) DeepCopyInto(out *OutputsConfigMapReference) {
+	*out = *in
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputsConfigMapReference.
+func (in *OutputsConfigMapReference) DeepCopy() *OutputsConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputsConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProviderConfig) DeepCopyInto(out *ProviderConfig) {
 	*out = *in
@@ -196,6 +376,26 @@ func (in *ProviderConfigSpec) DeepCopyInto(out *ProviderConfigSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.AllowedModuleSources != nil {
+		in, out := &in.AllowedModuleSources, &out.AllowedModuleSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedModuleSources != nil {
+		in, out := &in.DeniedModuleSources, &out.DeniedModuleSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GitSSH != nil {
+		in, out := &in.GitSSH, &out.GitSSH
+		*out = new(GitSSHConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StateLock != nil {
+		in, out := &in.StateLock, &out.StateLock
+		*out = new(StateLockPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigSpec.
@@ -298,9 +498,78 @@ func (in *ProviderCredentials) DeepCopy() *ProviderCredentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadinessCheck) DeepCopyInto(out *ReadinessCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessCheck.
+func (in *ReadinessCheck) DeepCopy() *ReadinessCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadinessCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StateLock) DeepCopyInto(out *StateLock) {
+	*out = *in
+	if in.Created != nil {
+		in, out := &in.Created, &out.Created
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateLock.
+func (in *StateLock) DeepCopy() *StateLock {
+	if in == nil {
+		return nil
+	}
+	out := new(StateLock)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StateLockPolicy) DeepCopyInto(out *StateLockPolicy) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ForceUnlockAfter != nil {
+		in, out := &in.ForceUnlockAfter, &out.ForceUnlockAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateLockPolicy.
+func (in *StateLockPolicy) DeepCopy() *StateLockPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(StateLockPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Var) DeepCopyInto(out *Var) {
 	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(VarSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JSONValue != nil {
+		in, out := &in.JSONValue, &out.JSONValue
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Var.
@@ -343,6 +612,36 @@ func (in *VarFile) DeepCopy() *VarFile {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarSource) DeepCopyInto(out *VarSource) {
+	*out = *in
+	if in.ConfigMapKeyReference != nil {
+		in, out := &in.ConfigMapKeyReference, &out.ConfigMapKeyReference
+		*out = new(KeyReference)
+		**out = **in
+	}
+	if in.SecretKeyReference != nil {
+		in, out := &in.SecretKeyReference, &out.SecretKeyReference
+		*out = new(KeyReference)
+		**out = **in
+	}
+	if in.WorkspaceOutputReference != nil {
+		in, out := &in.WorkspaceOutputReference, &out.WorkspaceOutputReference
+		*out = new(WorkspaceOutputReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarSource.
+func (in *VarSource) DeepCopy() *VarSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VarSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Workspace) DeepCopyInto(out *Workspace) {
 	*out = *in
@@ -407,11 +706,28 @@ func (in *WorkspaceObservation) DeepCopyInto(out *WorkspaceObservation) {
 	*out = *in
 	if in.Outputs != nil {
 		in, out := &in.Outputs, &out.Outputs
-		*out = make(map[string]v1.JSON, len(*in))
+		*out = make(map[string]apiextensionsv1.JSON, len(*in))
 		for key, val := range *in {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.StateLock != nil {
+		in, out := &in.StateLock, &out.StateLock
+		*out = new(StateLock)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = make([]DriftedResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Imports != nil {
+		in, out := &in.Imports, &out.Imports
+		*out = make([]ImportStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceObservation.
@@ -424,9 +740,36 @@ func (in *WorkspaceObservation) DeepCopy() *WorkspaceObservation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceOutputReference) DeepCopyInto(out *WorkspaceOutputReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceOutputReference.
+func (in *WorkspaceOutputReference) DeepCopy() *WorkspaceOutputReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceOutputReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkspaceParameters) DeepCopyInto(out *WorkspaceParameters) {
 	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make(map[string]InlineFile, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.ModuleRefs != nil {
+		in, out := &in.ModuleRefs, &out.ModuleRefs
+		*out = make([]ModuleFilesReference, len(*in))
+		copy(*out, *in)
+	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
 		*out = make([]EnvVar, len(*in))
@@ -437,7 +780,9 @@ func (in *WorkspaceParameters) DeepCopyInto(out *WorkspaceParameters) {
 	if in.Vars != nil {
 		in, out := &in.Vars, &out.Vars
 		*out = make([]Var, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.VarMap != nil {
 		in, out := &in.VarMap, &out.VarMap
@@ -451,6 +796,11 @@ func (in *WorkspaceParameters) DeepCopyInto(out *WorkspaceParameters) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Imports != nil {
+		in, out := &in.Imports, &out.Imports
+		*out = make([]Import, len(*in))
+		copy(*out, *in)
+	}
 	if in.InitArgs != nil {
 		in, out := &in.InitArgs, &out.InitArgs
 		*out = make([]string, len(*in))
@@ -471,6 +821,26 @@ func (in *WorkspaceParameters) DeepCopyInto(out *WorkspaceParameters) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ConnectionDetails != nil {
+		in, out := &in.ConnectionDetails, &out.ConnectionDetails
+		*out = make([]ConnectionDetail, len(*in))
+		copy(*out, *in)
+	}
+	if in.OutputsConfigMapRef != nil {
+		in, out := &in.OutputsConfigMapRef, &out.OutputsConfigMapRef
+		*out = new(OutputsConfigMapReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessCheck != nil {
+		in, out := &in.ReadinessCheck, &out.ReadinessCheck
+		*out = new(ReadinessCheck)
+		**out = **in
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalPolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceParameters.
@@ -43,6 +43,7 @@ import (
 	clustercontroller "github.com/upbound/provider-opentofu/internal/controller/cluster"
 	namespacedcontroller "github.com/upbound/provider-opentofu/internal/controller/namespaced"
 	"github.com/upbound/provider-opentofu/internal/features"
+	"github.com/upbound/provider-opentofu/internal/tofuversion"
 )
 
 func init() {
@@ -61,6 +62,10 @@ func main() {
 		pollStateMetricInterval  = app.Flag("poll-state-metric", "State metric recording interval").Default("5s").Duration()
 		pollJitter               = app.Flag("poll-jitter", "If non-zero, varies the poll interval by a random amount up to plus-or-minus this value.").Default("1m").Duration()
 		timeout                  = app.Flag("timeout", "Controls how long tofu processes may run before they are killed.").Default("20m").Duration()
+		moduleRefresh            = app.Flag("module-cache-refresh", "Controls how often cached remote modules are checked for new revisions.").Default("1m").Duration()
+		tofuVersion              = app.Flag("tofu-version", "The version of the tofu binary in the PATH, which runs workspaces that don't specify a tofu version.").Envar("OPENTOFU_VERSION").String()
+		tofuVersionsDir          = app.Flag("tofu-versions-dir", "Directory in which other versions of tofu are installed, e.g. 1.9.0/tofu.").Default("/tofu/versions").String()
+		tofuMirror               = app.Flag("tofu-mirror", "Base URL of a mirror of OpenTofu releases, from which versions of tofu that aren't installed are downloaded, e.g. https://github.com/opentofu/opentofu/releases/download. Versions aren't downloaded if it's empty.").String()
 		leaderElection           = app.Flag("leader-election", "Use leader election for the controller manager.").Short('l').Default("false").Envar("LEADER_ELECTION").Bool()
 		maxReconcileRate         = app.Flag("max-reconcile-rate", "The maximum number of concurrent reconciliation operations.").Default("1").Int()
 		enableManagementPolicies = app.Flag("enable-management-policies", "Enable support for Management Policies.").Default("true").Envar("ENABLE_MANAGEMENT_POLICIES").Bool()
@@ -112,6 +117,12 @@ func main() {
 	metrics.Registry.MustRegister(metricRecorder)
 	metrics.Registry.MustRegister(stateMetrics)
 
+	versions := tofuversion.New(*tofuVersionsDir,
+		tofuversion.WithDefault(tofuversion.Binary, *tofuVersion),
+		tofuversion.WithMirror(*tofuMirror),
+		tofuversion.WithLogger(log))
+	metrics.Registry.MustRegister(versions)
+
 	ctx := context.Background()
 	clusterOpts := controller.Options{
 		Logger:                  log,
@@ -151,12 +162,12 @@ func main() {
 		clusterOpts.Gate = crdGate
 		namespacedOpts.Gate = crdGate
 		kingpin.FatalIfError(customresourcesgate.Setup(mgr, namespacedOpts), "Cannot setup CRD gate")
-		kingpin.FatalIfError(clustercontroller.SetupGated(mgr, clusterOpts, *timeout, *pollJitter), "Cannot setup cluster-scoped Workspace controllers")
-		kingpin.FatalIfError(namespacedcontroller.SetupGated(mgr, namespacedOpts, *timeout, *pollJitter), "Cannot setup namespaced Workspace controllers")
+		kingpin.FatalIfError(clustercontroller.SetupGated(mgr, clusterOpts, *timeout, *pollJitter, *moduleRefresh, versions), "Cannot setup cluster-scoped Workspace controllers")
+		kingpin.FatalIfError(namespacedcontroller.SetupGated(mgr, namespacedOpts, *timeout, *pollJitter, *moduleRefresh, versions), "Cannot setup namespaced Workspace controllers")
 	} else {
 		log.Info("Provider has missing RBAC permissions for watching CRDs, controller SafeStart capability will be disabled")
-		kingpin.FatalIfError(clustercontroller.Setup(mgr, clusterOpts, *timeout, *pollJitter), "Cannot setup cluster-scoped Workspace controllers")
-		kingpin.FatalIfError(namespacedcontroller.Setup(mgr, namespacedOpts, *timeout, *pollJitter), "Cannot setup namespaced Workspace controllers")
+		kingpin.FatalIfError(clustercontroller.Setup(mgr, clusterOpts, *timeout, *pollJitter, *moduleRefresh, versions), "Cannot setup cluster-scoped Workspace controllers")
+		kingpin.FatalIfError(namespacedcontroller.Setup(mgr, namespacedOpts, *timeout, *pollJitter, *moduleRefresh, versions), "Cannot setup namespaced Workspace controllers")
 	}
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
@@ -144,5 +144,15 @@ func enrichLocalSecretRefs(pc *namespacedv1beta1.ProviderConfig, mg resource.Man
 				}
 			}
 		}
+		if pc.Spec.GitSSH != nil {
+			for _, k := range pc.Spec.GitSSH.Keys {
+				if k.SecretRef != nil {
+					k.SecretRef.Namespace = mg.GetNamespace()
+				}
+			}
+			if kh := pc.Spec.GitSSH.KnownHosts; kh != nil && kh.SecretRef != nil {
+				kh.SecretRef.Namespace = mg.GetNamespace()
+			}
+		}
 	}
 }
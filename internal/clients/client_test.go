@@ -0,0 +1,95 @@
+/*
+Copyright 2025 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"context"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/upbound/provider-opentofu/apis/namespaced"
+	namespacedv1beta1 "github.com/upbound/provider-opentofu/apis/namespaced/v1beta1"
+)
+
+func TestResolveProviderConfigSecretNamespaces(t *testing.T) {
+	ref := func() xpv1.CommonCredentialSelectors {
+		return xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{
+			SecretReference: xpv1.SecretReference{Name: "secret", Namespace: "other-tenant"},
+			Key:             "key",
+		}}
+	}
+	pc := namespacedv1beta1.ProviderConfig{
+		Spec: namespacedv1beta1.ProviderConfigSpec{
+			Credentials: []namespacedv1beta1.ProviderCredentials{
+				{Filename: "creds", Source: xpv1.CredentialsSourceSecret, CommonCredentialSelectors: ref()},
+			},
+			GitSSH: &namespacedv1beta1.GitSSHConfig{
+				Keys: []namespacedv1beta1.GitSSHKey{
+					{Host: "*", GitSSHCredentials: namespacedv1beta1.GitSSHCredentials{Source: xpv1.CredentialsSourceSecret, CommonCredentialSelectors: ref()}},
+				},
+				KnownHosts: &namespacedv1beta1.GitSSHCredentials{Source: xpv1.CredentialsSourceSecret, CommonCredentialSelectors: ref()},
+			},
+		},
+	}
+
+	kube := &test.MockClient{
+		MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+			pc.DeepCopyInto(obj.(*namespacedv1beta1.ProviderConfig))
+			return nil
+		}),
+		MockScheme: func() *runtime.Scheme {
+			s := runtime.NewScheme()
+			if err := namespaced.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+	mg := &namespacedv1beta1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "tenant"},
+		Spec: namespacedv1beta1.WorkspaceSpec{
+			ManagedResourceSpec: xpv2.ManagedResourceSpec{
+				ProviderConfigReference: &xpv1.ProviderConfigReference{Name: "default", Kind: namespacedv1beta1.ProviderConfigKind},
+			},
+		},
+	}
+	mt := ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil })
+
+	got, err := ResolveProviderConfig(context.Background(), kube, nil, mt, mg)
+	if err != nil {
+		t.Fatalf("ResolveProviderConfig(...): %v", err)
+	}
+
+	// A namespaced ProviderConfig may only reference Secrets in the namespace
+	// of the Workspace that uses it.
+	namespaces := []string{
+		got.Spec.Credentials[0].SecretRef.Namespace,
+		got.Spec.GitSSH.Keys[0].SecretRef.Namespace,
+		got.Spec.GitSSH.KnownHosts.SecretRef.Namespace,
+	}
+	if diff := cmp.Diff([]string{"tenant", "tenant", "tenant"}, namespaces); diff != "" {
+		t.Errorf("ResolveProviderConfig(...): -want secret namespaces, +got secret namespaces:\n%s", diff)
+	}
+}
@@ -15,16 +15,17 @@ import (
 
 	"github.com/upbound/provider-opentofu/internal/controller/cluster/config"
 	"github.com/upbound/provider-opentofu/internal/controller/cluster/workspace"
+	"github.com/upbound/provider-opentofu/internal/tofuversion"
 )
 
 // Setup creates all opentofu controllers with the supplied logger and adds them
 // to the supplied manager.
-func Setup(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJitter time.Duration) error {
-	for _, setup := range []func(ctrl.Manager, controller.Options, time.Duration, time.Duration) error{
+func Setup(mgr ctrl.Manager, o controller.Options, timeout, pollJitter, moduleRefresh time.Duration, versions *tofuversion.Installer) error {
+	for _, setup := range []func(ctrl.Manager, controller.Options, time.Duration, time.Duration, time.Duration, *tofuversion.Installer) error{
 		config.Setup,
 		workspace.Setup,
 	} {
-		if err := setup(mgr, o, timeout, pollJitter); err != nil {
+		if err := setup(mgr, o, timeout, pollJitter, moduleRefresh, versions); err != nil {
 			return err
 		}
 	}
@@ -33,12 +34,12 @@ func Setup(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJi
 
 // SetupGated creates all controllers with the supplied logger and adds them to
 // the supplied manager gated.
-func SetupGated(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJitter time.Duration) error {
-	for _, setup := range []func(ctrl.Manager, controller.Options, time.Duration, time.Duration) error{
+func SetupGated(mgr ctrl.Manager, o controller.Options, timeout, pollJitter, moduleRefresh time.Duration, versions *tofuversion.Installer) error {
+	for _, setup := range []func(ctrl.Manager, controller.Options, time.Duration, time.Duration, time.Duration, *tofuversion.Installer) error{
 		config.SetupGated,
 		workspace.SetupGated,
 	} {
-		if err := setup(mgr, o, timeout, pollJitter); err != nil {
+		if err := setup(mgr, o, timeout, pollJitter, moduleRefresh, versions); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,155 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package workspace
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/upbound/provider-opentofu/apis/cluster/v1beta1"
+	"github.com/upbound/provider-opentofu/internal/opentofu"
+)
+
+func TestRecordFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+	errInvalid := opentofu.Classify(&exec.ExitError{Stderr: []byte("Error: Unsupported argument\n")})
+	errThrottled := opentofu.Classify(&exec.ExitError{Stderr: []byte("Error: Throttling: Rate exceeded\n")})
+
+	type want struct {
+		err       error
+		condition *xpv1.Condition
+		terminal  bool
+	}
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   want
+	}{
+		"Succeeded": {
+			reason: "A successful operation should be recorded.",
+			want: want{
+				condition: &xpv1.Condition{Type: v1beta1.TypeTofuSucceeded, Status: "True", Reason: v1beta1.ReasonTofuSucceeded},
+			},
+		},
+		"NotTofu": {
+			reason: "Errors that aren't tofu failures should not be recorded.",
+			err:    errBoom,
+			want:   want{err: errBoom},
+		},
+		"Terminal": {
+			reason: "A terminal failure should be recorded with the reason for its category.",
+			err:    errors.Wrap(errInvalid, errDiff),
+			want: want{
+				err:       errors.Wrap(errInvalid, errDiff),
+				condition: &xpv1.Condition{Type: v1beta1.TypeTofuSucceeded, Status: "False", Reason: v1beta1.ReasonConfigInvalid, Message: errInvalid.Error()},
+				terminal:  true,
+			},
+		},
+		"Transient": {
+			reason: "A transient failure should be recorded with the reason for its category.",
+			err:    errors.Wrap(errThrottled, errApply),
+			want: want{
+				err:       errors.Wrap(errThrottled, errApply),
+				condition: &xpv1.Condition{Type: v1beta1.TypeTofuSucceeded, Status: "False", Reason: v1beta1.ReasonRateLimited, Message: errThrottled.Error()},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := newFailures()
+			cr := &v1beta1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "cool"}}
+			err := f.record(cr, tc.err)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nf.record(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			var got *xpv1.Condition
+			if c := cr.GetCondition(v1beta1.TypeTofuSucceeded); c.Reason != "" {
+				got = &c
+			}
+			if diff := cmp.Diff(tc.want.condition, got, cmpopts.IgnoreFields(xpv1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("\n%s\nf.record(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+			if got := f.take(types.NamespacedName{Name: "cool"}); got != tc.want.terminal {
+				t.Errorf("\n%s\nf.take(...): want terminal %t, got %t", tc.reason, tc.want.terminal, got)
+			}
+		})
+	}
+}
+
+type reconcilerFn func(ctx context.Context, req reconcile.Request) (reconcile.Result, error)
+
+func (fn reconcilerFn) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	return fn(ctx, req)
+}
+
+func TestBackoffReconcile(t *testing.T) {
+	errInvalid := opentofu.Classify(&exec.ExitError{Stderr: []byte("Error: Unsupported argument\n")})
+	errThrottled := opentofu.Classify(&exec.ExitError{Stderr: []byte("Error: Throttling: Rate exceeded\n")})
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "cool"}}
+
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   reconcile.Result
+	}{
+		"Terminal": {
+			reason: "A Workspace whose tofu failure was terminal should be requeued after the delay.",
+			err:    errInvalid,
+			want:   reconcile.Result{RequeueAfter: time.Hour},
+		},
+		"Transient": {
+			reason: "A Workspace whose tofu failure was transient should be requeued with the usual backoff.",
+			err:    errThrottled,
+			want:   reconcile.Result{Requeue: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := newFailures()
+			// A terminal failure recorded by an earlier reconcile should be
+			// forgotten.
+			f.terminal[req.NamespacedName] = true
+
+			ec := &failureConnecter{
+				ExternalConnecter: managed.ExternalConnectorFn(func(_ context.Context, _ resource.Managed) (managed.ExternalClient, error) {
+					return nil, tc.err
+				}),
+				failures: f,
+			}
+			r := &backoffReconciler{
+				Reconciler: reconcilerFn(func(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+					_, err := ec.Connect(ctx, &v1beta1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "cool"}})
+					return reconcile.Result{Requeue: err != nil}, nil
+				}),
+				failures: f,
+				delay:    time.Hour,
+			}
+			got, err := r.Reconcile(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package workspace
+
+import (
+	"context"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/upbound/provider-opentofu/apis/cluster/v1beta1"
+)
+
+// Workspaces are indexed by the objects they reference, so that they can be
+// reconciled as soon as those objects change. Index keys are namespace/name
+// strings, or /name for cluster scoped objects.
+const (
+	workspaceOutputRefIndex = "workspaceOutputRefs"
+	secretRefIndex          = "secretRefs"
+	configMapRefIndex       = "configMapRefs"
+	providerConfigRefIndex  = "providerConfigRef"
+)
+
+func key(namespace, name string) string {
+	return types.NamespacedName{Namespace: namespace, Name: name}.String()
+}
+
+func objectKey(o client.Object) string {
+	return key(o.GetNamespace(), o.GetName())
+}
+
+// index Workspaces by the objects they reference.
+func index(mgr ctrl.Manager) error {
+	indexes := map[string]client.IndexerFunc{
+		workspaceOutputRefIndex: workspaceOutputRefs,
+		secretRefIndex:          secretRefs,
+		configMapRefIndex:       configMapRefs,
+		providerConfigRefIndex:  providerConfigRef,
+	}
+	for name, fn := range indexes {
+		if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &v1beta1.Workspace{}, name, fn); err != nil {
+			return errors.Wrapf(err, "cannot add %s index", name)
+		}
+	}
+	return nil
+}
+
+// workspaceOutputRefs returns the keys of the Workspaces whose outputs the
+// supplied Workspace's variables reference.
+func workspaceOutputRefs(o client.Object) []string {
+	ws, ok := o.(*v1beta1.Workspace)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, v := range ws.Spec.ForProvider.Vars {
+		if v.ValueFrom != nil && v.ValueFrom.WorkspaceOutputReference != nil {
+			keys = append(keys, key("", v.ValueFrom.WorkspaceOutputReference.Name))
+		}
+	}
+	return keys
+}
+
+// secretRefs returns the keys of the Secrets the supplied Workspace's var
+// files, environment variables, variables and module reference.
+func secretRefs(o client.Object) []string {
+	ws, ok := o.(*v1beta1.Workspace)
+	if !ok {
+		return nil
+	}
+	p := ws.Spec.ForProvider
+	var keys []string
+	for _, vf := range p.VarFiles {
+		if vf.Source == v1beta1.VarFileSourceSecretKey && vf.SecretKeyReference != nil {
+			keys = append(keys, key(vf.SecretKeyReference.Namespace, vf.SecretKeyReference.Name))
+		}
+	}
+	for _, e := range p.Env {
+		if e.SecretKeyReference != nil {
+			keys = append(keys, key(e.SecretKeyReference.Namespace, e.SecretKeyReference.Name))
+		}
+	}
+	for _, v := range p.Vars {
+		if v.ValueFrom != nil && v.ValueFrom.SecretKeyReference != nil {
+			keys = append(keys, key(v.ValueFrom.SecretKeyReference.Namespace, v.ValueFrom.SecretKeyReference.Name))
+		}
+	}
+	for _, ref := range p.ModuleRefs {
+		if ref.Kind == v1beta1.ModuleFilesKindSecret {
+			keys = append(keys, key(ref.Namespace, ref.Name))
+		}
+	}
+	return keys
+}
+
+// configMapRefs returns the keys of the ConfigMaps the supplied Workspace's
+// var files, environment variables, variables and module reference.
+func configMapRefs(o client.Object) []string {
+	ws, ok := o.(*v1beta1.Workspace)
+	if !ok {
+		return nil
+	}
+	p := ws.Spec.ForProvider
+	var keys []string
+	for _, vf := range p.VarFiles {
+		if vf.Source == v1beta1.VarFileSourceConfigMapKey && vf.ConfigMapKeyReference != nil {
+			keys = append(keys, key(vf.ConfigMapKeyReference.Namespace, vf.ConfigMapKeyReference.Name))
+		}
+	}
+	for _, e := range p.Env {
+		if e.ConfigMapKeyReference != nil {
+			keys = append(keys, key(e.ConfigMapKeyReference.Namespace, e.ConfigMapKeyReference.Name))
+		}
+	}
+	for _, v := range p.Vars {
+		if v.ValueFrom != nil && v.ValueFrom.ConfigMapKeyReference != nil {
+			keys = append(keys, key(v.ValueFrom.ConfigMapKeyReference.Namespace, v.ValueFrom.ConfigMapKeyReference.Name))
+		}
+	}
+	for _, ref := range p.ModuleRefs {
+		if ref.Kind == v1beta1.ModuleFilesKindConfigMap || ref.Kind == "" {
+			keys = append(keys, key(ref.Namespace, ref.Name))
+		}
+	}
+	return keys
+}
+
+// providerConfigRef returns the key of the supplied Workspace's
+// ProviderConfig.
+func providerConfigRef(o client.Object) []string {
+	ws, ok := o.(*v1beta1.Workspace)
+	if !ok {
+		return nil
+	}
+	ref := ws.GetProviderConfigReference()
+	if ref == nil {
+		return nil
+	}
+	return []string{key("", ref.Name)}
+}
+
+// indexed returns requests for the Workspaces the supplied index maps to the
+// supplied key.
+func indexed(ctx context.Context, kube client.Reader, index, key string) []reconcile.Request {
+	l := &v1beta1.WorkspaceList{}
+	if err := kube.List(ctx, l, client.MatchingFields{index: key}); err != nil {
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(l.Items))
+	for _, ws := range l.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: ws.GetName()}})
+	}
+	return reqs
+}
+
+// enqueueIndexed enqueues the Workspaces the supplied index maps to the key
+// of a changed object.
+func enqueueIndexed(kube client.Reader, index string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
+		return indexed(ctx, kube, index, objectKey(o))
+	})
+}
+
+// enqueueSecretRefs enqueues the Workspaces that reference a changed Secret,
+// either directly or via the credentials or git SSH keys of their
+// ProviderConfig.
+func enqueueSecretRefs(kube client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
+		reqs := indexed(ctx, kube, secretRefIndex, objectKey(o))
+
+		pcs := &v1beta1.ProviderConfigList{}
+		if err := kube.List(ctx, pcs); err == nil {
+			for _, pc := range pcs.Items {
+				if usesSecret(pc.Spec, o.GetNamespace(), o.GetName()) {
+					reqs = append(reqs, indexed(ctx, kube, providerConfigRefIndex, key("", pc.GetName()))...)
+				}
+			}
+		}
+		return reqs
+	})
+}
+
+// usesSecret returns true if any of the supplied ProviderConfig's credentials
+// or git SSH keys and known_hosts are read from the named Secret.
+func usesSecret(spec v1beta1.ProviderConfigSpec, namespace, name string) bool {
+	sels := make([]xpv1.CommonCredentialSelectors, 0, len(spec.Credentials))
+	for _, cd := range spec.Credentials {
+		if cd.Source == xpv1.CredentialsSourceSecret {
+			sels = append(sels, cd.CommonCredentialSelectors)
+		}
+	}
+	if spec.GitSSH != nil {
+		for _, k := range spec.GitSSH.Keys {
+			if k.Source == xpv1.CredentialsSourceSecret {
+				sels = append(sels, k.CommonCredentialSelectors)
+			}
+		}
+		if kh := spec.GitSSH.KnownHosts; kh != nil && kh.Source == xpv1.CredentialsSourceSecret {
+			sels = append(sels, kh.CommonCredentialSelectors)
+		}
+	}
+	for _, s := range sels {
+		if s.SecretRef != nil && s.SecretRef.Name == name && (s.SecretRef.Namespace == namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchReferences adds watches that enqueue Workspaces when the objects they
+// reference change.
+func watchReferences(b *ctrl.Builder, kube client.Reader) *ctrl.Builder {
+	return b.
+		Watches(&v1beta1.Workspace{}, enqueueIndexed(kube, workspaceOutputRefIndex), builder.WithPredicates(outputsChanged())).
+		Watches(&corev1.Secret{}, enqueueSecretRefs(kube)).
+		Watches(&corev1.ConfigMap{}, enqueueIndexed(kube, configMapRefIndex)).
+		Watches(&v1beta1.ProviderConfig{}, enqueueIndexed(kube, providerConfigRefIndex))
+}
+
+// outputsChanged accepts updates to Workspaces that change their outputs or
+// their readiness, which determine whether their outputs may be referenced.
+func outputsChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			old, ok := e.ObjectOld.(*v1beta1.Workspace)
+			if !ok {
+				return false
+			}
+			ws, ok := e.ObjectNew.(*v1beta1.Workspace)
+			if !ok {
+				return false
+			}
+			return !equality.Semantic.DeepEqual(old.Status.AtProvider.Outputs, ws.Status.AtProvider.Outputs) ||
+				old.GetCondition(xpv1.TypeReady).Status != ws.GetCondition(xpv1.TypeReady).Status
+		},
+	}
+}
@@ -0,0 +1,185 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package workspace
+
+import (
+	"context"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	extensionsV1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/upbound/provider-opentofu/apis/cluster/v1beta1"
+)
+
+type fakeQueue struct {
+	workqueue.TypedRateLimitingInterface[reconcile.Request]
+	added []reconcile.Request
+}
+
+func (q *fakeQueue) Add(r reconcile.Request) {
+	q.added = append(q.added, r)
+}
+
+func TestIndexes(t *testing.T) {
+	ws := &v1beta1.Workspace{
+		Spec: v1beta1.WorkspaceSpec{
+			ForProvider: v1beta1.WorkspaceParameters{
+				VarFiles: []v1beta1.VarFile{
+					{Source: v1beta1.VarFileSourceSecretKey, SecretKeyReference: &v1beta1.KeyReference{Namespace: "a", Name: "secret", Key: "k"}},
+					{Source: v1beta1.VarFileSourceConfigMapKey, ConfigMapKeyReference: &v1beta1.KeyReference{Namespace: "a", Name: "config", Key: "k"}},
+				},
+				Env: []v1beta1.EnvVar{
+					{Name: "A", SecretKeyReference: &v1beta1.KeyReference{Namespace: "b", Name: "env-secret", Key: "k"}},
+				},
+				Vars: []v1beta1.Var{
+					{Key: "a", Value: "b"},
+					{Key: "c", ValueFrom: &v1beta1.VarSource{ConfigMapKeyReference: &v1beta1.KeyReference{Namespace: "b", Name: "var-config", Key: "k"}}},
+					{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+				},
+				ModuleRefs: []v1beta1.ModuleFilesReference{
+					{Namespace: "c", Name: "module"},
+					{Kind: v1beta1.ModuleFilesKindSecret, Namespace: "c", Name: "module-secret"},
+				},
+			},
+		},
+	}
+	ws.SetProviderConfigReference(&xpv1.Reference{Name: "default"})
+
+	cases := map[string]struct {
+		fn   client.IndexerFunc
+		want []string
+	}{
+		"WorkspaceOutputRefs": {fn: workspaceOutputRefs, want: []string{"/network"}},
+		"SecretRefs":          {fn: secretRefs, want: []string{"a/secret", "b/env-secret", "c/module-secret"}},
+		"ConfigMapRefs":       {fn: configMapRefs, want: []string{"a/config", "b/var-config", "c/module"}},
+		"ProviderConfigRef":   {fn: providerConfigRef, want: []string{"/default"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.fn(ws)); diff != "" {
+				t.Errorf("index(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnqueueIndexed(t *testing.T) {
+	kube := &test.MockClient{
+		MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+			obj.(*v1beta1.WorkspaceList).Items = []v1beta1.Workspace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}},
+			}
+			return nil
+		}),
+	}
+	network := &v1beta1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "network"}}
+
+	q := &fakeQueue{}
+	enqueueIndexed(kube, workspaceOutputRefIndex).Update(context.Background(), event.UpdateEvent{ObjectOld: network, ObjectNew: network}, q)
+
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "cluster"}}}
+	if diff := cmp.Diff(want, q.added); diff != "" {
+		t.Errorf("enqueueIndexed(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestEnqueueSecretRefs(t *testing.T) {
+	creds := func(namespace string) []v1beta1.ProviderCredentials {
+		return []v1beta1.ProviderCredentials{{
+			Source:                    xpv1.CredentialsSourceSecret,
+			CommonCredentialSelectors: xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Namespace: namespace, Name: "creds"}, Key: "k"}},
+		}}
+	}
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj client.ObjectList, opts ...client.ListOption) error {
+			lo := &client.ListOptions{}
+			lo.ApplyOptions(opts)
+			switch l := obj.(type) {
+			case *v1beta1.ProviderConfigList:
+				l.Items = []v1beta1.ProviderConfig{
+					{ObjectMeta: metav1.ObjectMeta{Name: "pc"}, Spec: v1beta1.ProviderConfigSpec{Credentials: creds("crossplane-system")}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Spec: v1beta1.ProviderConfigSpec{Credentials: creds("default")}},
+				}
+			case *v1beta1.WorkspaceList:
+				// Each Workspace is named for the index key it matched.
+				for _, v := range []string{"secretRefs", "providerConfigRef"} {
+					if k, ok := lo.FieldSelector.RequiresExactMatch(v); ok {
+						l.Items = []v1beta1.Workspace{{ObjectMeta: metav1.ObjectMeta{Name: k}}}
+					}
+				}
+			}
+			return nil
+		},
+	}
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "creds"}}
+
+	q := &fakeQueue{}
+	enqueueSecretRefs(kube).Update(context.Background(), event.UpdateEvent{ObjectOld: s, ObjectNew: s}, q)
+
+	want := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "crossplane-system/creds"}},
+		{NamespacedName: types.NamespacedName{Name: "/pc"}},
+	}
+	if diff := cmp.Diff(want, q.added); diff != "" {
+		t.Errorf("enqueueSecretRefs(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestOutputsChanged(t *testing.T) {
+	ready := func(outputs map[string]extensionsV1.JSON) *v1beta1.Workspace {
+		ws := &v1beta1.Workspace{}
+		ws.SetConditions(xpv1.Available())
+		ws.Status.AtProvider.Outputs = outputs
+		return ws
+	}
+
+	cases := map[string]struct {
+		reason string
+		old    *v1beta1.Workspace
+		new    *v1beta1.Workspace
+		want   bool
+	}{
+		"Unchanged": {
+			reason: "Updates that don't change outputs or readiness should be ignored",
+			old:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"b"`)}}),
+			new:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"b"`)}}),
+			want:   false,
+		},
+		"OutputsChanged": {
+			reason: "Updates that change outputs should be accepted",
+			old:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"b"`)}}),
+			new:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"c"`)}}),
+			want:   true,
+		},
+		"BecameReady": {
+			reason: "Updates that change readiness should be accepted",
+			old:    &v1beta1.Workspace{},
+			new:    ready(nil),
+			want:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := outputsChanged().Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: tc.new})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\noutputsChanged().Update(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
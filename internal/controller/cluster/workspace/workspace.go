@@ -7,11 +7,19 @@ SPDX-License-Identifier: Apache-2.0
 package workspace
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,24 +29,34 @@ import (
 	"github.com/spf13/afero"
 	corev1 "k8s.io/api/core/v1"
 	extensionsV1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/ratelimiter"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
 
-	"github.com/hashicorp/go-getter"
-
 	"github.com/upbound/provider-opentofu/apis/cluster/v1beta1"
+	namespacedv1beta1 "github.com/upbound/provider-opentofu/apis/namespaced/v1beta1"
 	"github.com/upbound/provider-opentofu/internal/clients"
 	"github.com/upbound/provider-opentofu/internal/features"
+	"github.com/upbound/provider-opentofu/internal/gitauth"
+	"github.com/upbound/provider-opentofu/internal/modcache"
+	"github.com/upbound/provider-opentofu/internal/modpolicy"
+	"github.com/upbound/provider-opentofu/internal/oci"
 	"github.com/upbound/provider-opentofu/internal/opentofu"
+	"github.com/upbound/provider-opentofu/internal/tofuversion"
 	"github.com/upbound/provider-opentofu/internal/workdir"
 )
 
@@ -47,15 +65,32 @@ const (
 	errTrackPCUsage = "cannot track ProviderConfig usage"
 	errGetPC        = "cannot get ProviderConfig"
 	errGetCreds     = "cannot get credentials"
+	errIndex        = "cannot index Workspaces"
 
 	errMkdir           = "cannot make tofu configuration directory"
 	errRemoteModule    = "cannot get remote tofu module"
-	errSetGitCredDir   = "cannot set GIT_CRED_DIR environment variable"
+	errOCIModule       = "cannot get OCI tofu module"
+	errRegistryCreds   = "cannot get OCI registry credentials"
+	errTofuVersion     = "cannot resolve tofu version"
+	errVersion         = "cannot determine tofu version"
+	errFmtVersion      = "tofu binary %s is version %s, not %s"
+	errModuleSource    = "cannot use module source"
+	errFmtForbidden    = "%s modules are forbidden by the ProviderConfig"
 	errWriteCreds      = "cannot write tofu credentials"
-	errWriteGitCreds   = "cannot write .git-credentials to /tmp dir"
+	errWriteGitCreds   = "cannot write git credentials to /tmp dir"
 	errWriteConfig     = "cannot write tofu configuration " + tfConfig
 	errWriteMain       = "cannot write tofu configuration "
+	errInlineFiles     = "cannot write inline module files"
+	errFmtInlinePath   = "invalid inline file path %q"
+	errFmtInlineJSON   = "inline file %q is not valid JSON"
+	errFmtInlineMain   = "inline file %q conflicts with the inline module"
+	errModuleRefs      = "cannot write module files from referenced objects"
+	errFmtModuleRef    = "cannot get %s %q"
+	errFmtModulePath   = "invalid path %q for key %q of %s %q"
+	errFmtModuleDup    = "module file %q is defined more than once"
+	errFmtModuleKey    = "key %q of %s %q is ambiguous: keys may not start or end with a directory separator, or contain more than two consecutive underscores"
 	errWriteBackend    = "cannot write tofu configuration " + tfBackendFile
+	errWriteImports    = "cannot write tofu configuration " + tfImports
 	errInit            = "cannot initialize tofu configuration"
 	errWorkspace       = "cannot select tofu workspace"
 	errResources       = "cannot list tofu resources"
@@ -63,22 +98,45 @@ const (
 	errOutputs         = "cannot list tofu outputs"
 	errOptions         = "cannot determine tofu options"
 	errApply           = "cannot apply tofu configuration"
+	errApprovedStale   = "approved plan is stale - it will be observed again"
 	errDestroy         = "cannot destroy tofu configuration"
+	errForceUnlock     = "cannot force unlock stale tofu state lock"
 	errVarFile         = "cannot get tfvars"
 	errVarMap          = "cannot get tfvars from var map"
 	errVarResolution   = "cannot resolve variables"
+	errVarName         = "invalid variable name %q"
+	errVarJSON         = "variable %q is not valid JSON"
 	errDeleteWorkspace = "cannot delete tofu workspace"
 	errChecksum        = "cannot calculate workspace checksum"
-
-	gitCredentialsFilename = ".git-credentials"
+	errConnection      = "cannot get connection details"
+	errPublishOutputs  = "cannot publish outputs to ConfigMap"
+	errNotControlled   = "refusing to overwrite a ConfigMap that is not controlled by this Workspace"
+
+	// registryCredentialsFilename is the filename of the ProviderConfig
+	// credentials that are used to pull OCI modules. They must be a docker
+	// config JSON, like the key of a kubernetes.io/dockerconfigjson Secret.
+	registryCredentialsFilename = ".dockerconfigjson"
 )
 
 const (
-	tofuPath      = "tofu"
 	tfMain        = "main.tf"
 	tfMainJSON    = "main.tf.json"
 	tfConfig      = "crossplane-provider-config.tf"
 	tfBackendFile = "crossplane.remote.tfbackend"
+	tfImports     = "crossplane-imports.tf.json"
+
+	// moduleFilesManifest lists the inline or referenced module files
+	// written to a workspace directory, so that they can be removed when
+	// they're no longer supplied.
+	moduleFilesManifest = ".crossplane-module-files"
+
+	// modulePathSeparator separates directories in the keys of ConfigMaps
+	// and Secrets that contain module files.
+	modulePathSeparator = "__"
+
+	// modulesDir is the directory within tfDir in which remote modules are
+	// cached. It's not a UUID, so the workdir garbage collector ignores it.
+	modulesDir = "modules"
 )
 
 func envVarFallback(envvar string, fallback string) string {
@@ -90,43 +148,71 @@ func envVarFallback(envvar string, fallback string) string {
 
 var tfDir = envVarFallback("XP_TF_DIR", "/tofu")
 
+// varName matches valid tofu variable names.
+var varName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// tofuVersions resolves tofu versions to tofu binaries, reports the version of
+// each binary, and records the versions that run workspaces.
+type tofuVersions interface {
+	Resolve(ctx context.Context, version string) (path, resolved string, err error)
+	Record(version, platform string)
+	Version(ctx context.Context, path string, fn func(ctx context.Context) (*opentofu.Version, error)) (*opentofu.Version, error)
+}
+
+// A moduleCache gets remote modules.
+type moduleCache interface {
+	Get(ctx context.Context, src, dst string, env []string, digest string) (string, error)
+	GetArtifact(ctx context.Context, ref string, kc oci.Keychain, dst string) (string, error)
+}
+
 type tofuclient interface {
+	Version(ctx context.Context) (*opentofu.Version, error)
 	Init(ctx context.Context, o ...opentofu.InitOption) error
 	Workspace(ctx context.Context, name string) error
 	Outputs(ctx context.Context) ([]opentofu.Output, error)
 	Resources(ctx context.Context) ([]string, error)
-	Diff(ctx context.Context, o ...opentofu.Option) (bool, error)
+	Plan(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error)
+	RefreshOnlyPlan(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error)
 	Apply(ctx context.Context, o ...opentofu.Option) error
+	ApplyPlan(ctx context.Context, o ...opentofu.Option) error
 	Destroy(ctx context.Context, o ...opentofu.Option) error
 	DeleteCurrentWorkspace(ctx context.Context) error
 	GenerateChecksum(ctx context.Context) (string, error)
+	ForceUnlock(ctx context.Context, id string) error
 }
 
 // Setup adds a controller that reconciles Workspace managed resources.
-func Setup(mgr ctrl.Manager, o controller.Options, timeout, pollJitter time.Duration) error {
+func Setup(mgr ctrl.Manager, o controller.Options, timeout, pollJitter, moduleRefresh time.Duration, versions *tofuversion.Installer) error {
 	name := managed.ControllerName(v1beta1.WorkspaceGroupKind)
 
 	fs := afero.Afero{Fs: afero.NewOsFs()}
 	gcWorkspace := workdir.NewGarbageCollector(mgr.GetClient(), tfDir, workdir.WithFs(fs), workdir.WithLogger(o.Logger))
 	go gcWorkspace.Run(context.TODO(), false)
 
+	modules := modcache.New(filepath.Join(tfDir, modulesDir), modcache.WithFs(fs), modcache.WithRefreshInterval(moduleRefresh), modcache.WithLogger(o.Logger))
+	go modules.Run(context.TODO())
+
 	gcTmp := workdir.NewGarbageCollector(mgr.GetClient(), filepath.Join("/tmp", tfDir), workdir.WithFs(fs), workdir.WithLogger(o.Logger))
 	go gcTmp.Run(context.TODO(), false)
 
 	c := &connector{
-		kube:   mgr.GetClient(),
-		usage:  resource.NewLegacyProviderConfigUsageTracker(mgr.GetClient(), &v1beta1.ProviderConfigUsage{}),
-		logger: o.Logger,
-		fs:     fs,
-		tofu: func(dir string, usePluginCache bool, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient {
-			return opentofu.Harness{Path: tofuPath, Dir: dir, UsePluginCache: usePluginCache, EnableTofuCLILogging: enableTofuCLILogging, Logger: logger, Envs: envs}
+		kube:               mgr.GetClient(),
+		usage:              resource.NewLegacyProviderConfigUsageTracker(mgr.GetClient(), &v1beta1.ProviderConfigUsage{}),
+		logger:             o.Logger,
+		fs:                 fs,
+		modules:            modules,
+		versions:           versions,
+		managementPolicies: o.Features.Enabled(features.EnableBetaManagementPolicies),
+		tofu: func(path, dir string, usePluginCache bool, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient {
+			return opentofu.Harness{Path: path, Dir: dir, UsePluginCache: usePluginCache, EnableTofuCLILogging: enableTofuCLILogging, Logger: logger, Envs: envs}
 		},
 	}
 
+	f := newFailures()
 	opts := []managed.ReconcilerOption{
 		managed.WithPollInterval(o.PollInterval),
 		managed.WithPollJitterHook(pollJitter),
-		managed.WithExternalConnecter(c),
+		managed.WithExternalConnecter(&failureConnecter{ExternalConnecter: c, failures: f}),
 		managed.WithLogger(o.Logger.WithValues("controller", name)),
 		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
 		managed.WithTimeout(timeout),
@@ -146,19 +232,23 @@ func Setup(mgr ctrl.Manager, o controller.Options, timeout, pollJitter time.Dura
 		resource.ManagedKind(v1beta1.WorkspaceGroupVersionKind),
 		opts...)
 
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := index(mgr); err != nil {
+		return errors.Wrap(err, errIndex)
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
-		WithEventFilter(resource.DesiredStateChanged()).
-		For(&v1beta1.Workspace{}).
-		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+		For(&v1beta1.Workspace{}, builder.WithPredicates(resource.DesiredStateChanged()))
+	return watchReferences(b, mgr.GetClient()).
+		Complete(ratelimiter.NewReconciler(name, &backoffReconciler{Reconciler: r, failures: f, delay: o.PollInterval}, o.GlobalRateLimiter))
 }
 
 // SetupGated adds a controller that reconciles ProviderConfigs by accounting for
 // their current usage.
-func SetupGated(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJitter time.Duration) error {
+func SetupGated(mgr ctrl.Manager, o controller.Options, timeout, pollJitter, moduleRefresh time.Duration, versions *tofuversion.Installer) error {
 	o.Gate.Register(func() {
-		if err := Setup(mgr, o, timeout, pollJitter); err != nil {
+		if err := Setup(mgr, o, timeout, pollJitter, moduleRefresh, versions); err != nil {
 			mgr.GetLogger().Error(err, "unable to setup reconciler", "gvk", v1beta1.WorkspaceGroupVersionKind.String())
 		}
 	}, v1beta1.WorkspaceGroupVersionKind)
@@ -166,11 +256,16 @@ func SetupGated(mgr ctrl.Manager, o controller.Options, timeout time.Duration, p
 }
 
 type connector struct {
-	kube   client.Client
-	usage  clients.LegacyTracker
-	logger logging.Logger
-	fs     afero.Afero
-	tofu   func(dir string, usePluginCache bool, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient
+	kube     client.Client
+	usage    clients.LegacyTracker
+	logger   logging.Logger
+	fs       afero.Afero
+	modules  moduleCache
+	versions tofuVersions
+	tofu     func(path, dir string, usePluginCache bool, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient
+
+	// managementPolicies is true if management policies are enabled.
+	managementPolicies bool
 }
 
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) { //nolint:gocyclo
@@ -198,54 +293,51 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, "failed to resolve provider config")
 	}
 
-	// Make git credentials available to inline and remote sources
-	for _, cd := range pc.Spec.Credentials {
-		if cd.Filename != gitCredentialsFilename {
-			continue
-		}
-		data, err := resource.CommonCredentialExtractor(ctx, cd.Source, c.kube, cd.CommonCredentialSelectors)
-		if err != nil {
-			return nil, errors.Wrap(err, errGetCreds)
-		}
-		// NOTE(bobh66): Put the git credentials file in /tmp/tofu/<UUID> so it doesn't get removed or overwritten
-		// by the remote module source case
-		gitCredDir := filepath.Clean(filepath.Join("/tmp", dir))
-		if err = c.fs.MkdirAll(gitCredDir, 0700); err != nil {
-			return nil, errors.Wrap(err, errWriteGitCreds)
-		}
+	if err := checkModuleSource(pc.Spec, cr.Spec.ForProvider, dir); err != nil {
+		cr.Status.SetConditions(v1beta1.ModuleSourceDenied(err.Error()))
+		return nil, errors.Wrap(err, errModuleSource)
+	}
+	cond := v1beta1.ModuleSourceUnrestricted()
+	if restrictsModuleSources(pc.Spec) {
+		cond = v1beta1.ModuleSourceAllowed()
+	}
+	cr.Status.SetConditions(cond)
 
-		// NOTE(ytsarev): Make go-getter pick up .git-credentials, see /.gitconfig in the container image
-		err = os.Setenv("GIT_CRED_DIR", gitCredDir)
-		if err != nil {
-			return nil, errors.Wrap(err, errSetGitCredDir)
-		}
-		p := filepath.Clean(filepath.Join(gitCredDir, filepath.Base(cd.Filename)))
-		if err := c.fs.WriteFile(p, data, 0600); err != nil {
-			return nil, errors.Wrap(err, errWriteGitCreds)
-		}
+	// NOTE(bobh66): Put the git credentials in /tmp/tofu/<UUID> so they don't
+	// get removed or overwritten by the remote module source case.
+	gitEnv, gitCreds, err := c.gitEnv(ctx, pc.Spec, filepath.Clean(filepath.Join("/tmp", dir)))
+	if err != nil {
+		return nil, err
 	}
 
+	revision := ""
 	switch cr.Spec.ForProvider.Source {
 	case v1beta1.ModuleSourceRemote:
-		gc := getter.Client{
-			Src: cr.Spec.ForProvider.Module,
-			Dst: dir,
-			Pwd: dir,
-
-			Mode: getter.ClientModeDir,
+		if revision, err = c.modules.Get(ctx, cr.Spec.ForProvider.Module, dir, gitEnv, gitCreds); err != nil {
+			return nil, errors.Wrap(err, errRemoteModule)
 		}
-		err := gc.Get()
+
+	case v1beta1.ModuleSourceOCI:
+		kc, err := c.registryCredentials(ctx, pc.Spec.Credentials)
 		if err != nil {
-			return nil, errors.Wrap(err, errRemoteModule)
+			return nil, errors.Wrap(err, errRegistryCreds)
+		}
+		if revision, err = c.modules.GetArtifact(ctx, cr.Spec.ForProvider.Module, kc, dir); err != nil {
+			return nil, errors.Wrap(err, errOCIModule)
 		}
 
 	case v1beta1.ModuleSourceInline:
-		fn := tfMain
-		if cr.Spec.ForProvider.InlineFormat == v1beta1.FileFormatJSON {
-			fn = tfMainJSON
+		if err := writeInlineModule(c.fs, dir, cr.Spec.ForProvider); err != nil {
+			return nil, err
+		}
+
+	case v1beta1.ModuleSourceConfigMap:
+		files, err := c.moduleFiles(ctx, cr.Spec.ForProvider.ModuleRefs)
+		if err != nil {
+			return nil, errors.Wrap(err, errModuleRefs)
 		}
-		if err := c.fs.WriteFile(filepath.Join(dir, fn), []byte(cr.Spec.ForProvider.Module), 0600); err != nil {
-			return nil, errors.Wrap(err, errWriteMain+fn)
+		if err := writeModuleFiles(c.fs, dir, files); err != nil {
+			return nil, errors.Wrap(err, errModuleRefs)
 		}
 	}
 
@@ -271,6 +363,10 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		}
 	}
 
+	if err := writeImports(c.fs, dir, cr.Spec.ForProvider.Imports); err != nil {
+		return nil, errors.Wrap(err, errWriteImports)
+	}
+
 	if pc.Spec.BackendFile != nil {
 		if err := c.fs.WriteFile(filepath.Join(dir, tfBackendFile), []byte(*pc.Spec.BackendFile), 0600); err != nil {
 			return nil, errors.Wrap(err, errWriteBackend)
@@ -314,16 +410,44 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		}
 		envs[idx] = strings.Join([]string{env.Name, runtimeVal}, "=")
 	}
+	// tofu init runs git to get remote modules that the module calls.
+	envs = append(envs, gitEnv...)
+
+	v := cr.Spec.ForProvider.TofuVersion
+	if v == "" {
+		v = pc.Spec.TofuVersion
+	}
+	path, resolved, err := c.versions.Resolve(ctx, v)
+	if err != nil {
+		return nil, errors.Wrap(err, errTofuVersion)
+	}
 
-	tofu := c.tofu(dir, *pc.Spec.PluginCache, cr.Spec.ForProvider.EnableTofuCLILogging, l, envs...)
-	if cr.Status.AtProvider.Checksum != "" {
+	tofu := c.tofu(path, dir, *pc.Spec.PluginCache, cr.Spec.ForProvider.EnableTofuCLILogging, l, envs...)
+	version, err := c.versions.Version(ctx, path, tofu.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, errVersion)
+	}
+	if resolved != "" && version.Version != resolved {
+		return nil, errors.Wrap(errors.Errorf(errFmtVersion, path, version.Version, resolved), errTofuVersion)
+	}
+	c.versions.Record(version.Version, version.Platform)
+	if err := version.CheckSupported(); err != nil {
+		cr.Status.SetConditions(v1beta1.TofuVersionUnsupported(err.Error()))
+		return nil, errors.Wrap(err, errTofuVersion)
+	}
+	cr.Status.SetConditions(v1beta1.TofuVersionSupported(version.Version))
+
+	observeOnly := managed.NewManagementPoliciesResolver(c.managementPolicies, cr.GetManagementPolicies()).ShouldOnlyObserve()
+
+	// The workspace must be initialized again if its tofu version changed.
+	if cr.Status.AtProvider.Checksum != "" && cr.Status.AtProvider.TofuVersion == version.Version {
 		checksum, err := tofu.GenerateChecksum(ctx)
 		if err != nil {
 			return nil, errors.Wrap(err, errChecksum)
 		}
 		if cr.Status.AtProvider.Checksum == checksum {
 			l.Debug("Checksums match - skip running tofu init")
-			return &external{tofu: tofu, kube: c.kube, logger: c.logger}, errors.Wrap(tofu.Workspace(ctx, meta.GetExternalName(cr)), errWorkspace)
+			return &external{tofu: tofu, kube: c.kube, logger: c.logger, revision: revision, version: *version, stateLock: pc.Spec.StateLock, observeOnly: observeOnly}, errors.Wrap(tofu.Workspace(ctx, meta.GetExternalName(cr)), errWorkspace)
 		}
 		l.Debug("Checksums don't match so run tofu init:", "old", cr.Status.AtProvider.Checksum, "new", checksum)
 	}
@@ -336,32 +460,164 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 	if err := tofu.Init(ctx, o...); err != nil {
 		return nil, errors.Wrap(err, errInit)
 	}
-	return &external{tofu: tofu, kube: c.kube}, errors.Wrap(tofu.Workspace(ctx, meta.GetExternalName(cr)), errWorkspace)
+	return &external{tofu: tofu, kube: c.kube, logger: c.logger, revision: revision, version: *version, stateLock: pc.Spec.StateLock, observeOnly: observeOnly}, errors.Wrap(tofu.Workspace(ctx, meta.GetExternalName(cr)), errWorkspace)
 }
 
 type external struct {
 	tofu   tofuclient
 	kube   client.Client
 	logger logging.Logger
+
+	// planKey identifies the inputs of the plan Observe saved to the
+	// workspace directory. Update applies that plan only if it is unchanged.
+	planKey string
+
+	// observed is the plan Observe saved to the workspace directory.
+	observed *opentofu.Plan
+
+	// revision of the remote module in the workspace directory.
+	revision string
+
+	// version of the tofu binary that runs the workspace. Features that only
+	// some versions of tofu support should be gated on it.
+	version opentofu.Version
+
+	// observeOnly workspaces are planned in refresh-only mode, so that tofu
+	// never proposes changes to match their configuration.
+	observeOnly bool
+
+	// stateLock configures how state locks are handled, if at all.
+	stateLock *namespacedv1beta1.StateLockPolicy
+}
+
+// lockOptions returns the options that configure how long tofu waits to
+// acquire the state lock.
+func (c *external) lockOptions() []opentofu.Option {
+	if c.stateLock == nil || c.stateLock.Timeout == nil {
+		return nil
+	}
+	return []opentofu.Option{opentofu.WithLockTimeout(c.stateLock.Timeout.Duration)}
+}
+
+// stale returns true if the supplied lock was acquired by this provider longer
+// ago than the state lock policy allows, and may thus be forced.
+func (c *external) stale(l *opentofu.LockInfo) bool {
+	if c.stateLock == nil || c.stateLock.ForceUnlockAfter == nil || l.Created.IsZero() {
+		return false
+	}
+	return time.Since(l.Created) > c.stateLock.ForceUnlockAfter.Duration && l.HeldBy(opentofu.LockHolder())
 }
 
-func (c *external) checkDiff(ctx context.Context, cr *v1beta1.Workspace) (bool, error) {
-	o, err := c.options(ctx, cr.Spec.ForProvider)
+// locked runs the supplied tofu operation, recording the lock in the
+// workspace's status if the state is locked. A stale lock is forced and the
+// operation retried once.
+func (c *external) locked(ctx context.Context, cr *v1beta1.Workspace, fn func() error) error {
+	err := fn()
+	l := opentofu.LockOf(err)
+	if l == nil || !c.stale(l) {
+		cr.Status.AtProvider.StateLock = stateLock(l)
+		return err
+	}
+	c.logger.Info("Forcing unlock of stale state lock", "request", cr.GetName(), "id", l.ID, "holder", l.Who, "created", l.Created)
+	if err := c.tofu.ForceUnlock(ctx, l.ID); err != nil {
+		cr.Status.AtProvider.StateLock = stateLock(l)
+		return errors.Wrap(err, errForceUnlock)
+	}
+	err = fn()
+	cr.Status.AtProvider.StateLock = stateLock(opentofu.LockOf(err))
+	return err
+}
+
+// stateLock returns the status of the supplied lock.
+func stateLock(l *opentofu.LockInfo) *v1beta1.StateLock {
+	if l == nil {
+		return nil
+	}
+	sl := &v1beta1.StateLock{ID: l.ID, Holder: l.Who, Operation: l.Operation}
+	if !l.Created.IsZero() {
+		sl.Created = &metav1.Time{Time: l.Created}
+	}
+	return sl
+}
+
+// planKey ties a saved plan to the workspace checksum and the resolved tofu
+// options (i.e. variables and arguments) it was created with.
+func planKey(checksum string, o ...opentofu.Option) string {
+	return checksum + "/" + opentofu.Digest(o...)
+}
+
+// plan the workspace's configuration. It returns a nil plan if planning fails
+// for a workspace that is being deleted, along with the options that were
+// used to plan.
+func (c *external) plan(ctx context.Context, cr *v1beta1.Workspace) (*opentofu.Plan, []opentofu.Option, error) {
+	o, err := c.options(ctx, cr)
 	if err != nil {
-		return false, errors.Wrap(err, errOptions)
+		return nil, nil, errors.Wrap(err, errOptions)
 	}
 
 	o = append(o, opentofu.WithArgs(cr.Spec.ForProvider.PlanArgs))
-	differs, err := c.tofu.Diff(ctx, o...)
+	plan := c.tofu.Plan
+	if c.observeOnly {
+		plan = c.tofu.RefreshOnlyPlan
+	}
+	p, err := plan(ctx, o...)
 	if err != nil {
 		if !meta.WasDeleted(cr) {
-			return false, errors.Wrap(err, errDiff)
+			return nil, nil, errors.Wrap(err, errDiff)
 		}
 		// tofu plan can fail on deleted resources, so let the reconciliation loop
 		// call Delete() if there are still resources in the tfstate file
-		differs = false
+		return nil, nil, nil
+	}
+	return p, o, nil
+}
+
+// requiresApproval returns true if the supplied plan may not be applied
+// without approval under the supplied policy.
+func requiresApproval(policy *v1beta1.ApprovalPolicy, p *opentofu.Plan) bool {
+	if policy == nil {
+		return false
 	}
-	return differs, nil
+	switch *policy {
+	case v1beta1.ApprovalPolicyAlways:
+		return p.HasChanges()
+	case v1beta1.ApprovalPolicyRequireForDestroy:
+		return p.Summary().Destroy > 0
+	case v1beta1.ApprovalPolicyAuto:
+	}
+	return false
+}
+
+// clearPendingApproval resets the PendingApproval condition of a Workspace
+// whose plan no longer requires approval, e.g. because its approval policy
+// changed or because there is nothing left to apply.
+func clearPendingApproval(cr *v1beta1.Workspace) {
+	if cr.GetCondition(v1beta1.TypePendingApproval).Status == corev1.ConditionTrue {
+		cr.Status.SetConditions(v1beta1.ApprovalNotRequired())
+	}
+}
+
+// apply the plan saved by Observe if it is still current. The workspace is
+// planned again from scratch if the saved plan is stale, or if apply arguments
+// are set, because tofu does not accept planning options when applying a saved
+// plan. An approved plan is always applied as saved, and never planned again
+// because the new plan would not have been approved.
+func (c *external) apply(ctx context.Context, cr *v1beta1.Workspace, o []opentofu.Option, approved bool) error {
+	if c.planKey != "" && (len(cr.Spec.ForProvider.ApplyArgs) == 0 || approved) {
+		checksum, err := c.tofu.GenerateChecksum(ctx)
+		if err != nil {
+			return errors.Wrap(err, errChecksum)
+		}
+		if planKey(checksum, append(o, opentofu.WithArgs(cr.Spec.ForProvider.PlanArgs))...) == c.planKey {
+			return c.tofu.ApplyPlan(ctx, o...)
+		}
+	}
+	if approved {
+		return errors.New(errApprovedStale)
+	}
+	c.logger.Debug("Saved plan cannot be applied - planning again", "request", cr.GetName())
+
+	return c.tofu.Apply(ctx, append(o, opentofu.WithArgs(cr.Spec.ForProvider.ApplyArgs))...)
 }
 
 func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -370,10 +626,19 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotWorkspace)
 	}
 
-	differs, err := c.checkDiff(ctx, cr)
+	p, po, err := c.plan(ctx, cr)
+	if we := (&waitingError{}); errors.As(err, &we) {
+		cr.Status.SetConditions(v1beta1.WaitingForWorkspace(we.Error()))
+	}
 	if err != nil {
+		cr.Status.AtProvider.Imports = failedImports(cr.Spec.ForProvider.Imports, cr.Status.AtProvider.Imports, err)
 		return managed.ExternalObservation{}, err
 	}
+	if hasWorkspaceOutputRefs(cr.Spec.ForProvider) {
+		cr.Status.SetConditions(v1beta1.ReferencesResolved())
+	}
+	differs := p != nil && p.HasChanges()
+
 	r, err := c.tofu.Resources(ctx)
 	if err != nil {
 		return managed.ExternalObservation{}, errors.Wrap(err, errResources)
@@ -389,26 +654,55 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 	if err != nil {
 		return managed.ExternalObservation{}, errors.Wrap(err, errOutputs)
 	}
+	applied := cr.Status.AtProvider.AppliedModuleRevision
+	if p != nil && !differs && !c.observeOnly {
+		// The workspace is up to date, so the module at the current revision
+		// is applied even if it was never applied by Update, e.g. because a
+		// new revision didn't change anything.
+		applied = c.revision
+	}
 	cr.Status.AtProvider = generateWorkspaceObservation(op)
+	cr.Status.AtProvider.ModuleRevision = c.revision
+	cr.Status.AtProvider.AppliedModuleRevision = applied
+	cr.Status.AtProvider.TofuVersion = c.version.Version
+	cr.Status.AtProvider.Imports = imported(cr.Spec.ForProvider.Imports, inState(r))
+	if err := c.publishOutputs(ctx, cr, op); err != nil {
+		return managed.ExternalObservation{}, err
+	}
 
 	checksum, err := c.tofu.GenerateChecksum(ctx)
 	if err != nil {
 		return managed.ExternalObservation{}, errors.Wrap(err, errChecksum)
 	}
+	cd, err := op2cd(cr.Spec.ForProvider.ConnectionDetails, op)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errConnection)
+	}
+
 	cr.Status.AtProvider.Checksum = checksum
+	if p != nil {
+		cr.Status.AtProvider.PlanSummary = p.Summary().String()
+		var dc xpv1.Condition
+		cr.Status.AtProvider.Drift, dc = drift(p)
+		cr.Status.SetConditions(dc)
+	}
+	// A refresh-only plan only updates the state, so Update must never apply
+	// it.
+	if p != nil && !c.observeOnly {
+		c.planKey = planKey(checksum, po...)
+		c.observed = p
+	}
 
 	if !differs {
-		// TODO(negz): Allow Workspaces to optionally derive their readiness from an
-		// output - similar to the logic XRs use to derive readiness from a field of
-		// a composed resource.
-		cr.Status.SetConditions(xpv1.Available())
+		cr.Status.SetConditions(readiness(cr.Spec.ForProvider.ReadinessCheck, op))
+		clearPendingApproval(cr)
 	}
 
 	return managed.ExternalObservation{
 		ResourceExists:          len(r)+len(op) > 0,
 		ResourceUpToDate:        !differs,
 		ResourceLateInitialized: false,
-		ConnectionDetails:       op2cd(op),
+		ConnectionDetails:       cd,
 	}, nil
 }
 
@@ -424,29 +718,55 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, errors.New(errNotWorkspace)
 	}
 
-	o, err := c.options(ctx, cr.Spec.ForProvider)
+	o, err := c.options(ctx, cr)
 	if err != nil {
 		return managed.ExternalUpdate{}, errors.Wrap(err, errOptions)
 	}
 
-	o = append(o, opentofu.WithArgs(cr.Spec.ForProvider.ApplyArgs))
-	if err := c.tofu.Apply(ctx, o...); err != nil {
+	approved := false
+	if c.observed != nil && requiresApproval(cr.Spec.ForProvider.Approval, c.observed) {
+		h := c.observed.Hash()
+		if cr.GetAnnotations()[v1beta1.AnnotationKeyApprovedPlan] != h {
+			c.logger.Debug("Plan requires approval - not applying", "request", cr.GetName(), "hash", h)
+			cr.Status.SetConditions(v1beta1.PendingApproval(h, c.observed.Summary().String()))
+			return managed.ExternalUpdate{}, nil
+		}
+		approved = true
+	}
+	if !approved {
+		clearPendingApproval(cr)
+	}
+
+	o = append(o, c.lockOptions()...)
+	if err := c.locked(ctx, cr, func() error { return c.apply(ctx, cr, o, approved) }); err != nil {
 		return managed.ExternalUpdate{}, errors.Wrap(err, errApply)
 	}
+	if approved {
+		cr.Status.SetConditions(v1beta1.PlanApproved(c.observed.Hash()))
+	}
 
 	op, err := c.tofu.Outputs(ctx)
 	if err != nil {
 		return managed.ExternalUpdate{}, errors.Wrap(err, errOutputs)
 	}
+	cd, err := op2cd(cr.Spec.ForProvider.ConnectionDetails, op)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errConnection)
+	}
 	cr.Status.AtProvider = generateWorkspaceObservation(op)
-	// TODO(negz): Allow Workspaces to optionally derive their readiness from an
-	// output - similar to the logic XRs use to derive readiness from a field of
-	// a composed resource.
-	// Note that since Create() calls this function the Reconciler will overwrite this Available condition with Creating
-	// on the first pass and it will get reset to Available() by Observe() on the next pass if there are no differences.
+	cr.Status.AtProvider.ModuleRevision = c.revision
+	cr.Status.AtProvider.AppliedModuleRevision = c.revision
+	cr.Status.AtProvider.TofuVersion = c.version.Version
+	// Every import is in the state once the workspace has been applied.
+	cr.Status.AtProvider.Imports = imported(cr.Spec.ForProvider.Imports, func(string) bool { return true })
+	if err := c.publishOutputs(ctx, cr, op); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	// Note that since Create() calls this function the Reconciler will overwrite this Ready condition with Creating
+	// on the first pass and it will get reset by Observe() on the next pass if there are no differences.
 	// Leave this call for the Update() case.
-	cr.Status.SetConditions(xpv1.Available())
-	return managed.ExternalUpdate{ConnectionDetails: op2cd(op)}, nil
+	cr.Status.SetConditions(readiness(cr.Spec.ForProvider.ReadinessCheck, op))
+	return managed.ExternalUpdate{ConnectionDetails: cd}, nil
 }
 
 func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.ExternalDelete, error) {
@@ -455,13 +775,15 @@ func (c *external) Delete(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalDelete{}, errors.New(errNotWorkspace)
 	}
 
-	o, err := c.options(ctx, cr.Spec.ForProvider)
+	o, err := c.options(ctx, cr)
 	if err != nil {
 		return managed.ExternalDelete{}, errors.Wrap(err, errOptions)
 	}
 
+	o = append(o, c.lockOptions()...)
 	o = append(o, opentofu.WithArgs(cr.Spec.ForProvider.DestroyArgs))
-	return managed.ExternalDelete{}, errors.Wrap(c.tofu.Destroy(ctx, o...), errDestroy)
+	err = c.locked(ctx, cr, func() error { return c.tofu.Destroy(ctx, o...) })
+	return managed.ExternalDelete{}, errors.Wrap(err, errDestroy)
 }
 
 func (c *external) Disconnect(ctx context.Context) error {
@@ -469,11 +791,61 @@ func (c *external) Disconnect(ctx context.Context) error {
 }
 
 //nolint:gocyclo
-func (c *external) options(ctx context.Context, p v1beta1.WorkspaceParameters) ([]opentofu.Option, error) {
+func (c *external) options(ctx context.Context, cr *v1beta1.Workspace) ([]opentofu.Option, error) {
+	p := cr.Spec.ForProvider
 	o := make([]opentofu.Option, 0, len(p.Vars)+len(p.VarFiles)+len(p.DestroyArgs)+len(p.ApplyArgs)+len(p.PlanArgs))
 
+	jv := map[string]json.RawMessage{}
+	hv := map[string]string{}
+	sv := map[string]string{}
 	for _, v := range p.Vars {
-		o = append(o, opentofu.WithVar(v.Key, v.Value))
+		switch {
+		case v.ValueFrom != nil && v.ValueFrom.WorkspaceOutputReference != nil:
+			out, err := c.workspaceOutput(ctx, v.ValueFrom.WorkspaceOutputReference, meta.WasDeleted(cr))
+			if we := (&waitingError{}); errors.As(err, &we) && meta.WasDeleted(cr) {
+				// Don't block the deletion of a Workspace on a Workspace it
+				// references that may never become ready again. Destroying
+				// usually doesn't depend on the variable's value.
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, errVarResolution)
+			}
+			jv[v.Key] = out
+		case v.ValueFrom != nil:
+			val, sensitive, err := c.varValue(ctx, v.ValueFrom)
+			if err != nil {
+				return nil, errors.Wrap(err, errVarResolution)
+			}
+			if sensitive {
+				sv[v.Key] = val
+				continue
+			}
+			jv[v.Key], _ = json.Marshal(val) //nolint:errchkjson // Marshalling a string cannot fail.
+		case v.HCLValue != "":
+			if !varName.MatchString(v.Key) {
+				return nil, errors.Errorf(errVarName, v.Key)
+			}
+			hv[v.Key] = v.HCLValue
+		case v.JSONValue != nil:
+			if !json.Valid(v.JSONValue.Raw) {
+				return nil, errors.Errorf(errVarJSON, v.Key)
+			}
+			jv[v.Key] = v.JSONValue.Raw
+		default:
+			// Plain values are passed on the command line, where tofu parses
+			// them as HCL if the variable's type isn't a string.
+			o = append(o, opentofu.WithVar(v.Key, v.Value))
+		}
+	}
+	if len(jv) > 0 {
+		o = append(o, opentofu.WithJSONVars(jv))
+	}
+	if len(hv) > 0 {
+		o = append(o, opentofu.WithHCLVars(hv))
+	}
+	if len(sv) > 0 {
+		o = append(o, opentofu.WithSensitiveVars(sv))
 	}
 
 	for _, vf := range p.VarFiles {
@@ -514,18 +886,654 @@ func (c *external) options(ctx context.Context, p v1beta1.WorkspaceParameters) (
 	return o, nil
 }
 
-func op2cd(o []opentofu.Output) managed.ConnectionDetails {
+// writeImports writes the supplied imports to the supplied directory as tofu
+// import blocks, or removes the imports file if there are none.
+func writeImports(fs afero.Afero, dir string, imports []v1beta1.Import) error {
+	p := filepath.Join(dir, tfImports)
+	if len(imports) == 0 {
+		return resource.Ignore(os.IsNotExist, fs.Remove(p))
+	}
+	data, err := importBlocks(imports)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(p, data, 0600)
+}
+
+// importBlocks renders the supplied imports as tofu import blocks, in tofu's
+// JSON configuration syntax.
+func importBlocks(imports []v1beta1.Import) ([]byte, error) {
+	type block struct {
+		To       string `json:"to"`
+		ID       string `json:"id"`
+		Provider string `json:"provider,omitempty"`
+	}
+	// IDs are string templates in JSON syntax, so any template sequences in
+	// them must be escaped.
+	literal := strings.NewReplacer("${", "$${", "%{", "%%{")
+	blocks := make([]block, len(imports))
+	for i, imp := range imports {
+		blocks[i] = block{To: imp.Address, ID: literal.Replace(imp.ID), Provider: imp.Provider}
+	}
+	return json.Marshal(map[string][]block{"import": blocks})
+}
+
+// inState returns a function that returns true if the supplied address is one
+// of the supplied addresses of the resources in a workspace's state.
+func inState(state []string) func(address string) bool {
+	in := make(map[string]bool, len(state))
+	for _, a := range state {
+		in[a] = true
+	}
+	return func(address string) bool { return in[address] }
+}
+
+// imported returns the status of the supplied imports. An import is imported
+// once its resource is in the state, and pending until then.
+func imported(imports []v1beta1.Import, inState func(address string) bool) []v1beta1.ImportStatus {
+	if len(imports) == 0 {
+		return nil
+	}
+	s := make([]v1beta1.ImportStatus, len(imports))
+	for i, imp := range imports {
+		s[i] = v1beta1.ImportStatus{Address: imp.Address, ID: imp.ID, Result: v1beta1.ImportResultPending}
+		if inState(imp.Address) {
+			s[i].Result = v1beta1.ImportResultImported
+		}
+	}
+	return s
+}
+
+// failedImports returns the status of the supplied imports when planning them
+// failed with the supplied error. Imports the error's diagnostics concern
+// failed. Other imports keep their previous result if they were imported, and
+// are pending otherwise.
+func failedImports(imports []v1beta1.Import, previous []v1beta1.ImportStatus, err error) []v1beta1.ImportStatus {
+	was := make(map[string]v1beta1.ImportStatus, len(previous))
+	for _, s := range previous {
+		was[s.Address] = s
+	}
+	de := &opentofu.DiagnosticsError{}
+	errors.As(err, &de)
+
+	s := imported(imports, func(string) bool { return false })
+	for i := range s {
+		if w := was[s[i].Address]; w.ID == s[i].ID && w.Result == v1beta1.ImportResultImported {
+			s[i].Result = v1beta1.ImportResultImported
+		}
+		for _, d := range de.Errors() {
+			if d.Address == s[i].Address || strings.Contains(d.Summary+"\n"+d.Detail, s[i].Address) {
+				s[i].Result = v1beta1.ImportResultFailed
+				s[i].Message = d.String()
+				break
+			}
+		}
+	}
+	return s
+}
+
+// drift returns the resources that the supplied plan found changed outside of
+// tofu, and the Drifted condition they imply.
+func drift(p *opentofu.Plan) ([]v1beta1.DriftedResource, xpv1.Condition) {
+	d := p.Drift()
+	if len(d) == 0 {
+		return nil, v1beta1.NotDrifted()
+	}
+	dr := make([]v1beta1.DriftedResource, len(d))
+	addrs := make([]string, len(d))
+	for i := range d {
+		dr[i] = v1beta1.DriftedResource{Address: d[i].Address, Deleted: d[i].Deleted, Attributes: d[i].Attributes}
+		addrs[i] = d[i].Address
+	}
+	return dr, v1beta1.Drifted("resources changed outside of tofu: " + strings.Join(addrs, ", "))
+}
+
+// readiness returns the Ready condition of an up-to-date workspace with the
+// supplied outputs. The workspace is available unless the readiness check
+// fails.
+func readiness(rc *v1beta1.ReadinessCheck, op []opentofu.Output) xpv1.Condition {
+	if rc == nil {
+		return xpv1.Available()
+	}
+	for _, o := range op {
+		if o.Name != rc.OutputName {
+			continue
+		}
+		switch rc.Type {
+		case v1beta1.ReadinessCheckTypeMatchTrue:
+			if o.Value() != true {
+				return xpv1.Unavailable().WithMessage(fmt.Sprintf("output %q is not true", o.Name))
+			}
+		case v1beta1.ReadinessCheckTypeMatchValue:
+			v := o.StringValue()
+			if o.Type != opentofu.OutputTypeString {
+				j, err := o.JSONValue()
+				if err != nil {
+					return xpv1.Unavailable().WithMessage(fmt.Sprintf("cannot encode output %q as JSON: %s", o.Name, err))
+				}
+				v = string(j)
+			}
+			if v != rc.Value {
+				return xpv1.Unavailable().WithMessage(fmt.Sprintf("output %q does not equal %q", o.Name, rc.Value))
+			}
+		case v1beta1.ReadinessCheckTypeNonEmpty, "":
+			if isEmpty(o.Value()) {
+				return xpv1.Unavailable().WithMessage(fmt.Sprintf("output %q is empty", o.Name))
+			}
+		}
+		return xpv1.Available()
+	}
+	return xpv1.Unavailable().WithMessage(fmt.Sprintf("output %q does not exist", rc.OutputName))
+}
+
+func isEmpty(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+// A waitingError indicates that a Workspace is waiting for another Workspace
+// it references to exist, to become ready, or to produce the referenced output.
+type waitingError struct {
+	msg string
+}
+
+func (e *waitingError) Error() string {
+	return e.msg
+}
+
+// workspaceOutput returns the JSON value of an output of another Workspace.
+// It returns a *waitingError if the Workspace is not ready or does not have
+// the output. The last output the Workspace reported is returned regardless of
+// its readiness when the referencing Workspace is being deleted.
+func (c *external) workspaceOutput(ctx context.Context, r *v1beta1.WorkspaceOutputReference, deleting bool) (json.RawMessage, error) {
+	ws := &v1beta1.Workspace{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: "", Name: r.Name}, ws); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, &waitingError{msg: fmt.Sprintf("waiting for Workspace %q to exist", r.Name)}
+		}
+		return nil, err
+	}
+	if ws.GetCondition(xpv1.TypeReady).Status != corev1.ConditionTrue && !deleting {
+		return nil, &waitingError{msg: fmt.Sprintf("waiting for Workspace %q to be ready", r.Name)}
+	}
+	out, ok := ws.Status.AtProvider.Outputs[r.Output]
+	if !ok {
+		return nil, &waitingError{msg: fmt.Sprintf("waiting for Workspace %q to have non-sensitive output %q", r.Name, r.Output)}
+	}
+	return out.Raw, nil
+}
+
+// hasWorkspaceOutputRefs returns true if any of the supplied variables are
+// read from the output of another Workspace.
+func hasWorkspaceOutputRefs(p v1beta1.WorkspaceParameters) bool {
+	for _, v := range p.Vars {
+		if v.ValueFrom != nil && v.ValueFrom.WorkspaceOutputReference != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// varValue returns the value of a variable read from a Secret or ConfigMap
+// key, and whether the value is sensitive.
+func (c *external) varValue(ctx context.Context, vs *v1beta1.VarSource) (string, bool, error) {
+	switch {
+	case vs.ConfigMapKeyReference != nil:
+		cm := &corev1.ConfigMap{}
+		r := vs.ConfigMapKeyReference
+		if err := c.kube.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.Name}, cm); err != nil {
+			return "", false, err
+		}
+		v, ok := cm.Data[r.Key]
+		if !ok {
+			return "", false, fmt.Errorf("couldn't find key %v in ConfigMap %v/%v", r.Key, r.Namespace, r.Name)
+		}
+		return v, false, nil
+	case vs.SecretKeyReference != nil:
+		s := &corev1.Secret{}
+		r := vs.SecretKeyReference
+		if err := c.kube.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.Name}, s); err != nil {
+			return "", false, err
+		}
+		v, ok := s.Data[r.Key]
+		if !ok {
+			return "", false, fmt.Errorf("couldn't find key %v in Secret %v/%v", r.Key, r.Namespace, r.Name)
+		}
+		return string(v), true, nil
+	}
+	return "", false, errors.New("valueFrom must specify a configMapKeyRef or secretKeyRef")
+}
+
+// op2cd returns the connection details of the supplied outputs. All outputs
+// are returned when no connection details are specified. Outputs, or values
+// within outputs, that don't exist yet are omitted.
+func op2cd(cds []v1beta1.ConnectionDetail, o []opentofu.Output) (managed.ConnectionDetails, error) {
 	cd := managed.ConnectionDetails{}
+	if len(cds) == 0 {
+		for _, op := range o {
+			if op.Type == opentofu.OutputTypeString {
+				cd[op.Name] = []byte(op.StringValue())
+				continue
+			}
+			if j, err := op.JSONValue(); err == nil {
+				cd[op.Name] = j
+			}
+		}
+		return cd, nil
+	}
+
+	values := make(map[string]any, len(o))
 	for _, op := range o {
-		if op.Type == opentofu.OutputTypeString {
-			cd[op.Name] = []byte(op.StringValue())
+		values[op.Name] = op.Value()
+	}
+	for _, d := range cds {
+		if _, ok := values[d.OutputName]; !ok {
+			continue
+		}
+		// Output names may contain dashes, which field paths only support
+		// within brackets.
+		path := "[" + d.OutputName + "]"
+		switch {
+		case d.FieldPath == "":
+		case strings.HasPrefix(d.FieldPath, "["):
+			path += d.FieldPath
+		default:
+			path += "." + d.FieldPath
+		}
+
+		v, err := fieldpath.Pave(values).GetValue(path)
+		if fieldpath.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get %s", path)
+		}
+		raw, err := rawValue(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot encode %s", path)
+		}
+		key := d.Key
+		if key == "" {
+			key = d.OutputName
+		}
+		if d.Encoding == v1beta1.ConnectionDetailEncodingBase64 {
+			raw = []byte(base64.StdEncoding.EncodeToString(raw))
+		}
+		cd[key] = raw
+	}
+	return cd, nil
+}
+
+// publishOutputs writes the workspace's selected non-sensitive outputs to its
+// outputs ConfigMap, if it has one.
+func (c *external) publishOutputs(ctx context.Context, cr *v1beta1.Workspace, op []opentofu.Output) error {
+	ref := cr.Spec.ForProvider.OutputsConfigMapRef
+	if ref == nil || meta.WasDeleted(cr) {
+		return nil
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Namespace: ref.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, c.kube, cm, func() error {
+		// Don't adopt a ConfigMap that existed before the Workspace, which
+		// may be owned by something else.
+		if cm.ResourceVersion != "" && !metav1.IsControlledBy(cm, cr) {
+			return errors.New(errNotControlled)
+		}
+		cm.Data = op2cm(ref, op)
+		return meta.AddControllerReference(cm, meta.AsController(meta.TypedReferenceTo(cr, v1beta1.WorkspaceGroupVersionKind)))
+	})
+	return errors.Wrap(err, errPublishOutputs)
+}
+
+// op2cm returns the ConfigMap data for the supplied outputs. Sensitive
+// outputs, and keys that are not valid ConfigMap keys, are omitted.
+func op2cm(ref *v1beta1.OutputsConfigMapReference, o []opentofu.Output) map[string]string {
+	selected := make(map[string]bool, len(ref.Outputs))
+	for _, name := range ref.Outputs {
+		selected[name] = true
+	}
+
+	data := map[string]string{}
+	for _, op := range o {
+		if op.Sensitive || (len(selected) > 0 && !selected[op.Name]) {
+			continue
+		}
+		if m, ok := op.Value().(map[string]any); ok && ref.Flatten {
+			flatten(data, op.Name, m)
+			continue
+		}
+		setConfigMapValue(data, op.Name, op.Value())
+	}
+	return data
+}
+
+// flatten writes each field of the supplied object to its own key, named
+// <prefix>.<field>. Nested objects are flattened recursively.
+func flatten(data map[string]string, prefix string, m map[string]any) {
+	for k, v := range m {
+		key := prefix + "." + k
+		if nested, ok := v.(map[string]any); ok {
+			flatten(data, key, nested)
+			continue
+		}
+		setConfigMapValue(data, key, v)
+	}
+}
+
+func setConfigMapValue(data map[string]string, key string, v any) {
+	if len(validation.IsConfigMapKey(key)) > 0 {
+		return
+	}
+	if raw, err := rawValue(v); err == nil {
+		data[key] = string(raw)
+	}
+}
+
+// rawValue returns strings as is, and values of any other type as JSON.
+func rawValue(v any) ([]byte, error) {
+	if s, ok := v.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(v)
+}
+
+// validateInlineFiles returns an error if any of the supplied inline files
+// would be written outside the workspace directory, or are not valid in their
+// format. The main file may only be supplied if there is no inline module.
+func validateInlineFiles(files map[string]v1beta1.InlineFile, main string, module bool) error {
+	for name, f := range files {
+		if !filepath.IsLocal(name) || name != filepath.Clean(name) {
+			return errors.Errorf(errFmtInlinePath, name)
+		}
+		if module && (name == tfMain || name == tfMainJSON) {
+			return errors.Errorf(errFmtInlineMain, name)
+		}
+		format := f.Format
+		if format == nil && strings.HasSuffix(name, ".json") {
+			format = &v1beta1.FileFormatJSON
+		}
+		if format != nil && *format == v1beta1.FileFormatJSON && !json.Valid([]byte(f.Content)) {
+			return errors.Errorf(errFmtInlineJSON, name)
+		}
+	}
+	return nil
+}
+
+// moduleFiles returns the module files contained in the supplied ConfigMaps
+// and Secrets, keyed by their path relative to the root module.
+func (c *connector) moduleFiles(ctx context.Context, refs []v1beta1.ModuleFilesReference) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, ref := range refs {
+		kind := ref.Kind
+		if kind == "" {
+			kind = v1beta1.ModuleFilesKindConfigMap
+		}
+		data := map[string][]byte{}
+		nn := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+		switch kind {
+		case v1beta1.ModuleFilesKindSecret:
+			sc := &corev1.Secret{}
+			if err := c.kube.Get(ctx, nn, sc); err != nil {
+				return nil, errors.Wrapf(err, errFmtModuleRef, kind, nn)
+			}
+			data = sc.Data
+		default:
+			cm := &corev1.ConfigMap{}
+			if err := c.kube.Get(ctx, nn, cm); err != nil {
+				return nil, errors.Wrapf(err, errFmtModuleRef, kind, nn)
+			}
+			for k, v := range cm.BinaryData {
+				data[k] = v
+			}
+			for k, v := range cm.Data {
+				data[k] = []byte(v)
+			}
+		}
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ambiguousKey(k) {
+				return nil, errors.Errorf(errFmtModuleKey, k, kind, nn)
+			}
+			name := filepath.Join(ref.Path, strings.ReplaceAll(k, modulePathSeparator, "/"))
+			if !filepath.IsLocal(name) {
+				return nil, errors.Errorf(errFmtModulePath, name, k, kind, nn)
+			}
+			if _, ok := files[name]; ok {
+				return nil, errors.Errorf(errFmtModuleDup, name)
+			}
+			files[name] = data[k]
+		}
+	}
+	return files, nil
+}
+
+// ambiguousKey returns true if the supplied key of a ConfigMap or Secret that
+// contains module files doesn't identify exactly one path, e.g. a___b could be
+// a/_b or a_/b, and __a could be /a or a.
+func ambiguousKey(k string) bool {
+	return strings.HasPrefix(k, modulePathSeparator) || strings.HasSuffix(k, modulePathSeparator) || strings.Contains(k, modulePathSeparator+"_")
+}
+
+// checkModuleSource returns an error if the supplied ProviderConfig doesn't
+// allow the module of the supplied Workspace. Relative remote modules are
+// relative to the supplied directory.
+func checkModuleSource(pc namespacedv1beta1.ProviderConfigSpec, p v1beta1.WorkspaceParameters, dir string) error {
+	policy := modpolicy.Policy{Allowed: pc.AllowedModuleSources, Denied: pc.DeniedModuleSources}
+	switch p.Source {
+	case v1beta1.ModuleSourceRemote:
+		return policy.CheckRemote(p.Module, dir)
+	case v1beta1.ModuleSourceOCI:
+		return policy.CheckOCI(p.Module)
+	case v1beta1.ModuleSourceInline, v1beta1.ModuleSourceConfigMap:
+		if pc.ForbidInlineModules {
+			return errors.Errorf(errFmtForbidden, p.Source)
+		}
+	}
+	return nil
+}
+
+// restrictsModuleSources returns true if the supplied ProviderConfig restricts
+// the sources of modules.
+func restrictsModuleSources(pc namespacedv1beta1.ProviderConfigSpec) bool {
+	return len(pc.AllowedModuleSources) > 0 || len(pc.DeniedModuleSources) > 0 || pc.ForbidInlineModules
+}
+
+// registryCredentials returns the OCI registry credentials of the supplied
+// ProviderConfig credentials, if any.
+func (c *connector) registryCredentials(ctx context.Context, creds []namespacedv1beta1.ProviderCredentials) (oci.Keychain, error) {
+	for _, cd := range creds {
+		if cd.Filename != registryCredentialsFilename {
+			continue
+		}
+		data, err := resource.CommonCredentialExtractor(ctx, cd.Source, c.kube, cd.CommonCredentialSelectors)
+		if err != nil {
+			return nil, errors.Wrap(err, errGetCreds)
+		}
+		return oci.ParseDockerConfig(data)
+	}
+	return nil, nil
+}
+
+// gitEnv writes git credentials to dir, and returns their environment and digest.
+// SSH deploy keys and a known_hosts file are written alongside any git
+// credentials. The digest covers everything that determines which
+// repositories are accessible, and keys the module cache's resolutions.
+func (c *connector) gitEnv(ctx context.Context, spec namespacedv1beta1.ProviderConfigSpec, dir string) ([]string, string, error) {
+	cfg := gitauth.Config{}
+	h := sha256.New()
+	var key *gitauth.SSHKey
+	for _, cd := range spec.Credentials {
+		switch cd.Filename {
+		case gitauth.CredentialsFilename, gitauth.SSHKeyFilename, gitauth.KnownHostsFilename:
+		default:
 			continue
 		}
-		if j, err := op.JSONValue(); err == nil {
-			cd[op.Name] = j
+		p, err := c.writeGitFile(ctx, h, cd.Source, cd.CommonCredentialSelectors, dir, cd.Filename)
+		if err != nil {
+			return nil, "", err
+		}
+		switch cd.Filename {
+		case gitauth.CredentialsFilename:
+			cfg.CredentialsFile = p
+		case gitauth.SSHKeyFilename:
+			key = &gitauth.SSHKey{Host: "*", File: p}
+		case gitauth.KnownHostsFilename:
+			cfg.KnownHostsFile = p
+		}
+	}
+
+	if ssh := spec.GitSSH; ssh != nil {
+		for i, k := range ssh.Keys {
+			p, err := c.writeGitFile(ctx, h, k.Source, k.CommonCredentialSelectors, dir, gitauth.SSHKeyFilename+"-"+strconv.Itoa(i))
+			if err != nil {
+				return nil, "", err
+			}
+			host := k.Host
+			if host == "" {
+				host = "*"
+			}
+			cfg.SSHKeys = append(cfg.SSHKeys, gitauth.SSHKey{Host: host, File: p})
+			_, _ = h.Write([]byte(host + "\x00"))
 		}
+		if ssh.KnownHosts != nil {
+			p, err := c.writeGitFile(ctx, h, ssh.KnownHosts.Source, ssh.KnownHosts.CommonCredentialSelectors, dir, gitauth.KnownHostsFilename)
+			if err != nil {
+				return nil, "", err
+			}
+			cfg.KnownHostsFile = p
+		}
+		cfg.AcceptNewHostKeys = ssh.StrictHostKeyChecking == namespacedv1beta1.StrictHostKeyCheckingAcceptNew
+	}
+
+	// The key with the .git-ssh-key filename authenticates to any host
+	// that no other key matches.
+	if key != nil {
+		cfg.SSHKeys = append(cfg.SSHKeys, *key)
+	}
+
+	// Accepted host keys are remembered for the workspace, rather than in
+	// the provider's known_hosts file.
+	if cfg.AcceptNewHostKeys && cfg.KnownHostsFile == "" {
+		cfg.KnownHostsFile = filepath.Join(dir, gitauth.KnownHostsFilename)
+	}
+
+	if cfg.AcceptNewHostKeys {
+		_, _ = h.Write([]byte("accept-new\x00"))
+	}
+
+	if len(cfg.SSHKeys) > 0 || cfg.KnownHostsFile != "" {
+		cfg.SSHConfigFile = filepath.Join(dir, gitauth.SSHConfigFilename)
+		if err := c.fs.MkdirAll(dir, 0700); err != nil {
+			return nil, "", errors.Wrap(err, errWriteGitCreds)
+		}
+		if err := c.fs.WriteFile(cfg.SSHConfigFile, cfg.SSHConfig(), 0600); err != nil {
+			return nil, "", errors.Wrap(err, errWriteGitCreds)
+		}
+	}
+	return cfg.Env(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeGitFile writes the git credentials extracted from the supplied source
+// to a file with the supplied name in the supplied directory, and returns its
+// path. The file's name and content are also written to the supplied hash.
+func (c *connector) writeGitFile(ctx context.Context, h io.Writer, source xpv1.CredentialsSource, selectors xpv1.CommonCredentialSelectors, dir, name string) (string, error) {
+	data, err := resource.CommonCredentialExtractor(ctx, source, c.kube, selectors)
+	if err != nil {
+		return "", errors.Wrap(err, errGetCreds)
+	}
+	// SSH can't read private keys that don't end with a newline.
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	if err := c.fs.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, errWriteGitCreds)
+	}
+	_, _ = h.Write([]byte(name + "\x00"))
+	_, _ = h.Write(data)
+	_, _ = h.Write([]byte{0})
+	p := filepath.Join(dir, name)
+	if err := c.fs.WriteFile(p, data, 0600); err != nil {
+		return "", errors.Wrap(err, errWriteGitCreds)
+	}
+	return p, nil
+}
+
+// writeInlineModule writes the supplied inline module and its files to the
+// supplied directory. Any main file that was written for a previous inline
+// module is removed, e.g. if the module was replaced by files or changed
+// format.
+func writeInlineModule(fs afero.Afero, dir string, p v1beta1.WorkspaceParameters) error {
+	fn := tfMain
+	if p.InlineFormat == v1beta1.FileFormatJSON {
+		fn = tfMainJSON
+	}
+	if err := validateInlineFiles(p.Files, fn, p.Module != ""); err != nil {
+		return errors.Wrap(err, errInlineFiles)
+	}
+	main := p.Module != "" || len(p.Files) == 0
+	if main {
+		if err := fs.WriteFile(filepath.Join(dir, fn), []byte(p.Module), 0600); err != nil {
+			return errors.Wrap(err, errWriteMain+fn)
+		}
+	}
+	for _, m := range []string{tfMain, tfMainJSON} {
+		if _, ok := p.Files[m]; ok || (main && m == fn) {
+			continue
+		}
+		if err := fs.Remove(filepath.Join(dir, m)); resource.Ignore(os.IsNotExist, err) != nil {
+			return errors.Wrap(err, errInlineFiles)
+		}
+	}
+	files := make(map[string][]byte, len(p.Files))
+	for name, f := range p.Files {
+		files[name] = []byte(f.Content)
+	}
+	return errors.Wrap(writeModuleFiles(fs, dir, files), errInlineFiles)
+}
+
+// writeModuleFiles writes the supplied module files to the supplied
+// directory, and removes any module files that were previously written there
+// but are no longer supplied.
+func writeModuleFiles(fs afero.Afero, dir string, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+		if err := fs.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		if err := fs.WriteFile(p, data, 0600); err != nil {
+			return errors.Wrap(err, errWriteMain+name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if prev, err := fs.ReadFile(filepath.Join(dir, moduleFilesManifest)); err == nil {
+		for _, name := range strings.Split(string(prev), "\n") {
+			if _, ok := files[name]; ok || !filepath.IsLocal(name) {
+				continue
+			}
+			if err := fs.Remove(filepath.Join(dir, name)); resource.Ignore(os.IsNotExist, err) != nil {
+				return err
+			}
+		}
+	}
+	if len(names) == 0 {
+		return resource.Ignore(os.IsNotExist, fs.Remove(filepath.Join(dir, moduleFilesManifest)))
 	}
-	return cd
+	return fs.WriteFile(filepath.Join(dir, moduleFilesManifest), []byte(strings.Join(names, "\n")), 0600)
 }
 
 // generateWorkspaceObservation is used to produce v1beta1.WorkspaceObservation from
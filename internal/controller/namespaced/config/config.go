@@ -18,11 +18,12 @@ import (
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
 
 	"github.com/upbound/provider-opentofu/apis/namespaced/v1beta1"
+	"github.com/upbound/provider-opentofu/internal/tofuversion"
 )
 
 // Setup adds a controller that reconciles ProviderConfigs by accounting for
 // their current usage.
-func Setup(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJitter time.Duration) error {
+func Setup(mgr ctrl.Manager, o controller.Options, timeout, pollJitter, moduleRefresh time.Duration, versions *tofuversion.Installer) error {
 	name := providerconfig.ControllerName(v1beta1.ProviderConfigGroupKind)
 
 	of := resource.ProviderConfigKinds{
@@ -45,9 +46,9 @@ func Setup(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJi
 
 // SetupGated adds a controller that reconciles ProviderConfigs by accounting for
 // their current usage.
-func SetupGated(mgr ctrl.Manager, o controller.Options, timeout time.Duration, pollJitter time.Duration) error {
+func SetupGated(mgr ctrl.Manager, o controller.Options, timeout, pollJitter, moduleRefresh time.Duration, versions *tofuversion.Installer) error {
 	o.Gate.Register(func() {
-		if err := Setup(mgr, o, timeout, pollJitter); err != nil {
+		if err := Setup(mgr, o, timeout, pollJitter, moduleRefresh, versions); err != nil {
 			mgr.GetLogger().Error(err, "unable to setup reconciler", "gvk", v1beta1.ProviderConfigGroupVersionKind.String())
 		}
 	}, v1beta1.ProviderConfigGroupVersionKind, v1beta1.ProviderConfigUsageGroupVersionKind)
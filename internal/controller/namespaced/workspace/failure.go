@@ -0,0 +1,146 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package workspace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/upbound/provider-opentofu/apis/namespaced/v1beta1"
+	"github.com/upbound/provider-opentofu/internal/opentofu"
+)
+
+// reasons maps each category of tofu failure to the reason of the
+// TofuSucceeded condition.
+var reasons = map[opentofu.Category]xpv1.ConditionReason{
+	opentofu.CategoryConfigInvalid: v1beta1.ReasonConfigInvalid,
+	opentofu.CategoryAuth:          v1beta1.ReasonAuthFailed,
+	opentofu.CategoryStateLocked:   v1beta1.ReasonStateLocked,
+	opentofu.CategoryProviderCrash: v1beta1.ReasonProviderCrashed,
+	opentofu.CategoryRateLimited:   v1beta1.ReasonRateLimited,
+	opentofu.CategoryNetwork:       v1beta1.ReasonNetworkError,
+	opentofu.CategoryQuota:         v1beta1.ReasonQuotaExceeded,
+	opentofu.CategoryUnknown:       v1beta1.ReasonTofuFailed,
+}
+
+// failures records the outcome of tofu operations in the TofuSucceeded
+// condition of each Workspace, and tracks the Workspaces whose most recent
+// failure was terminal.
+type failures struct {
+	mu       sync.Mutex
+	terminal map[types.NamespacedName]bool
+}
+
+func newFailures() *failures {
+	return &failures{terminal: make(map[types.NamespacedName]bool)}
+}
+
+// record the outcome of a tofu operation on the supplied Workspace. Errors
+// that aren't tofu failures aren't recorded. The supplied error is returned.
+func (f *failures) record(mg resource.Managed, err error) error {
+	cr, ok := mg.(*v1beta1.Workspace)
+	if !ok {
+		return err
+	}
+	if err == nil {
+		cr.Status.SetConditions(v1beta1.TofuSucceeded())
+		return nil
+	}
+	e := &opentofu.Error{}
+	if !errors.As(err, &e) {
+		return err
+	}
+	reason, ok := reasons[e.Category]
+	if !ok {
+		reason = v1beta1.ReasonTofuFailed
+	}
+	cr.Status.SetConditions(v1beta1.TofuFailed(reason, e.Error()))
+	if e.Category.Terminal() {
+		f.mu.Lock()
+		f.terminal[types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}] = true
+		f.mu.Unlock()
+	}
+	return err
+}
+
+// take returns true if the most recent failure of the supplied Workspace was
+// terminal, and forgets it.
+func (f *failures) take(nn types.NamespacedName) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	terminal := f.terminal[nn]
+	delete(f.terminal, nn)
+	return terminal
+}
+
+// A failureConnecter records the outcome of the tofu operations of the
+// ExternalConnecter and ExternalClients it wraps.
+type failureConnecter struct {
+	managed.ExternalConnecter
+	failures *failures
+}
+
+func (c *failureConnecter) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	ec, err := c.ExternalConnecter.Connect(ctx, mg)
+	if err != nil {
+		return ec, c.failures.record(mg, err)
+	}
+	return &failureClient{ExternalClient: ec, failures: c.failures}, nil
+}
+
+type failureClient struct {
+	managed.ExternalClient
+	failures *failures
+}
+
+func (c *failureClient) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	o, err := c.ExternalClient.Observe(ctx, mg)
+	return o, c.failures.record(mg, err)
+}
+
+func (c *failureClient) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, err := c.ExternalClient.Create(ctx, mg)
+	return cr, c.failures.record(mg, err)
+}
+
+func (c *failureClient) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	u, err := c.ExternalClient.Update(ctx, mg)
+	return u, c.failures.record(mg, err)
+}
+
+func (c *failureClient) Delete(ctx context.Context, mg resource.Managed) (managed.ExternalDelete, error) {
+	d, err := c.ExternalClient.Delete(ctx, mg)
+	return d, c.failures.record(mg, err)
+}
+
+// A backoffReconciler requeues Workspaces whose most recent tofu failure was
+// terminal, e.g. invalid configuration, after a fixed delay rather than
+// retrying them with the usual exponential backoff. Retrying won't help until
+// the Workspace or something it references changes, which triggers a
+// reconcile regardless.
+type backoffReconciler struct {
+	reconcile.Reconciler
+	failures *failures
+	delay    time.Duration
+}
+
+func (r *backoffReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	r.failures.take(req.NamespacedName)
+	res, err := r.Reconciler.Reconcile(ctx, req)
+	if r.failures.take(req.NamespacedName) && err == nil && res.Requeue {
+		return reconcile.Result{RequeueAfter: r.delay}, nil
+	}
+	return res, err
+}
@@ -0,0 +1,197 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package workspace
+
+import (
+	"context"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	extensionsV1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/upbound/provider-opentofu/apis/namespaced/v1beta1"
+)
+
+type fakeQueue struct {
+	workqueue.TypedRateLimitingInterface[reconcile.Request]
+	added []reconcile.Request
+}
+
+func (q *fakeQueue) Add(r reconcile.Request) {
+	q.added = append(q.added, r)
+}
+
+func TestIndexes(t *testing.T) {
+	sk := &v1beta1.KeyReference{Name: "secret", Key: "k"}
+	ck := &v1beta1.KeyReference{Name: "config", Key: "k"}
+	ws := &v1beta1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+		Spec: v1beta1.WorkspaceSpec{
+			ForProvider: v1beta1.WorkspaceParameters{
+				VarFiles: []v1beta1.VarFile{
+					{Source: v1beta1.VarFileSourceSecretKey, SecretKeyReference: sk},
+					{Source: v1beta1.VarFileSourceConfigMapKey, ConfigMapKeyReference: ck},
+				},
+				Env: []v1beta1.EnvVar{
+					{Name: "A", SecretKeyReference: &v1beta1.KeyReference{Name: "env-secret", Key: "k"}},
+				},
+				Vars: []v1beta1.Var{
+					{Key: "a", Value: "b"},
+					{Key: "c", ValueFrom: &v1beta1.VarSource{ConfigMapKeyReference: &v1beta1.KeyReference{Name: "var-config", Key: "k"}}},
+					{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+				},
+				ModuleRefs: []v1beta1.ModuleFilesReference{
+					{Name: "module"},
+					{Kind: v1beta1.ModuleFilesKindSecret, Name: "module-secret"},
+				},
+			},
+		},
+	}
+	ws.SetProviderConfigReference(&xpv1.ProviderConfigReference{Kind: v1beta1.ClusterProviderConfigKind, Name: "default"})
+
+	cases := map[string]struct {
+		fn   client.IndexerFunc
+		want []string
+	}{
+		"WorkspaceOutputRefs": {fn: workspaceOutputRefs, want: []string{"default/network"}},
+		"SecretRefs":          {fn: secretRefs, want: []string{"default/secret", "default/env-secret", "default/module-secret"}},
+		"ConfigMapRefs":       {fn: configMapRefs, want: []string{"default/config", "default/var-config", "default/module"}},
+		"ProviderConfigRef":   {fn: providerConfigRef, want: []string{"ClusterProviderConfig//default"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.fn(ws)); diff != "" {
+				t.Errorf("index(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnqueueIndexed(t *testing.T) {
+	kube := &test.MockClient{
+		MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+			obj.(*v1beta1.WorkspaceList).Items = []v1beta1.Workspace{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster"}},
+			}
+			return nil
+		}),
+	}
+	network := &v1beta1.Workspace{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "network"}}
+
+	q := &fakeQueue{}
+	enqueueIndexed(kube, workspaceOutputRefIndex, objectKey).Update(context.Background(), event.UpdateEvent{ObjectOld: network, ObjectNew: network}, q)
+
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "cluster"}}}
+	if diff := cmp.Diff(want, q.added); diff != "" {
+		t.Errorf("enqueueIndexed(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestEnqueueSecretRefs(t *testing.T) {
+	creds := func(namespace string) []v1beta1.ProviderCredentials {
+		return []v1beta1.ProviderCredentials{{
+			Source:                    xpv1.CredentialsSourceSecret,
+			CommonCredentialSelectors: xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Namespace: namespace, Name: "creds"}, Key: "k"}},
+		}}
+	}
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, obj client.ObjectList, opts ...client.ListOption) error {
+			lo := &client.ListOptions{}
+			lo.ApplyOptions(opts)
+			switch l := obj.(type) {
+			case *v1beta1.ProviderConfigList:
+				l.Items = []v1beta1.ProviderConfig{
+					{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pc"}, Spec: v1beta1.ProviderConfigSpec{Credentials: creds("")}},
+					{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ssh"}, Spec: v1beta1.ProviderConfigSpec{GitSSH: &v1beta1.GitSSHConfig{
+						Keys: []v1beta1.GitSSHKey{{GitSSHCredentials: v1beta1.GitSSHCredentials{Source: creds("")[0].Source, CommonCredentialSelectors: creds("elsewhere")[0].CommonCredentialSelectors}}},
+					}}},
+				}
+			case *v1beta1.ClusterProviderConfigList:
+				l.Items = []v1beta1.ClusterProviderConfig{
+					{ObjectMeta: metav1.ObjectMeta{Name: "cpc"}, Spec: v1beta1.ProviderConfigSpec{Credentials: creds("default")}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Spec: v1beta1.ProviderConfigSpec{Credentials: creds("other")}},
+				}
+			case *v1beta1.WorkspaceList:
+				// Each Workspace is named for the index key it matched.
+				for _, v := range []string{"secretRefs", "providerConfigRef"} {
+					if k, ok := lo.FieldSelector.RequiresExactMatch(v); ok {
+						l.Items = []v1beta1.Workspace{{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: k}}}
+					}
+				}
+			}
+			return nil
+		},
+	}
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "creds"}}
+
+	q := &fakeQueue{}
+	enqueueSecretRefs(kube).Update(context.Background(), event.UpdateEvent{ObjectOld: s, ObjectNew: s}, q)
+
+	want := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "default/creds"}},
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "ProviderConfig/default/pc"}},
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "ProviderConfig/default/ssh"}},
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "ClusterProviderConfig//cpc"}},
+	}
+	if diff := cmp.Diff(want, q.added); diff != "" {
+		t.Errorf("enqueueSecretRefs(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestOutputsChanged(t *testing.T) {
+	ready := func(outputs map[string]extensionsV1.JSON) *v1beta1.Workspace {
+		ws := &v1beta1.Workspace{}
+		ws.SetConditions(xpv1.Available())
+		ws.Status.AtProvider.Outputs = outputs
+		return ws
+	}
+
+	cases := map[string]struct {
+		reason string
+		old    *v1beta1.Workspace
+		new    *v1beta1.Workspace
+		want   bool
+	}{
+		"Unchanged": {
+			reason: "Updates that don't change outputs or readiness should be ignored",
+			old:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"b"`)}}),
+			new:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"b"`)}}),
+			want:   false,
+		},
+		"OutputsChanged": {
+			reason: "Updates that change outputs should be accepted",
+			old:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"b"`)}}),
+			new:    ready(map[string]extensionsV1.JSON{"a": {Raw: []byte(`"c"`)}}),
+			want:   true,
+		},
+		"BecameReady": {
+			reason: "Updates that change readiness should be accepted",
+			old:    &v1beta1.Workspace{},
+			new:    ready(nil),
+			want:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := outputsChanged().Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: tc.new})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\noutputsChanged().Update(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -8,23 +8,32 @@ package workspace
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 	corev1 "k8s.io/api/core/v1"
 	extensionsV1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
@@ -32,6 +41,7 @@ import (
 	"github.com/upbound/provider-opentofu/apis/namespaced"
 	"github.com/upbound/provider-opentofu/apis/namespaced/v1beta1"
 	"github.com/upbound/provider-opentofu/internal/clients"
+	"github.com/upbound/provider-opentofu/internal/oci"
 	"github.com/upbound/provider-opentofu/internal/opentofu"
 )
 
@@ -61,16 +71,58 @@ func (e *ErrFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, e
 	return e.Fs.OpenFile(name, flag, perm)
 }
 
+type MockModuleCache struct {
+	MockGet         func(ctx context.Context, src, dst string, env []string, digest string) (string, error)
+	MockGetArtifact func(ctx context.Context, ref string, kc oci.Keychain, dst string) (string, error)
+}
+
+func (m *MockModuleCache) Get(ctx context.Context, src, dst string, env []string, digest string) (string, error) {
+	return m.MockGet(ctx, src, dst, env, digest)
+}
+
+func (m *MockModuleCache) GetArtifact(ctx context.Context, ref string, kc oci.Keychain, dst string) (string, error) {
+	return m.MockGetArtifact(ctx, ref, kc, dst)
+}
+
+type MockTofuVersions struct {
+	MockResolve func(ctx context.Context, version string) (string, string, error)
+	MockRecord  func(version, platform string)
+	MockVersion func(ctx context.Context, path string, fn func(ctx context.Context) (*opentofu.Version, error)) (*opentofu.Version, error)
+}
+
+func (m *MockTofuVersions) Resolve(ctx context.Context, version string) (string, string, error) {
+	return m.MockResolve(ctx, version)
+}
+
+func (m *MockTofuVersions) Record(version, platform string) {
+	m.MockRecord(version, platform)
+}
+
+func (m *MockTofuVersions) Version(ctx context.Context, path string, fn func(ctx context.Context) (*opentofu.Version, error)) (*opentofu.Version, error) {
+	if m.MockVersion == nil {
+		return fn(ctx)
+	}
+	return m.MockVersion(ctx, path, fn)
+}
+
 type MockTofu struct {
+	MockVersion                func(ctx context.Context) (*opentofu.Version, error)
 	MockInit                   func(ctx context.Context, o ...opentofu.InitOption) error
 	MockWorkspace              func(ctx context.Context, name string) error
 	MockOutputs                func(ctx context.Context) ([]opentofu.Output, error)
 	MockResources              func(ctx context.Context) ([]string, error)
-	MockDiff                   func(ctx context.Context, o ...opentofu.Option) (bool, error)
+	MockPlan                   func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error)
+	MockRefreshOnlyPlan        func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error)
 	MockApply                  func(ctx context.Context, o ...opentofu.Option) error
+	MockApplyPlan              func(ctx context.Context, o ...opentofu.Option) error
 	MockDestroy                func(ctx context.Context, o ...opentofu.Option) error
 	MockDeleteCurrentWorkspace func(ctx context.Context) error
 	MockGenerateChecksum       func(ctx context.Context) (string, error)
+	MockForceUnlock            func(ctx context.Context, id string) error
+}
+
+func (tf *MockTofu) Version(ctx context.Context) (*opentofu.Version, error) {
+	return tf.MockVersion(ctx)
 }
 
 func (tf *MockTofu) Init(ctx context.Context, o ...opentofu.InitOption) error {
@@ -93,14 +145,22 @@ func (tf *MockTofu) Resources(ctx context.Context) ([]string, error) {
 	return tf.MockResources(ctx)
 }
 
-func (tf *MockTofu) Diff(ctx context.Context, o ...opentofu.Option) (bool, error) {
-	return tf.MockDiff(ctx, o...)
+func (tf *MockTofu) Plan(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) {
+	return tf.MockPlan(ctx, o...)
+}
+
+func (tf *MockTofu) RefreshOnlyPlan(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) {
+	return tf.MockRefreshOnlyPlan(ctx, o...)
 }
 
 func (tf *MockTofu) Apply(ctx context.Context, o ...opentofu.Option) error {
 	return tf.MockApply(ctx, o...)
 }
 
+func (tf *MockTofu) ApplyPlan(ctx context.Context, o ...opentofu.Option) error {
+	return tf.MockApplyPlan(ctx, o...)
+}
+
 func (tf *MockTofu) Destroy(ctx context.Context, o ...opentofu.Option) error {
 	return tf.MockDestroy(ctx, o...)
 }
@@ -109,6 +169,10 @@ func (tf *MockTofu) DeleteCurrentWorkspace(ctx context.Context) error {
 	return tf.MockDeleteCurrentWorkspace(ctx)
 }
 
+func (tf *MockTofu) ForceUnlock(ctx context.Context, id string) error {
+	return tf.MockForceUnlock(ctx, id)
+}
+
 func TestConnect(t *testing.T) {
 	errBoom := errors.New("boom")
 	errNoProviderConfig := errors.New(errProviderConfigNotSet)
@@ -116,10 +180,12 @@ func TestConnect(t *testing.T) {
 	tfCreds := "credentials"
 
 	type fields struct {
-		kube  client.Client
-		usage clients.ModernTracker
-		fs    afero.Afero
-		tofu  func(dir string, usePluginCache bool, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient
+		kube     client.Client
+		usage    clients.ModernTracker
+		fs       afero.Afero
+		modules  moduleCache
+		versions tofuVersions
+		tofu     func(path, dir string, usePluginCache bool, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient
 	}
 
 	type args struct {
@@ -227,7 +293,7 @@ func TestConnect(t *testing.T) {
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
 				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
 						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
@@ -275,7 +341,7 @@ func TestConnect(t *testing.T) {
 						errs: map[string]error{filepath.Join(tfDir, string(uid), tfCreds): errBoom},
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
 						MockInit:      func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 						MockWorkspace: func(ctx context.Context, name string) error { return errors.New(errWriteCreds) },
@@ -324,7 +390,7 @@ func TestConnect(t *testing.T) {
 						errs: map[string]error{filepath.Join(tfDir, string(uid), "subdir", tfCreds): errBoom},
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
 						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
@@ -377,7 +443,7 @@ func TestConnect(t *testing.T) {
 						errs: map[string]error{filepath.Join("/tmp", tfDir, string(uid), ".git-credentials"): errBoom},
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
 						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
@@ -429,7 +495,7 @@ func TestConnect(t *testing.T) {
 						errs: map[string]error{filepath.Join("/tmp", tfDir, string(uid)): errBoom},
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
 						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
@@ -453,17 +519,11 @@ func TestConnect(t *testing.T) {
 			},
 			want: errors.Wrap(errBoom, errWriteGitCreds),
 		},
-		"WriteConfigError": {
-			reason: "We should return any error encountered while writing our crossplane-provider-config.tf file",
+		"RemoteModuleError": {
+			reason: "We should return any error encountered while getting a remote module",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
-							cfg := "I'm HCL!"
-							pc.Spec.Configuration = &cfg
-						}
-						return nil
-					}),
+					MockGet: test.NewMockGetFn(nil),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -473,13 +533,13 @@ func TestConnect(t *testing.T) {
 					},
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
-				fs: afero.Afero{
-					Fs: &ErrFs{
-						Fs:   afero.NewMemMapFs(),
-						errs: map[string]error{filepath.Join(tfDir, string(uid), tfConfig): errBoom},
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				modules: &MockModuleCache{
+					MockGet: func(_ context.Context, _, _ string, _ []string, _ string) (string, error) {
+						return "", errBoom
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
 						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
@@ -495,22 +555,24 @@ func TestConnect(t *testing.T) {
 							},
 						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							Module: "I'm HCL!",
-							Source: v1beta1.ModuleSourceInline,
+							Module: "github.com/crossplane/rocks",
+							Source: v1beta1.ModuleSourceRemote,
 						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errWriteConfig),
+			want: errors.Wrap(errBoom, errRemoteModule),
 		},
-		"WriteConfigEntrypointError": {
-			reason: "We should return any error encountered while writing our crossplane-provider-config.tf file to entrypoint subdir location",
+		"RemoteModuleGitCredentials": {
+			reason: "We should get remote modules using only the workspace's git credentials",
 			fields: fields{
 				kube: &test.MockClient{
 					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
 						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
-							cfg := "I'm HCL!"
-							pc.Spec.Configuration = &cfg
+							pc.Spec.Credentials = []v1beta1.ProviderCredentials{{
+								Filename: ".git-credentials",
+								Source:   xpv1.CredentialsSourceNone,
+							}}
 						}
 						return nil
 					}),
@@ -523,17 +585,23 @@ func TestConnect(t *testing.T) {
 					},
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
-				fs: afero.Afero{
-					Fs: &ErrFs{
-						Fs:   afero.NewMemMapFs(),
-						errs: map[string]error{filepath.Join(tfDir, string(uid), "subdir", tfConfig): errBoom},
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				modules: &MockModuleCache{
+					MockGet: func(_ context.Context, _, _ string, env []string, _ string) (string, error) {
+						want := []string{
+							"GIT_TERMINAL_PROMPT=0",
+							"GIT_CONFIG_COUNT=2",
+							"GIT_CONFIG_KEY_0=credential.helper",
+							"GIT_CONFIG_VALUE_0=",
+							"GIT_CONFIG_KEY_1=credential.helper",
+							"GIT_CONFIG_VALUE_1=store --file='" + filepath.Join("/tmp", tfDir, string(uid), ".git-credentials") + "'",
+						}
+						if diff := cmp.Diff(want, env); diff != "" {
+							return "", errors.New(diff)
+						}
+						return "", errBoom
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
-					return &MockTofu{
-						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
-					}
-				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
@@ -545,20 +613,29 @@ func TestConnect(t *testing.T) {
 							},
 						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							Module:     "I'm HCL!",
-							Source:     v1beta1.ModuleSourceInline,
-							Entrypoint: "subdir",
+							Module: "github.com/crossplane/rocks",
+							Source: v1beta1.ModuleSourceRemote,
 						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errWriteConfig),
+			want: errors.Wrap(errBoom, errRemoteModule),
 		},
-		"WriteMainError": {
-			reason: "We should return any error encountered while writing our main.tf file",
+		"RemoteModuleGitSSHKeys": {
+			reason: "We should get remote modules using only the workspace's SSH config",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							pc.Spec.GitSSH = &v1beta1.GitSSHConfig{
+								Keys: []v1beta1.GitSSHKey{{
+									Host:              "github.com",
+									GitSSHCredentials: v1beta1.GitSSHCredentials{Source: xpv1.CredentialsSourceNone},
+								}},
+							}
+						}
+						return nil
+					}),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -568,17 +645,22 @@ func TestConnect(t *testing.T) {
 					},
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
-				fs: afero.Afero{
-					Fs: &ErrFs{
-						Fs:   afero.NewMemMapFs(),
-						errs: map[string]error{filepath.Join(tfDir, string(uid), tfMain): errBoom},
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				modules: &MockModuleCache{
+					MockGet: func(_ context.Context, _, _ string, env []string, _ string) (string, error) {
+						want := []string{
+							"GIT_TERMINAL_PROMPT=0",
+							"GIT_CONFIG_COUNT=1",
+							"GIT_CONFIG_KEY_0=credential.helper",
+							"GIT_CONFIG_VALUE_0=",
+							"GIT_SSH_COMMAND=ssh -F '" + filepath.Join("/tmp", tfDir, string(uid), ".git-ssh-config") + "'",
+						}
+						if diff := cmp.Diff(want, env); diff != "" {
+							return "", errors.New(diff)
+						}
+						return "", errBoom
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
-					return &MockTofu{
-						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
-					}
-				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
@@ -590,19 +672,26 @@ func TestConnect(t *testing.T) {
 							},
 						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							Module: "I'm HCL!",
-							Source: v1beta1.ModuleSourceInline,
+							Module: "github.com/crossplane/rocks",
+							Source: v1beta1.ModuleSourceRemote,
 						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errWriteMain+tfMain),
+			want: errors.Wrap(errBoom, errRemoteModule),
 		},
-		"WriteMainJsonError": {
-			reason: "We should return any error encountered while writing our main.tf file",
+		"WriteGitSSHConfigError": {
+			reason: "We should return any error encountered while writing the workspace's SSH config",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							pc.Spec.GitSSH = &v1beta1.GitSSHConfig{
+								KnownHosts: &v1beta1.GitSSHCredentials{Source: xpv1.CredentialsSourceNone},
+							}
+						}
+						return nil
+					}),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -615,14 +704,9 @@ func TestConnect(t *testing.T) {
 				fs: afero.Afero{
 					Fs: &ErrFs{
 						Fs:   afero.NewMemMapFs(),
-						errs: map[string]error{filepath.Join(tfDir, string(uid), tfMainJSON): errBoom},
+						errs: map[string]error{filepath.Join("/tmp", tfDir, string(uid), ".git-ssh-config"): errBoom},
 					},
 				},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
-					return &MockTofu{
-						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
-					}
-				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
@@ -634,20 +718,24 @@ func TestConnect(t *testing.T) {
 							},
 						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							Module:       "I'm JSON!",
-							Source:       v1beta1.ModuleSourceInline,
-							InlineFormat: v1beta1.FileFormatJSON,
+							Module: "github.com/crossplane/rocks",
+							Source: v1beta1.ModuleSourceRemote,
 						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errWriteMain+tfMainJSON),
+			want: errors.Wrap(errBoom, errWriteGitCreds),
 		},
-		"TofuInitError": {
-			reason: "We should return any error encountered while initializing Tofu",
+		"ModuleSourceNotAllowed": {
+			reason: "We should return an error if the ProviderConfig doesn't allow the module's source",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							pc.Spec.AllowedModuleSources = []string{"github.com/upbound"}
+						}
+						return nil
+					}),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -658,8 +746,10 @@ func TestConnect(t *testing.T) {
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
 				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
-					return &MockTofu{MockInit: func(_ context.Context, _ ...opentofu.InitOption) error { return errBoom }}
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
+					}
 				},
 			},
 			args: args{
@@ -671,16 +761,25 @@ func TestConnect(t *testing.T) {
 								Kind: "ClusterProviderConfig",
 							},
 						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module: "github.com/crossplane/rocks",
+							Source: v1beta1.ModuleSourceRemote,
+						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errInit),
+			want: errors.Wrap(errors.Errorf("module source %q does not match any allowed module source", "https://github.com/crossplane/rocks"), errModuleSource),
 		},
-		"TofuWorkspaceError": {
-			reason: "We should return any error encountered while selecting a tofu workspace",
+		"InlineModuleForbidden": {
+			reason: "We should return an error if the ProviderConfig forbids inline modules",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							pc.Spec.ForbidInlineModules = true
+						}
+						return nil
+					}),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -691,10 +790,9 @@ func TestConnect(t *testing.T) {
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
 				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
-						MockInit:      func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
-						MockWorkspace: func(_ context.Context, _ string) error { return errBoom },
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
 				},
 			},
@@ -707,28 +805,56 @@ func TestConnect(t *testing.T) {
 								Kind: "ClusterProviderConfig",
 							},
 						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module: "I'm HCL!",
+							Source: v1beta1.ModuleSourceInline,
+						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errWorkspace),
+			want: errors.Wrap(errors.Errorf(errFmtForbidden, v1beta1.ModuleSourceInline), errModuleSource),
 		},
-		"GenerateChecksumError": {
-			reason: "We should return any error when generating the workspace checksum",
-			fields: fields{kube: &test.MockClient{
-				MockGet: test.NewMockGetFn(nil),
-				MockScheme: func() *runtime.Scheme {
-					s := runtime.NewScheme()
-					if err := namespaced.AddToScheme(s); err != nil {
-						t.Fatal(err)
-					}
-					return s
+		"OCIModuleError": {
+			reason: "We should pass the registry credentials to, and return any error encountered while getting, an OCI module",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1beta1.ClusterProviderConfig:
+							o.Spec.Credentials = []v1beta1.ProviderCredentials{{
+								Filename: registryCredentialsFilename,
+								Source:   xpv1.CredentialsSourceSecret,
+								CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+									SecretRef: &xpv1.SecretKeySelector{Key: registryCredentialsFilename},
+								},
+							}}
+						case *corev1.Secret:
+							o.Data = map[string][]byte{registryCredentialsFilename: []byte(`{"auths":{"registry.example.org":{"username":"cool","password":"secret"}}}`)}
+						}
+						return nil
+					}),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
+						}
+						return s
+					},
 				},
-			},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
 				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				modules: &MockModuleCache{
+					MockGetArtifact: func(_ context.Context, _ string, kc oci.Keychain, _ string) (string, error) {
+						want := oci.Keychain{"registry.example.org": {Username: "cool", Password: "secret"}}
+						if diff := cmp.Diff(want, kc); diff != "" {
+							t.Errorf("GetArtifact(...): -want keychain, +got keychain:\n%s", diff)
+						}
+						return "", errBoom
+					},
+				},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
-						MockGenerateChecksum: func(ctx context.Context) (string, error) { return "", errBoom },
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
 				},
 			},
@@ -742,37 +868,43 @@ func TestConnect(t *testing.T) {
 							},
 						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							Module: "I'm HCL!",
-							Source: v1beta1.ModuleSourceInline,
-						},
-					},
-					Status: v1beta1.WorkspaceStatus{
-						AtProvider: v1beta1.WorkspaceObservation{
-							Checksum: tfChecksum,
+							Module: "registry.example.org/modules/rocks:v1",
+							Source: v1beta1.ModuleSourceOCI,
 						},
 					},
 				},
 			},
-			want: errors.Wrap(errBoom, errChecksum),
+			want: errors.Wrap(errBoom, errOCIModule),
 		},
-		"ChecksumMatches": {
-			reason: "We should return any error when generating the workspace checksum",
-			fields: fields{kube: &test.MockClient{
-				MockGet: test.NewMockGetFn(nil),
-				MockScheme: func() *runtime.Scheme {
-					s := runtime.NewScheme()
-					if err := namespaced.AddToScheme(s); err != nil {
-						t.Fatal(err)
-					}
-					return s
+		"WriteConfigError": {
+			reason: "We should return any error encountered while writing our crossplane-provider-config.tf file",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							cfg := "I'm HCL!"
+							pc.Spec.Configuration = &cfg
+						}
+						return nil
+					}),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
+						}
+						return s
+					},
 				},
-			},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
-				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				fs: afero.Afero{
+					Fs: &ErrFs{
+						Fs:   afero.NewMemMapFs(),
+						errs: map[string]error{filepath.Join(tfDir, string(uid), tfConfig): errBoom},
+					},
+				},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
-						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
 				},
 			},
@@ -790,20 +922,21 @@ func TestConnect(t *testing.T) {
 							Source: v1beta1.ModuleSourceInline,
 						},
 					},
-					Status: v1beta1.WorkspaceStatus{
-						AtProvider: v1beta1.WorkspaceObservation{
-							Checksum: tfChecksum,
-						},
-					},
 				},
 			},
-			want: nil,
+			want: errors.Wrap(errBoom, errWriteConfig),
 		},
-		"Success": {
-			reason: "We should not return an error when we successfully 'connect' to tofu",
+		"WriteConfigEntrypointError": {
+			reason: "We should return any error encountered while writing our crossplane-provider-config.tf file to entrypoint subdir location",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							cfg := "I'm HCL!"
+							pc.Spec.Configuration = &cfg
+						}
+						return nil
+					}),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -813,12 +946,15 @@ func TestConnect(t *testing.T) {
 					},
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
-				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				fs: afero.Afero{
+					Fs: &ErrFs{
+						Fs:   afero.NewMemMapFs(),
+						errs: map[string]error{filepath.Join(tfDir, string(uid), "subdir", tfConfig): errBoom},
+					},
+				},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
-						MockInit:             func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
-						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
 				},
 			},
@@ -826,32 +962,26 @@ func TestConnect(t *testing.T) {
 				mg: &v1beta1.Workspace{
 					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							InitArgs: []string{"-upgrade=true"},
-						},
 						ManagedResourceSpec: xpv2.ManagedResourceSpec{
 							ProviderConfigReference: &xpv1.ProviderConfigReference{
 								Kind: "ClusterProviderConfig",
 							},
 						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module:     "I'm HCL!",
+							Source:     v1beta1.ModuleSourceInline,
+							Entrypoint: "subdir",
+						},
 					},
 				},
 			},
-			want: nil,
+			want: errors.Wrap(errBoom, errWriteConfig),
 		},
-		"SuccessUsingBackendFile": {
-			reason: "We should not return an error when we successfully 'connect' to tofu using a Backend file",
+		"WriteMainError": {
+			reason: "We should return any error encountered while writing our main.tf file",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
-							cfg := "I'm HCL!"
-							backendFile := "I'm a backend!"
-							pc.Spec.Configuration = &cfg
-							pc.Spec.BackendFile = &backendFile
-						}
-						return nil
-					}),
+					MockGet: test.NewMockGetFn(nil),
 					MockScheme: func() *runtime.Scheme {
 						s := runtime.NewScheme()
 						if err := namespaced.AddToScheme(s); err != nil {
@@ -861,20 +991,15 @@ func TestConnect(t *testing.T) {
 					},
 				},
 				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
-				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
-				tofu: func(_ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+				fs: afero.Afero{
+					Fs: &ErrFs{
+						Fs:   afero.NewMemMapFs(),
+						errs: map[string]error{filepath.Join(tfDir, string(uid), tfMain): errBoom},
+					},
+				},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
 					return &MockTofu{
-						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error {
-							args := opentofu.InitArgsToString(o)
-							if len(args) != 2 {
-								return errors.New("two init args are expected")
-							} else if args[0] != "-backend-config=/tofu/no-you-id/crossplane.remote.tfbackend" {
-								return errors.Errorf("backend config arg has invalid value: %s", args[0])
-							}
-							return nil
-						},
-						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
 					}
 				},
 			},
@@ -882,423 +1007,590 @@ func TestConnect(t *testing.T) {
 				mg: &v1beta1.Workspace{
 					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							InitArgs: []string{"-upgrade=true"},
-						},
 						ManagedResourceSpec: xpv2.ManagedResourceSpec{
 							ProviderConfigReference: &xpv1.ProviderConfigReference{
 								Kind: "ClusterProviderConfig",
 							},
 						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module: "I'm HCL!",
+							Source: v1beta1.ModuleSourceInline,
+						},
 					},
 				},
 			},
-			want: nil,
-		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			c := connector{
-				kube:   tc.fields.kube,
-				usage:  tc.fields.usage,
-				fs:     tc.fields.fs,
-				tofu:   tc.fields.tofu,
-				logger: logging.NewNopLogger(),
-			}
-			_, err := c.Connect(tc.args.ctx, tc.args.mg)
-			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\ne.Connect(...): -want error, +got error:\n%s\n", tc.reason, diff)
-			}
-		})
-	}
-}
-
-func TestObserve(t *testing.T) {
-	errBoom := errors.New("boom")
-	now := metav1.Now()
-	type fields struct {
-		tofu tofuclient
-		kube client.Client
-	}
-
-	type args struct {
-		ctx context.Context
-		mg  resource.Managed
-	}
-
-	type want struct {
-		o   managed.ExternalObservation
-		wo  v1beta1.WorkspaceObservation
-		err error
-	}
-
-	cases := map[string]struct {
-		reason string
-		fields fields
-		args   args
-		want   want
-	}{
-		"NotAWorkspaceError": {
-			reason: "We should return an error if the supplied managed resource is not a Workspace",
-			args: args{
-				mg: nil,
-			},
-			want: want{
-				err: errors.New(errNotWorkspace),
-			},
+			want: errors.Wrap(errBoom, errWriteMain+tfMain),
 		},
-		"GetConfigMapError": {
-			reason: "We should return any error we encounter getting tfvars from a ConfigMap",
+		"WriteMainJsonError": {
+			reason: "We should return any error encountered while writing our main.tf file",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if _, ok := obj.(*corev1.ConfigMap); ok {
-							return errBoom
+					MockGet: test.NewMockGetFn(nil),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
 						}
-						return nil
-					}),
+						return s
+					},
+				},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs: afero.Afero{
+					Fs: &ErrFs{
+						Fs:   afero.NewMemMapFs(),
+						errs: map[string]error{filepath.Join(tfDir, string(uid), tfMainJSON): errBoom},
+					},
+				},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
+					}
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							VarFiles: []v1beta1.VarFile{
-								{
-									Source:                v1beta1.VarFileSourceConfigMapKey,
-									ConfigMapKeyReference: &v1beta1.KeyReference{},
-								},
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
 							},
 						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module:       "I'm JSON!",
+							Source:       v1beta1.ModuleSourceInline,
+							InlineFormat: v1beta1.FileFormatJSON,
+						},
 					},
 				},
 			},
-			want: want{
-				err: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
-			},
+			want: errors.Wrap(errBoom, errWriteMain+tfMainJSON),
 		},
-		"GetSecretError": {
-			reason: "We should return any error we encounter getting tfvars from a Secret",
+		"TofuInitError": {
+			reason: "We should return any error encountered while initializing Tofu",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if _, ok := obj.(*corev1.Secret); ok {
-							return errBoom
+					MockGet: test.NewMockGetFn(nil),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
 						}
-						return nil
-					}),
+						return s
+					},
+				},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{MockInit: func(_ context.Context, _ ...opentofu.InitOption) error { return errBoom }}
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							VarFiles: []v1beta1.VarFile{
-								{
-									Source:             v1beta1.VarFileSourceSecretKey,
-									SecretKeyReference: &v1beta1.KeyReference{},
-								},
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
 							},
 						},
 					},
 				},
 			},
-			want: want{
-				err: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
-			},
+			want: errors.Wrap(errBoom, errInit),
 		},
-		"GetVarMapError": {
-			reason: "We should return any error we encounter getting tfvars from varmap",
+		"TofuWorkspaceError": {
+			reason: "We should return any error encountered while selecting a tofu workspace",
 			fields: fields{
 				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if _, ok := obj.(*corev1.Secret); ok {
-							return errBoom
+					MockGet: test.NewMockGetFn(nil),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
 						}
-						return nil
-					}),
+						return s
+					},
+				},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockInit:      func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
+						MockWorkspace: func(_ context.Context, _ string) error { return errBoom },
+					}
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							VarMap: &runtime.RawExtension{
-								Raw: []byte("I'm not JSON"),
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
 							},
 						},
 					},
 				},
 			},
-			want: want{
-				err: errors.Wrap(errors.Wrap(errors.New("json: error calling MarshalJSON for type *runtime.RawExtension: cannot convert RawExtension with unrecognized content type to unstructured"), errVarMap), errOptions),
-			},
+			want: errors.Wrap(errBoom, errWorkspace),
 		},
-		"DiffError": {
-			reason: "We should return any error encountered while diffing the tofu configuration",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff: func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, errBoom },
+		"GenerateChecksumError": {
+			reason: "We should return any error when generating the workspace checksum",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
 				},
 			},
-			args: args{
-				mg: &v1beta1.Workspace{},
-			},
-			want: want{
-				err: errors.Wrap(errBoom, errDiff),
-			},
-		},
-		"DiffErrorDeletedWithExistingResources": {
-			reason: "We should return ResourceUpToDate true when resource is deleted and there are existing resources but tofu plan fails",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:             func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, errBoom },
-					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
-					MockResources: func(ctx context.Context) ([]string, error) {
-						return []string{"cool_resource.very"}, nil
-					},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockGenerateChecksum: func(ctx context.Context) (string, error) { return "", errBoom },
+					}
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
-					ObjectMeta: metav1.ObjectMeta{
-						DeletionTimestamp: &now,
-					},
-				},
-			},
-			want: want{
-				o: managed.ExternalObservation{
-					ResourceExists:    true,
-					ResourceUpToDate:  true,
-					ConnectionDetails: managed.ConnectionDetails{},
-				},
-				wo: v1beta1.WorkspaceObservation{
-					Checksum: tfChecksum,
-					Outputs:  map[string]extensionsV1.JSON{},
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module: "I'm HCL!",
+							Source: v1beta1.ModuleSourceInline,
+						},
+					},
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							Checksum:    tfChecksum,
+							TofuVersion: "1.10.0",
+						},
+					},
 				},
 			},
+			want: errors.Wrap(errBoom, errChecksum),
 		},
-		"DiffErrorDeletedWithoutExistingResources": {
-			reason: "We should return ResourceUpToDate true when resource is deleted and there are no existing resources and tofu plan fails",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:                   func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, errBoom },
-					MockGenerateChecksum:       func(ctx context.Context) (string, error) { return tfChecksum, nil },
-					MockOutputs:                func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
-					MockResources:              func(ctx context.Context) ([]string, error) { return nil, nil },
-					MockDeleteCurrentWorkspace: func(ctx context.Context) error { return nil },
+		"ChecksumMatches": {
+			reason: "We should return any error when generating the workspace checksum",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
+				},
+			},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+					}
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
-					ObjectMeta: metav1.ObjectMeta{
-						DeletionTimestamp: &now,
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module: "I'm HCL!",
+							Source: v1beta1.ModuleSourceInline,
+						},
+					},
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							Checksum:    tfChecksum,
+							TofuVersion: "1.10.0",
+						},
 					},
 				},
 			},
-			want: want{
-				o: managed.ExternalObservation{
-					ResourceExists:    false,
-					ResourceUpToDate:  true,
-					ConnectionDetails: managed.ConnectionDetails{},
-				},
-				wo: v1beta1.WorkspaceObservation{
-					Checksum: tfChecksum,
-					Outputs:  map[string]extensionsV1.JSON{},
-				},
-			},
+			want: nil,
 		},
-		"DiffErrorDeletedWithoutExistingResourcesWorkspaceDeleteError": {
-			reason: "We should return ResourceUpToDate true when resource is deleted and there are no existing resources and tofu plan fails",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:                   func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, errBoom },
-					MockResources:              func(ctx context.Context) ([]string, error) { return nil, nil },
-					MockDeleteCurrentWorkspace: func(ctx context.Context) error { return errBoom },
+		"TofuVersionChanged": {
+			reason: "We should initialize the workspace again if its tofu version changed, using the workspace's tofu version",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
 				},
 			},
-			args: args{
-				mg: &v1beta1.Workspace{
-					ObjectMeta: metav1.ObjectMeta{
-						DeletionTimestamp: &now,
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				versions: &MockTofuVersions{
+					MockResolve: func(_ context.Context, v string) (string, string, error) {
+						return "/versions/" + v + "/tofu", v, nil
 					},
+					MockRecord: func(_, _ string) {},
 				},
-			},
-			want: want{
-				err: errors.Wrap(errBoom, errDeleteWorkspace),
-			},
-		},
-		"ResourcesError": {
-			reason: "We should return any error encountered while listing extant tofu resources",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:      func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, nil },
-					MockResources: func(ctx context.Context) ([]string, error) { return nil, errBoom },
+				tofu: func(path, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockVersion: func(_ context.Context) (*opentofu.Version, error) { return opentofu.NewVersion("1.9.0", "linux_amd64") },
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error {
+							if path != "/versions/1.9.0/tofu" {
+								return errors.Errorf("tofu path is %s", path)
+							}
+							return errBoom
+						},
+						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+					}
 				},
 			},
 			args: args{
-				mg: &v1beta1.Workspace{},
-			},
-			want: want{
-				err: errors.Wrap(errBoom, errResources),
-			},
-		},
-		"OutputsError": {
-			reason: "We should return any error encountered while listing tofu outputs",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:      func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, nil },
-					MockResources: func(ctx context.Context) ([]string, error) { return nil, nil },
-					MockOutputs:   func(ctx context.Context) ([]opentofu.Output, error) { return nil, errBoom },
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module:      "I'm HCL!",
+							Source:      v1beta1.ModuleSourceInline,
+							TofuVersion: "1.9.0",
+						},
+					},
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							Checksum:    tfChecksum,
+							TofuVersion: "1.8.0",
+						},
+					},
 				},
 			},
-			args: args{
-				mg: &v1beta1.Workspace{},
-			},
-			want: want{
-				err: errors.Wrap(errBoom, errOutputs),
-			},
+			want: errors.Wrap(errBoom, errInit),
 		},
-		"WorkspaceDoesNotExist": {
-			reason: "A workspace with zero resources should be considered to be non-existent",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:             func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, nil },
-					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-					MockResources:        func(ctx context.Context) ([]string, error) { return []string{}, nil },
-					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
-				},
-			},
-			args: args{
-				mg: &v1beta1.Workspace{},
-			},
-			want: want{
-				o: managed.ExternalObservation{
-					ResourceExists:    false,
-					ResourceUpToDate:  true,
-					ConnectionDetails: managed.ConnectionDetails{},
-				},
-				wo: v1beta1.WorkspaceObservation{
-					Checksum: tfChecksum,
-					Outputs:  map[string]extensionsV1.JSON{},
+		"TofuVersionError": {
+			reason: "We should return any error encountered while resolving the tofu version",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
 				},
 			},
-		},
-		"WorkspaceExists": {
-			reason: "A workspace with resources should return its outputs as connection details",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:             func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, nil },
-					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-					MockResources: func(ctx context.Context) ([]string, error) {
-						return []string{"cool_resource.very"}, nil
-					},
-					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) {
-						return []opentofu.Output{
-							{Name: "string", Type: opentofu.OutputTypeString, Sensitive: false},
-							{Name: "object", Type: opentofu.OutputTypeObject, Sensitive: true},
-						}, nil
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				versions: &MockTofuVersions{
+					MockResolve: func(_ context.Context, _ string) (string, string, error) {
+						return "", "", errBoom
 					},
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							PlanArgs: []string{"-refresh=false"},
+							Module:      "I'm HCL!",
+							Source:      v1beta1.ModuleSourceInline,
+							TofuVersion: "1.9.0",
 						},
 					},
-				},
-			},
-			want: want{
-				o: managed.ExternalObservation{
-					ResourceExists:   true,
-					ResourceUpToDate: true,
-					ConnectionDetails: managed.ConnectionDetails{
-						"string": {},
-						"object": []byte("null"), // Because we JSON decode the the value, which is any{}
-					},
-				},
-				wo: v1beta1.WorkspaceObservation{
-					Checksum: tfChecksum,
-					Outputs: map[string]extensionsV1.JSON{
-						"string": {Raw: []byte("null")},
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							Checksum: tfChecksum,
+						},
 					},
 				},
 			},
+			want: errors.Wrap(errBoom, errTofuVersion),
 		},
-		"WorkspaceExistsOnlyOutputs": {
-			reason: "A workspace with only outputs and no resources should set ResourceExists to true",
-			fields: fields{
-				tofu: &MockTofu{
-					MockDiff:             func(ctx context.Context, o ...opentofu.Option) (bool, error) { return false, nil },
-					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-					MockResources: func(ctx context.Context) ([]string, error) {
-						return nil, nil
-					},
-					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) {
-						return []opentofu.Output{
-							{Name: "string", Type: opentofu.OutputTypeString, Sensitive: false},
-							{Name: "object", Type: opentofu.OutputTypeObject, Sensitive: true},
-						}, nil
-					},
+		"TofuVersionUnsupported": {
+			reason: "We should refuse to run a version of tofu that isn't supported",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
+				},
+			},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockVersion: func(_ context.Context) (*opentofu.Version, error) { return opentofu.NewVersion("1.5.7", "linux_amd64") },
+					}
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
 					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
 						ForProvider: v1beta1.WorkspaceParameters{
-							PlanArgs: []string{"-refresh=false"},
+							Module:      "I'm HCL!",
+							Source:      v1beta1.ModuleSourceInline,
+							TofuVersion: "1.5.7",
 						},
 					},
 				},
 			},
-			want: want{
-				o: managed.ExternalObservation{
-					ResourceExists:   true,
-					ResourceUpToDate: true,
-					ConnectionDetails: managed.ConnectionDetails{
-						"string": {},
-						"object": []byte("null"), // Because we JSON decode the the value, which is any{}
+			want: errors.Wrap(errors.Errorf("tofu %s is not supported; the minimum supported version is %s", "1.5.7", opentofu.MinimumVersion), errTofuVersion),
+		},
+		"TofuVersionMismatch": {
+			reason: "We should refuse to run a tofu binary that isn't the version it was resolved to",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
+				},
+			},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockVersion: func(_ context.Context) (*opentofu.Version, error) {
+							return opentofu.NewVersion("1.10.0", "linux_amd64")
+						},
+					}
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module:      "I'm HCL!",
+							Source:      v1beta1.ModuleSourceInline,
+							TofuVersion: "1.9.0",
+						},
 					},
 				},
-				wo: v1beta1.WorkspaceObservation{
-					Checksum: tfChecksum,
-					Outputs: map[string]extensionsV1.JSON{
-						"string": {Raw: []byte("null")},
+			},
+			want: errors.Wrap(errors.Errorf(errFmtVersion, "tofu", "1.10.0", "1.9.0"), errTofuVersion),
+		},
+		"VersionError": {
+			reason: "We should return any error encountered while determining the tofu version",
+			fields: fields{kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+				MockScheme: func() *runtime.Scheme {
+					s := runtime.NewScheme()
+					if err := namespaced.AddToScheme(s); err != nil {
+						t.Fatal(err)
+					}
+					return s
+				},
+			},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockVersion: func(_ context.Context) (*opentofu.Version, error) { return nil, errBoom },
+					}
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
+						ForProvider: v1beta1.WorkspaceParameters{
+							Module: "I'm HCL!",
+							Source: v1beta1.ModuleSourceInline,
+						},
+					},
+				},
+			},
+			want: errors.Wrap(errBoom, errVersion),
+		},
+		"Success": {
+			reason: "We should not return an error when we successfully 'connect' to tofu",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
+						}
+						return s
+					},
+				},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockInit:             func(ctx context.Context, o ...opentofu.InitOption) error { return nil },
+						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+					}
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							InitArgs: []string{"-upgrade=true"},
+						},
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
+					},
+				},
+			},
+			want: nil,
+		},
+		"SuccessUsingBackendFile": {
+			reason: "We should not return an error when we successfully 'connect' to tofu using a Backend file",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if pc, ok := obj.(*v1beta1.ClusterProviderConfig); ok {
+							cfg := "I'm HCL!"
+							backendFile := "I'm a backend!"
+							pc.Spec.Configuration = &cfg
+							pc.Spec.BackendFile = &backendFile
+						}
+						return nil
+					}),
+					MockScheme: func() *runtime.Scheme {
+						s := runtime.NewScheme()
+						if err := namespaced.AddToScheme(s); err != nil {
+							t.Fatal(err)
+						}
+						return s
+					},
+				},
+				usage: clients.ModernTrackerFn(func(_ context.Context, _ resource.ModernManaged) error { return nil }),
+				fs:    afero.Afero{Fs: afero.NewMemMapFs()},
+				tofu: func(_, _ string, _ bool, _ bool, _ logging.Logger, _ ...string) tofuclient {
+					return &MockTofu{
+						MockInit: func(ctx context.Context, o ...opentofu.InitOption) error {
+							args := opentofu.InitArgsToString(o)
+							if len(args) != 2 {
+								return errors.New("two init args are expected")
+							} else if args[0] != "-backend-config=/tofu/no-you-id/crossplane.remote.tfbackend" {
+								return errors.Errorf("backend config arg has invalid value: %s", args[0])
+							}
+							return nil
+						},
+						MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+						MockWorkspace:        func(_ context.Context, _ string) error { return nil },
+					}
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{UID: uid},
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							InitArgs: []string{"-upgrade=true"},
+						},
+						ManagedResourceSpec: xpv2.ManagedResourceSpec{
+							ProviderConfigReference: &xpv1.ProviderConfigReference{
+								Kind: "ClusterProviderConfig",
+							},
+						},
 					},
 				},
 			},
+			want: nil,
 		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := external{tofu: tc.fields.tofu, kube: tc.fields.kube, logger: logging.NewNopLogger()}
-			got, err := e.Observe(tc.args.ctx, tc.args.mg)
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
-			}
-			if diff := cmp.Diff(tc.want.o, got); diff != "" {
-				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			versions := tc.fields.versions
+			if versions == nil {
+				versions = &MockTofuVersions{
+					MockResolve: func(_ context.Context, v string) (string, string, error) { return "tofu", v, nil },
+					MockRecord:  func(_, _ string) {},
+				}
 			}
-			if tc.args.mg != nil {
-				if diff := cmp.Diff(tc.want.wo, tc.args.mg.(*v1beta1.Workspace).Status.AtProvider); diff != "" {
-					t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			// Most cases don't care about the version of tofu, so clients
+			// report a supported version unless they mock it.
+			tofu := tc.fields.tofu
+			if tofu != nil {
+				tofu = func(path, dir string, usePluginCache, enableTofuCLILogging bool, logger logging.Logger, envs ...string) tofuclient {
+					tf := tc.fields.tofu(path, dir, usePluginCache, enableTofuCLILogging, logger, envs...)
+					if m, ok := tf.(*MockTofu); ok && m.MockVersion == nil {
+						m.MockVersion = func(_ context.Context) (*opentofu.Version, error) {
+							return opentofu.NewVersion("1.10.0", "linux_amd64")
+						}
+					}
+					return tf
 				}
 			}
+			c := connector{
+				kube:     tc.fields.kube,
+				usage:    tc.fields.usage,
+				fs:       tc.fields.fs,
+				modules:  tc.fields.modules,
+				versions: versions,
+				tofu:     tofu,
+				logger:   logging.NewNopLogger(),
+			}
+			_, err := c.Connect(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Connect(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
 		})
 	}
 }
 
-func TestCreate(t *testing.T) {
+func TestObserve(t *testing.T) {
 	errBoom := errors.New("boom")
-
+	now := metav1.Now()
 	type fields struct {
-		tofu tofuclient
-		kube client.Client
+		tofu        tofuclient
+		kube        client.Client
+		revision    string
+		observeOnly bool
 	}
 
 	type args struct {
@@ -1307,9 +1599,10 @@ func TestCreate(t *testing.T) {
 	}
 
 	type want struct {
-		c   managed.ExternalCreation
-		wo  v1beta1.WorkspaceObservation
-		err error
+		o       managed.ExternalObservation
+		wo      v1beta1.WorkspaceObservation
+		drifted *xpv1.Condition
+		err     error
 	}
 
 	cases := map[string]struct {
@@ -1414,268 +1707,2091 @@ func TestCreate(t *testing.T) {
 				err: errors.Wrap(errors.Wrap(errors.New("json: error calling MarshalJSON for type *runtime.RawExtension: cannot convert RawExtension with unrecognized content type to unstructured"), errVarMap), errOptions),
 			},
 		},
-		"ApplyError": {
-			reason: "We should return any error we encounter applying our tofu configuration",
+		"DiffError": {
+			reason: "We should return any error encountered while diffing the tofu configuration",
 			fields: fields{
 				tofu: &MockTofu{
-					MockApply: func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockPlan: func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return nil, errBoom },
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{},
 			},
 			want: want{
-				err: errors.Wrap(errBoom, errApply),
+				err: errors.Wrap(errBoom, errDiff),
 			},
 		},
-		"OutputsError": {
-			reason: "We should return any error we encounter getting our tofu outputs",
+		"DiffErrorDeletedWithExistingResources": {
+			reason: "We should return ResourceUpToDate true when resource is deleted and there are existing resources but tofu plan fails",
 			fields: fields{
 				tofu: &MockTofu{
-					MockApply:   func(_ context.Context, _ ...opentofu.Option) error { return nil },
-					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, errBoom },
+					MockPlan:             func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return nil, errBoom },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.very"}, nil
+					},
 				},
 			},
 			args: args{
-				mg: &v1beta1.Workspace{},
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{
+						DeletionTimestamp: &now,
+					},
+				},
 			},
 			want: want{
-				err: errors.Wrap(errBoom, errOutputs),
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum: tfChecksum,
+					Outputs:  map[string]extensionsV1.JSON{},
+				},
 			},
 		},
-		"Success": {
-			reason: "We should refresh our connection details with any updated outputs after successfully applying the tofu configuration",
+		"DiffErrorDeletedWithoutExistingResources": {
+			reason: "We should return ResourceUpToDate true when resource is deleted and there are no existing resources and tofu plan fails",
 			fields: fields{
 				tofu: &MockTofu{
-					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return nil },
-					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
-					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) {
-						return []opentofu.Output{
-							{Name: "string", Type: opentofu.OutputTypeString, Sensitive: true},
-							{Name: "object", Type: opentofu.OutputTypeObject, Sensitive: false},
-						}, nil
-					},
-				},
-				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockPlan:                   func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return nil, errBoom },
+					MockGenerateChecksum:       func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockOutputs:                func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+					MockResources:              func(ctx context.Context) ([]string, error) { return nil, nil },
+					MockDeleteCurrentWorkspace: func(ctx context.Context) error { return nil },
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
-					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							ApplyArgs: []string{"-refresh=false"},
-							Vars:      []v1beta1.Var{{Key: "super", Value: "cool"}},
-							VarFiles: []v1beta1.VarFile{
-								{
-									Source:                v1beta1.VarFileSourceConfigMapKey,
-									ConfigMapKeyReference: &v1beta1.KeyReference{},
-								},
-								{
-									Source:             v1beta1.VarFileSourceSecretKey,
-									SecretKeyReference: &v1beta1.KeyReference{},
-									Format:             &v1beta1.FileFormatJSON,
-								},
-							},
-						},
+					ObjectMeta: metav1.ObjectMeta{
+						DeletionTimestamp: &now,
 					},
 				},
 			},
 			want: want{
-				c: managed.ExternalCreation{
-					ConnectionDetails: managed.ConnectionDetails{
-						"string": {},
-						"object": []byte("null"), // Because we JSON decode the value, which is any{}
-					},
+				o: managed.ExternalObservation{
+					ResourceExists:    false,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
 				},
 				wo: v1beta1.WorkspaceObservation{
-					Outputs: map[string]extensionsV1.JSON{
-						"object": {Raw: []byte("null")},
-					},
+					Checksum: tfChecksum,
+					Outputs:  map[string]extensionsV1.JSON{},
 				},
 			},
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			e := external{tofu: tc.fields.tofu, kube: tc.fields.kube, logger: logging.NewNopLogger()}
-			got, err := e.Create(tc.args.ctx, tc.args.mg)
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
-			}
-			if diff := cmp.Diff(tc.want.c, got); diff != "" {
-				t.Errorf("\n%s\ne.Create(...): -want, +got:\n%s\n", tc.reason, diff)
-			}
-			if tc.args.mg != nil {
-				if diff := cmp.Diff(tc.want.wo, tc.args.mg.(*v1beta1.Workspace).Status.AtProvider); diff != "" {
-					t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
-				}
-			}
-		})
-	}
-}
-
-func TestDelete(t *testing.T) {
-	errBoom := errors.New("boom")
-
-	type fields struct {
-		tofu tofuclient
-		kube client.Client
-	}
-
-	type args struct {
-		ctx context.Context
-		mg  resource.Managed
-	}
-
-	cases := map[string]struct {
-		reason string
-		fields fields
-		args   args
-		want   error
-	}{
-		"NotAWorkspaceError": {
-			reason: "We should return an error if the supplied managed resource is not a Workspace",
-			args: args{
-				mg: nil,
-			},
-			want: errors.New(errNotWorkspace),
-		},
-		"GetConfigMapError": {
-			reason: "We should return any error we encounter getting tfvars from a ConfigMap",
+		"DiffErrorDeletedWithoutExistingResourcesWorkspaceDeleteError": {
+			reason: "We should return ResourceUpToDate true when resource is deleted and there are no existing resources and tofu plan fails",
 			fields: fields{
-				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if _, ok := obj.(*corev1.ConfigMap); ok {
-							return errBoom
-						}
-						return nil
-					}),
+				tofu: &MockTofu{
+					MockPlan:                   func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return nil, errBoom },
+					MockResources:              func(ctx context.Context) ([]string, error) { return nil, nil },
+					MockDeleteCurrentWorkspace: func(ctx context.Context) error { return errBoom },
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
-					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							VarFiles: []v1beta1.VarFile{
-								{
-									Source:                v1beta1.VarFileSourceConfigMapKey,
-									ConfigMapKeyReference: &v1beta1.KeyReference{},
-								},
-							},
-						},
+					ObjectMeta: metav1.ObjectMeta{
+						DeletionTimestamp: &now,
 					},
 				},
 			},
-			want: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
+			want: want{
+				err: errors.Wrap(errBoom, errDeleteWorkspace),
+			},
 		},
-		"GetSecretError": {
-			reason: "We should return any error we encounter getting tfvars from a Secret",
+		"ResourcesError": {
+			reason: "We should return any error encountered while listing extant tofu resources",
 			fields: fields{
-				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if _, ok := obj.(*corev1.Secret); ok {
-							return errBoom
-						}
-						return nil
-					}),
+				tofu: &MockTofu{
+					MockPlan:      func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockResources: func(ctx context.Context) ([]string, error) { return nil, errBoom },
 				},
 			},
 			args: args{
-				mg: &v1beta1.Workspace{
-					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							VarFiles: []v1beta1.VarFile{
-								{
-									Source:             v1beta1.VarFileSourceSecretKey,
-									SecretKeyReference: &v1beta1.KeyReference{},
-								},
-							},
-						},
-					},
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errResources),
+			},
+		},
+		"OutputsError": {
+			reason: "We should return any error encountered while listing tofu outputs",
+			fields: fields{
+				tofu: &MockTofu{
+					MockPlan:      func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockResources: func(ctx context.Context) ([]string, error) { return nil, nil },
+					MockOutputs:   func(ctx context.Context) ([]opentofu.Output, error) { return nil, errBoom },
 				},
 			},
-			want: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errOutputs),
+			},
 		},
-		"GetVarMapError": {
-			reason: "We should return any error we encounter getting tfvars from varmap",
+		"WorkspaceDoesNotExist": {
+			reason: "A workspace with zero resources should be considered to be non-existent",
 			fields: fields{
-				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
-						if _, ok := obj.(*corev1.Secret); ok {
-							return errBoom
-						}
-						return nil
-					}),
+				tofu: &MockTofu{
+					MockPlan:             func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources:        func(ctx context.Context) ([]string, error) { return []string{}, nil },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
 				},
 			},
 			args: args{
-				mg: &v1beta1.Workspace{
-					Spec: v1beta1.WorkspaceSpec{
-						ForProvider: v1beta1.WorkspaceParameters{
-							VarMap: &runtime.RawExtension{
-								Raw: []byte("I'm not JSON"),
-							},
-						},
-					},
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    false,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					PlanSummary: "0 to add, 0 to change, 0 to destroy",
+					Outputs:     map[string]extensionsV1.JSON{},
 				},
 			},
-			want: errors.Wrap(errors.Wrap(errors.New("json: error calling MarshalJSON for type *runtime.RawExtension: cannot convert RawExtension with unrecognized content type to unstructured"), errVarMap), errOptions),
 		},
-		"DestroyError": {
-			reason: "We should return any error we encounter destroying our tofu configuration",
+		"WorkspaceHasChanges": {
+			reason: "A workspace with a plan that has changes should not be up to date and should summarize the plan",
 			fields: fields{
 				tofu: &MockTofu{
-					MockDestroy: func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockPlan: func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) {
+						return &opentofu.Plan{
+							ResourceChanges: []opentofu.ResourceChange{
+								{Address: "cool_resource.new", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionCreate}}},
+								{Address: "cool_resource.old", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionDelete, opentofu.ActionCreate}}},
+								{Address: "cool_resource.same", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionNoOp}}},
+							},
+						}, nil
+					},
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.old", "cool_resource.same"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{},
 			},
-			want: errors.Wrap(errBoom, errDestroy),
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					Outputs:     map[string]extensionsV1.JSON{},
+					PlanSummary: "2 to add, 0 to change, 1 to destroy",
+				},
+			},
 		},
-		"Success": {
-			reason: "We should not return an error if we successfully destroy the tofu configuration",
+		"WorkspaceExists": {
+			reason: "A workspace with resources should return its outputs as connection details",
 			fields: fields{
 				tofu: &MockTofu{
-					MockDestroy: func(_ context.Context, _ ...opentofu.Option) error { return nil },
-				},
-				kube: &test.MockClient{
-					MockGet: test.NewMockGetFn(nil),
+					MockPlan:             func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.very"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) {
+						return []opentofu.Output{
+							{Name: "string", Type: opentofu.OutputTypeString, Sensitive: false},
+							{Name: "object", Type: opentofu.OutputTypeObject, Sensitive: true},
+						}, nil
+					},
 				},
 			},
 			args: args{
 				mg: &v1beta1.Workspace{
 					Spec: v1beta1.WorkspaceSpec{
 						ForProvider: v1beta1.WorkspaceParameters{
-							DestroyArgs: []string{"-refresh=false"},
-							Vars:        []v1beta1.Var{{Key: "super", Value: "cool"}},
-							VarFiles: []v1beta1.VarFile{
-								{
-									Source:                v1beta1.VarFileSourceConfigMapKey,
-									ConfigMapKeyReference: &v1beta1.KeyReference{},
-								},
-								{
-									Source:             v1beta1.VarFileSourceSecretKey,
-									SecretKeyReference: &v1beta1.KeyReference{},
-									Format:             &v1beta1.FileFormatJSON,
-								},
-							},
+							PlanArgs: []string{"-refresh=false"},
 						},
 					},
 				},
 			},
-			want: nil,
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+					ConnectionDetails: managed.ConnectionDetails{
+						"string": {},
+						"object": []byte("null"), // Because we JSON decode the the value, which is any{}
+					},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					PlanSummary: "0 to add, 0 to change, 0 to destroy",
+					Outputs: map[string]extensionsV1.JSON{
+						"string": {Raw: []byte("null")},
+					},
+				},
+			},
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			e := external{tofu: tc.fields.tofu, kube: tc.fields.kube, logger: logging.NewNopLogger()}
-			_, err := e.Delete(tc.args.ctx, tc.args.mg)
-			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+		"WorkspaceExistsOnlyOutputs": {
+			reason: "A workspace with only outputs and no resources should set ResourceExists to true",
+			fields: fields{
+				tofu: &MockTofu{
+					MockPlan:             func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return nil, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) {
+						return []opentofu.Output{
+							{Name: "string", Type: opentofu.OutputTypeString, Sensitive: false},
+							{Name: "object", Type: opentofu.OutputTypeObject, Sensitive: true},
+						}, nil
+					},
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							PlanArgs: []string{"-refresh=false"},
+						},
+					},
+				},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+					ConnectionDetails: managed.ConnectionDetails{
+						"string": {},
+						"object": []byte("null"), // Because we JSON decode the the value, which is any{}
+					},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					PlanSummary: "0 to add, 0 to change, 0 to destroy",
+					Outputs: map[string]extensionsV1.JSON{
+						"string": {Raw: []byte("null")},
+					},
+				},
+			},
+		},
+		"Drifted": {
+			reason: "Resources that changed outside of tofu should be reported as drifted",
+			fields: fields{
+				tofu: &MockTofu{
+					MockPlan: func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) {
+						return &opentofu.Plan{ResourceDrift: []opentofu.ResourceChange{
+							{Address: "cool_resource.very", Mode: "managed", Change: opentofu.Change{
+								Actions: []opentofu.Action{opentofu.ActionUpdate},
+								Before:  json.RawMessage(`{"coolness":"very","tags":{}}`),
+								After:   json.RawMessage(`{"coolness":"extremely","tags":{}}`),
+							}},
+						}}, nil
+					},
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.very"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					PlanSummary: "0 to add, 0 to change, 0 to destroy",
+					Outputs:     map[string]extensionsV1.JSON{},
+					Drift:       []v1beta1.DriftedResource{{Address: "cool_resource.very", Attributes: []string{"coolness"}}},
+				},
+				drifted: &xpv1.Condition{Type: v1beta1.TypeDrifted, Status: corev1.ConditionTrue, Reason: v1beta1.ReasonDriftDetected, Message: "resources changed outside of tofu: cool_resource.very"},
+			},
+		},
+		"ObserveOnly": {
+			reason: "An observe only workspace should be planned in refresh-only mode",
+			fields: fields{
+				tofu: &MockTofu{
+					MockRefreshOnlyPlan: func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) {
+						return &opentofu.Plan{ResourceDrift: []opentofu.ResourceChange{
+							{Address: "cool_resource.very", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionDelete}}},
+						}}, nil
+					},
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.very"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				observeOnly: true,
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					PlanSummary: "0 to add, 0 to change, 0 to destroy",
+					Outputs:     map[string]extensionsV1.JSON{},
+					Drift:       []v1beta1.DriftedResource{{Address: "cool_resource.very", Deleted: true}},
+				},
+				drifted: &xpv1.Condition{Type: v1beta1.TypeDrifted, Status: corev1.ConditionTrue, Reason: v1beta1.ReasonDriftDetected, Message: "resources changed outside of tofu: cool_resource.very"},
+			},
+		},
+		"Imports": {
+			reason: "We should report imports whose resources are in the state as imported, and others as pending",
+			fields: fields{
+				tofu: &MockTofu{
+					MockPlan:             func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"aws_vpc.main"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							Imports: []v1beta1.Import{
+								{Address: "aws_vpc.main", ID: "vpc-0123"},
+								{Address: "aws_subnet.main", ID: "subnet-0123"},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:    tfChecksum,
+					PlanSummary: "0 to add, 0 to change, 0 to destroy",
+					Outputs:     map[string]extensionsV1.JSON{},
+					Imports: []v1beta1.ImportStatus{
+						{Address: "aws_vpc.main", ID: "vpc-0123", Result: v1beta1.ImportResultImported},
+						{Address: "aws_subnet.main", ID: "subnet-0123", Result: v1beta1.ImportResultPending},
+					},
+				},
+			},
+		},
+		"ModuleRevisionApplied": {
+			reason: "We should report the observed module revision as applied when the workspace is up to date",
+			fields: fields{
+				tofu: &MockTofu{
+					MockPlan:             func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) { return &opentofu.Plan{}, nil },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.very"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				revision: "new",
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							ModuleRevision:        "old",
+							AppliedModuleRevision: "old",
+						},
+					},
+				},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:              tfChecksum,
+					PlanSummary:           "0 to add, 0 to change, 0 to destroy",
+					Outputs:               map[string]extensionsV1.JSON{},
+					ModuleRevision:        "new",
+					AppliedModuleRevision: "new",
+				},
+			},
+		},
+		"ModuleRevisionPending": {
+			reason: "We should report the observed module revision, and keep reporting the revision that was last applied until it is applied",
+			fields: fields{
+				tofu: &MockTofu{
+					MockPlan: func(ctx context.Context, o ...opentofu.Option) (*opentofu.Plan, error) {
+						return &opentofu.Plan{ResourceChanges: []opentofu.ResourceChange{{Address: "cool_resource.new", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionCreate}}}}}, nil
+					},
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockResources: func(ctx context.Context) ([]string, error) {
+						return []string{"cool_resource.very"}, nil
+					},
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				revision: "new",
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							ModuleRevision:        "old",
+							AppliedModuleRevision: "old",
+						},
+					},
+				},
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Checksum:              tfChecksum,
+					PlanSummary:           "1 to add, 0 to change, 0 to destroy",
+					Outputs:               map[string]extensionsV1.JSON{},
+					ModuleRevision:        "new",
+					AppliedModuleRevision: "old",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{tofu: tc.fields.tofu, kube: tc.fields.kube, logger: logging.NewNopLogger(), revision: tc.fields.revision, observeOnly: tc.fields.observeOnly}
+			got, err := e.Observe(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if tc.args.mg != nil {
+				if diff := cmp.Diff(tc.want.wo, tc.args.mg.(*v1beta1.Workspace).Status.AtProvider); diff != "" {
+					t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+				}
+				if tc.want.drifted != nil {
+					got := tc.args.mg.(*v1beta1.Workspace).Status.GetCondition(v1beta1.TypeDrifted)
+					if diff := cmp.Diff(*tc.want.drifted, got, cmpopts.IgnoreFields(xpv1.Condition{}, "LastTransitionTime")); diff != "" {
+						t.Errorf("\n%s\ne.Observe(...): -want drifted condition, +got drifted condition:\n%s\n", tc.reason, diff)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	errBoom := errors.New("boom")
+	destroy := &opentofu.Plan{ResourceChanges: []opentofu.ResourceChange{
+		{Address: "null_resource.a", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionDelete}}},
+	}}
+	create := &opentofu.Plan{ResourceChanges: []opentofu.ResourceChange{
+		{Address: "null_resource.a", Mode: "managed", Change: opentofu.Change{Actions: []opentofu.Action{opentofu.ActionCreate}}},
+	}}
+	requireForDestroy := v1beta1.ApprovalPolicyRequireForDestroy
+
+	type fields struct {
+		tofu     tofuclient
+		kube     client.Client
+		planKey  string
+		observed *opentofu.Plan
+		revision string
+	}
+
+	type args struct {
+		ctx context.Context
+		mg  resource.Managed
+	}
+
+	type want struct {
+		c        managed.ExternalCreation
+		wo       v1beta1.WorkspaceObservation
+		approval xpv1.ConditionReason
+		err      error
+	}
+
+	cases := map[string]struct {
+		reason string
+		fields fields
+		args   args
+		want   want
+	}{
+		"NotAWorkspaceError": {
+			reason: "We should return an error if the supplied managed resource is not a Workspace",
+			args: args{
+				mg: nil,
+			},
+			want: want{
+				err: errors.New(errNotWorkspace),
+			},
+		},
+		"GetConfigMapError": {
+			reason: "We should return any error we encounter getting tfvars from a ConfigMap",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if _, ok := obj.(*corev1.ConfigMap); ok {
+							return errBoom
+						}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							VarFiles: []v1beta1.VarFile{
+								{
+									Source:                v1beta1.VarFileSourceConfigMapKey,
+									ConfigMapKeyReference: &v1beta1.KeyReference{},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				err: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
+			},
+		},
+		"GetSecretError": {
+			reason: "We should return any error we encounter getting tfvars from a Secret",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if _, ok := obj.(*corev1.Secret); ok {
+							return errBoom
+						}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							VarFiles: []v1beta1.VarFile{
+								{
+									Source:             v1beta1.VarFileSourceSecretKey,
+									SecretKeyReference: &v1beta1.KeyReference{},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				err: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
+			},
+		},
+		"GetVarMapError": {
+			reason: "We should return any error we encounter getting tfvars from varmap",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if _, ok := obj.(*corev1.Secret); ok {
+							return errBoom
+						}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							VarMap: &runtime.RawExtension{
+								Raw: []byte("I'm not JSON"),
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				err: errors.Wrap(errors.Wrap(errors.New("json: error calling MarshalJSON for type *runtime.RawExtension: cannot convert RawExtension with unrecognized content type to unstructured"), errVarMap), errOptions),
+			},
+		},
+		"ApplyError": {
+			reason: "We should return any error we encounter applying our tofu configuration",
+			fields: fields{
+				tofu: &MockTofu{
+					MockApply: func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errApply),
+			},
+		},
+		"ApplySavedPlan": {
+			reason: "We should apply the plan saved by Observe if it is still current",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				planKey: planKey(tfChecksum, opentofu.WithArgs(nil)),
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs: map[string]extensionsV1.JSON{},
+				},
+			},
+		},
+		"ApplySavedPlanError": {
+			reason: "We should return any error we encounter applying the saved plan",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+				},
+				planKey: planKey(tfChecksum, opentofu.WithArgs(nil)),
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errApply),
+			},
+		},
+		"SavedPlanStale": {
+			reason: "We should plan again rather than apply the saved plan if the workspace changed since it was observed",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return "new" + tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				planKey: planKey(tfChecksum, opentofu.WithArgs(nil)),
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs: map[string]extensionsV1.JSON{},
+				},
+			},
+		},
+		"PendingApproval": {
+			reason: "We should not apply a plan that requires approval until it is approved",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+				},
+				planKey:  planKey(tfChecksum, opentofu.WithArgs(nil)),
+				observed: destroy,
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{Approval: &requireForDestroy},
+					},
+				},
+			},
+			want: want{
+				approval: v1beta1.ReasonAwaitingApproval,
+			},
+		},
+		"ApplyApprovedPlan": {
+			reason: "We should apply a plan that requires approval once its hash is annotated",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				planKey:  planKey(tfChecksum, opentofu.WithArgs(nil)),
+				observed: destroy,
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{v1beta1.AnnotationKeyApprovedPlan: destroy.Hash()},
+					},
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{Approval: &requireForDestroy},
+					},
+				},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs: map[string]extensionsV1.JSON{},
+				},
+				approval: v1beta1.ReasonPlanApproved,
+			},
+		},
+		"ApprovedPlanStale": {
+			reason: "We should not plan again if an approved plan is stale, because the new plan would not be approved",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return "new" + tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+				},
+				planKey:  planKey(tfChecksum, opentofu.WithArgs(nil)),
+				observed: destroy,
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{v1beta1.AnnotationKeyApprovedPlan: destroy.Hash()},
+					},
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{Approval: &requireForDestroy},
+					},
+				},
+			},
+			want: want{
+				err: errors.Wrap(errors.New(errApprovedStale), errApply),
+			},
+		},
+		"ApprovalNotRequired": {
+			reason: "We should apply a plan that does not destroy anything without approval when approval is only required for destroys",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				planKey:  planKey(tfChecksum, opentofu.WithArgs(nil)),
+				observed: create,
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{Approval: &requireForDestroy},
+					},
+				},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs: map[string]extensionsV1.JSON{},
+				},
+			},
+		},
+		"ApprovalPolicyRemoved": {
+			reason: "We should reset the PendingApproval condition when a plan no longer requires approval",
+			fields: fields{
+				tofu: &MockTofu{
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockApplyPlan:        func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockOutputs:          func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				planKey:  planKey(tfChecksum, opentofu.WithArgs(nil)),
+				observed: destroy,
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Status: v1beta1.WorkspaceStatus{
+						ResourceStatus: xpv1.ResourceStatus{
+							ConditionedStatus: xpv1.ConditionedStatus{Conditions: []xpv1.Condition{v1beta1.PendingApproval(destroy.Hash(), "")}},
+						},
+					},
+				},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs: map[string]extensionsV1.JSON{},
+				},
+				approval: v1beta1.ReasonApprovalNotRequired,
+			},
+		},
+		"OutputsError": {
+			reason: "We should return any error we encounter getting our tofu outputs",
+			fields: fields{
+				tofu: &MockTofu{
+					MockApply:   func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, errBoom },
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errOutputs),
+			},
+		},
+		"Success": {
+			reason: "We should refresh our connection details with any updated outputs after successfully applying the tofu configuration",
+			fields: fields{
+				tofu: &MockTofu{
+					MockApply:            func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockGenerateChecksum: func(ctx context.Context) (string, error) { return tfChecksum, nil },
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) {
+						return []opentofu.Output{
+							{Name: "string", Type: opentofu.OutputTypeString, Sensitive: true},
+							{Name: "object", Type: opentofu.OutputTypeObject, Sensitive: false},
+						}, nil
+					},
+				},
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							ApplyArgs: []string{"-refresh=false"},
+							Vars:      []v1beta1.Var{{Key: "super", Value: "cool"}},
+							VarFiles: []v1beta1.VarFile{
+								{
+									Source:                v1beta1.VarFileSourceConfigMapKey,
+									ConfigMapKeyReference: &v1beta1.KeyReference{},
+								},
+								{
+									Source:             v1beta1.VarFileSourceSecretKey,
+									SecretKeyReference: &v1beta1.KeyReference{},
+									Format:             &v1beta1.FileFormatJSON,
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{
+						"string": {},
+						"object": []byte("null"), // Because we JSON decode the value, which is any{}
+					},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs: map[string]extensionsV1.JSON{
+						"object": {Raw: []byte("null")},
+					},
+				},
+			},
+		},
+		"AppliedModuleRevision": {
+			reason: "We should report the module revision we applied",
+			fields: fields{
+				tofu: &MockTofu{
+					MockApply:   func(_ context.Context, _ ...opentofu.Option) error { return nil },
+					MockOutputs: func(ctx context.Context) ([]opentofu.Output, error) { return nil, nil },
+				},
+				revision: "new",
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Status: v1beta1.WorkspaceStatus{
+						AtProvider: v1beta1.WorkspaceObservation{
+							ModuleRevision:        "new",
+							AppliedModuleRevision: "old",
+						},
+					},
+				},
+			},
+			want: want{
+				c: managed.ExternalCreation{
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				wo: v1beta1.WorkspaceObservation{
+					Outputs:               map[string]extensionsV1.JSON{},
+					ModuleRevision:        "new",
+					AppliedModuleRevision: "new",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{tofu: tc.fields.tofu, kube: tc.fields.kube, logger: logging.NewNopLogger(), planKey: tc.fields.planKey, observed: tc.fields.observed, revision: tc.fields.revision}
+			got, err := e.Create(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.c, got); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if tc.args.mg != nil {
+				if diff := cmp.Diff(tc.want.wo, tc.args.mg.(*v1beta1.Workspace).Status.AtProvider); diff != "" {
+					t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+				}
+				if diff := cmp.Diff(tc.want.approval, tc.args.mg.(*v1beta1.Workspace).GetCondition(v1beta1.TypePendingApproval).Reason); diff != "" {
+					t.Errorf("\n%s\ne.Create(...): -want approval reason, +got approval reason:\n%s\n", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	errBoom := errors.New("boom")
+	now := metav1.Now()
+
+	type fields struct {
+		tofu tofuclient
+		kube client.Client
+	}
+
+	type args struct {
+		ctx context.Context
+		mg  resource.Managed
+	}
+
+	cases := map[string]struct {
+		reason string
+		fields fields
+		args   args
+		want   error
+	}{
+		"NotAWorkspaceError": {
+			reason: "We should return an error if the supplied managed resource is not a Workspace",
+			args: args{
+				mg: nil,
+			},
+			want: errors.New(errNotWorkspace),
+		},
+		"GetConfigMapError": {
+			reason: "We should return any error we encounter getting tfvars from a ConfigMap",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if _, ok := obj.(*corev1.ConfigMap); ok {
+							return errBoom
+						}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							VarFiles: []v1beta1.VarFile{
+								{
+									Source:                v1beta1.VarFileSourceConfigMapKey,
+									ConfigMapKeyReference: &v1beta1.KeyReference{},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
+		},
+		"GetSecretError": {
+			reason: "We should return any error we encounter getting tfvars from a Secret",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if _, ok := obj.(*corev1.Secret); ok {
+							return errBoom
+						}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							VarFiles: []v1beta1.VarFile{
+								{
+									Source:             v1beta1.VarFileSourceSecretKey,
+									SecretKeyReference: &v1beta1.KeyReference{},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: errors.Wrap(errors.Wrap(errBoom, errVarFile), errOptions),
+		},
+		"GetVarMapError": {
+			reason: "We should return any error we encounter getting tfvars from varmap",
+			fields: fields{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						if _, ok := obj.(*corev1.Secret); ok {
+							return errBoom
+						}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							VarMap: &runtime.RawExtension{
+								Raw: []byte("I'm not JSON"),
+							},
+						},
+					},
+				},
+			},
+			want: errors.Wrap(errors.Wrap(errors.New("json: error calling MarshalJSON for type *runtime.RawExtension: cannot convert RawExtension with unrecognized content type to unstructured"), errVarMap), errOptions),
+		},
+		"ProducerDeleted": {
+			reason: "We should not block deleting a Workspace on a Workspace it references that no longer exists",
+			fields: fields{
+				tofu: &MockTofu{
+					MockDestroy: func(_ context.Context, o ...opentofu.Option) error {
+						if diff := cmp.Diff(opentofu.Digest(opentofu.WithArgs(nil)), opentofu.Digest(o...)); diff != "" {
+							t.Errorf("Destroy(...): -want options, +got options:\n%s", diff)
+						}
+						return nil
+					},
+				},
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "network")),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", DeletionTimestamp: &now},
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							Vars: []v1beta1.Var{
+								{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"ProducerNotReady": {
+			reason: "We should destroy a deleted Workspace with the last outputs of a Workspace it references that is not ready",
+			fields: fields{
+				tofu: &MockTofu{
+					MockDestroy: func(_ context.Context, o ...opentofu.Option) error {
+						want := opentofu.Digest(opentofu.WithJSONVars(map[string]json.RawMessage{"subnets": json.RawMessage(`["a","b"]`)}), opentofu.WithArgs(nil))
+						if diff := cmp.Diff(want, opentofu.Digest(o...)); diff != "" {
+							t.Errorf("Destroy(...): -want options, +got options:\n%s", diff)
+						}
+						return nil
+					},
+				},
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						ws := obj.(*v1beta1.Workspace)
+						ws.SetConditions(xpv1.Deleting())
+						ws.Status.AtProvider.Outputs = map[string]extensionsV1.JSON{"subnets": {Raw: []byte(`["a","b"]`)}}
+						return nil
+					}),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", DeletionTimestamp: &now},
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							Vars: []v1beta1.Var{
+								{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"DestroyError": {
+			reason: "We should return any error we encounter destroying our tofu configuration",
+			fields: fields{
+				tofu: &MockTofu{
+					MockDestroy: func(_ context.Context, _ ...opentofu.Option) error { return errBoom },
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{},
+			},
+			want: errors.Wrap(errBoom, errDestroy),
+		},
+		"Success": {
+			reason: "We should not return an error if we successfully destroy the tofu configuration",
+			fields: fields{
+				tofu: &MockTofu{
+					MockDestroy: func(_ context.Context, _ ...opentofu.Option) error { return nil },
+				},
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil),
+				},
+			},
+			args: args{
+				mg: &v1beta1.Workspace{
+					Spec: v1beta1.WorkspaceSpec{
+						ForProvider: v1beta1.WorkspaceParameters{
+							DestroyArgs: []string{"-refresh=false"},
+							Vars:        []v1beta1.Var{{Key: "super", Value: "cool"}},
+							VarFiles: []v1beta1.VarFile{
+								{
+									Source:                v1beta1.VarFileSourceConfigMapKey,
+									ConfigMapKeyReference: &v1beta1.KeyReference{},
+								},
+								{
+									Source:             v1beta1.VarFileSourceSecretKey,
+									SecretKeyReference: &v1beta1.KeyReference{},
+									Format:             &v1beta1.FileFormatJSON,
+								},
+							},
+						},
+					},
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{tofu: tc.fields.tofu, kube: tc.fields.kube, logger: logging.NewNopLogger()}
+			_, err := e.Delete(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLocked(t *testing.T) {
+	errBoom := errors.New("boom")
+	created := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	lockedBy := func(who string) error {
+		return opentofu.Classify(&exec.ExitError{Stderr: []byte(fmt.Sprintf(`Error: Error acquiring the state lock
+
+Lock Info:
+  ID:        8c2a1c0e
+  Operation: OperationTypeApply
+  Who:       %s
+  Created:   %s
+`, who, created))})
+	}
+	errOwnLock := lockedBy(opentofu.LockHolder())
+	errOtherLock := lockedBy("alice@laptop")
+	forceUnlockAfter := &v1beta1.StateLockPolicy{ForceUnlockAfter: &metav1.Duration{Duration: time.Hour}}
+
+	type want struct {
+		err        error
+		lock       *v1beta1.StateLock
+		forceCalls int
+	}
+	cases := map[string]struct {
+		reason    string
+		stateLock *v1beta1.StateLockPolicy
+		errs      []error
+		forceErr  error
+		want      want
+	}{
+		"Succeeded": {
+			reason: "No lock should be recorded if the operation succeeds.",
+			errs:   []error{nil},
+		},
+		"NotLocked": {
+			reason: "No lock should be recorded if the operation fails for another reason.",
+			errs:   []error{errBoom},
+			want:   want{err: errBoom},
+		},
+		"Locked": {
+			reason: "The lock should be recorded if the state is locked and locks are never forced.",
+			errs:   []error{errOwnLock},
+			want: want{
+				err:  errOwnLock,
+				lock: &v1beta1.StateLock{ID: "8c2a1c0e", Holder: opentofu.LockHolder(), Operation: "OperationTypeApply", Created: &metav1.Time{Time: created}},
+			},
+		},
+		"LockedByOther": {
+			reason:    "A stale lock acquired by someone else should not be forced.",
+			stateLock: forceUnlockAfter,
+			errs:      []error{errOtherLock},
+			want: want{
+				err:  errOtherLock,
+				lock: &v1beta1.StateLock{ID: "8c2a1c0e", Holder: "alice@laptop", Operation: "OperationTypeApply", Created: &metav1.Time{Time: created}},
+			},
+		},
+		"LockNotStale": {
+			reason:    "A lock younger than the policy allows should not be forced.",
+			stateLock: &v1beta1.StateLockPolicy{ForceUnlockAfter: &metav1.Duration{Duration: 3 * time.Hour}},
+			errs:      []error{errOwnLock},
+			want: want{
+				err:  errOwnLock,
+				lock: &v1beta1.StateLock{ID: "8c2a1c0e", Holder: opentofu.LockHolder(), Operation: "OperationTypeApply", Created: &metav1.Time{Time: created}},
+			},
+		},
+		"ForceUnlockError": {
+			reason:    "Errors forcing a stale lock should be returned.",
+			stateLock: forceUnlockAfter,
+			errs:      []error{errOwnLock},
+			forceErr:  errBoom,
+			want: want{
+				err:        errors.Wrap(errBoom, errForceUnlock),
+				lock:       &v1beta1.StateLock{ID: "8c2a1c0e", Holder: opentofu.LockHolder(), Operation: "OperationTypeApply", Created: &metav1.Time{Time: created}},
+				forceCalls: 1,
+			},
+		},
+		"ForceUnlocked": {
+			reason:    "A stale lock acquired by this provider should be forced and the operation retried.",
+			stateLock: forceUnlockAfter,
+			errs:      []error{errOwnLock, nil},
+			want:      want{forceCalls: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			forceCalls := 0
+			e := external{
+				tofu: &MockTofu{
+					MockForceUnlock: func(_ context.Context, id string) error {
+						forceCalls++
+						if id != "8c2a1c0e" {
+							t.Errorf("ForceUnlock(...): want lock 8c2a1c0e, got %s", id)
+						}
+						return tc.forceErr
+					},
+				},
+				logger:    logging.NewNopLogger(),
+				stateLock: tc.stateLock,
+			}
+			cr := &v1beta1.Workspace{}
+			calls := 0
+			err := e.locked(context.Background(), cr, func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.locked(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.lock, cr.Status.AtProvider.StateLock); diff != "" {
+				t.Errorf("\n%s\ne.locked(...): -want lock, +got lock:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.forceCalls, forceCalls); diff != "" {
+				t.Errorf("\n%s\ne.locked(...): -want force unlock calls, +got force unlock calls:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		rc     *v1beta1.ReadinessCheck
+		op     []opentofu.Output
+		want   xpv1.Condition
+	}{
+		"NoCheck": {
+			reason: "A workspace without a readiness check should be available",
+			want:   xpv1.Available(),
+		},
+		"MissingOutput": {
+			reason: "A workspace should be unavailable if the checked output does not exist",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "endpoint", Type: v1beta1.ReadinessCheckTypeNonEmpty},
+			want:   xpv1.Unavailable().WithMessage(`output "endpoint" does not exist`),
+		},
+		"MatchTrue": {
+			reason: "A workspace should be available if the checked output is true",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "healthy", Type: v1beta1.ReadinessCheckTypeMatchTrue},
+			op:     []opentofu.Output{opentofu.NewOutput("healthy", opentofu.OutputTypeBool, false, true)},
+			want:   xpv1.Available(),
+		},
+		"NotTrue": {
+			reason: "A workspace should be unavailable if the checked output is not true",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "healthy", Type: v1beta1.ReadinessCheckTypeMatchTrue},
+			op:     []opentofu.Output{opentofu.NewOutput("healthy", opentofu.OutputTypeString, false, "true")},
+			want:   xpv1.Unavailable().WithMessage(`output "healthy" is not true`),
+		},
+		"MatchStringValue": {
+			reason: "A workspace should be available if the checked string output equals the value",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "status", Type: v1beta1.ReadinessCheckTypeMatchValue, Value: "ACTIVE"},
+			op:     []opentofu.Output{opentofu.NewOutput("status", opentofu.OutputTypeString, false, "ACTIVE")},
+			want:   xpv1.Available(),
+		},
+		"MatchNumberValue": {
+			reason: "Outputs that are not strings should be compared using their JSON encoding",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "replicas", Type: v1beta1.ReadinessCheckTypeMatchValue, Value: "3"},
+			op:     []opentofu.Output{opentofu.NewOutput("replicas", opentofu.OutputTypeNumber, false, float64(3))},
+			want:   xpv1.Available(),
+		},
+		"ValueDoesNotMatch": {
+			reason: "A workspace should be unavailable if the checked output does not equal the value",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "status", Type: v1beta1.ReadinessCheckTypeMatchValue, Value: "ACTIVE"},
+			op:     []opentofu.Output{opentofu.NewOutput("status", opentofu.OutputTypeString, false, "CREATING")},
+			want:   xpv1.Unavailable().WithMessage(`output "status" does not equal "ACTIVE"`),
+		},
+		"NonEmpty": {
+			reason: "A workspace should be available if the checked output is not empty",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "endpoint", Type: v1beta1.ReadinessCheckTypeNonEmpty},
+			op:     []opentofu.Output{opentofu.NewOutput("endpoint", opentofu.OutputTypeString, true, "https://example.org")},
+			want:   xpv1.Available(),
+		},
+		"Empty": {
+			reason: "A workspace should be unavailable if the checked output is empty",
+			rc:     &v1beta1.ReadinessCheck{OutputName: "endpoints", Type: v1beta1.ReadinessCheckTypeNonEmpty},
+			op:     []opentofu.Output{opentofu.NewOutput("endpoints", opentofu.OutputTypeTuple, false, []any{})},
+			want:   xpv1.Unavailable().WithMessage(`output "endpoints" is empty`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := readiness(tc.rc, tc.op)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nreadiness(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOp2CD(t *testing.T) {
+	op := []opentofu.Output{
+		opentofu.NewOutput("endpoint", opentofu.OutputTypeString, false, "https://example.org"),
+		opentofu.NewOutput("db-credentials", opentofu.OutputTypeObject, true, map[string]any{"username": "admin", "password": "hunter2"}),
+		opentofu.NewOutput("ports", opentofu.OutputTypeTuple, false, []any{float64(80), float64(443)}),
+	}
+
+	type want struct {
+		cd  managed.ConnectionDetails
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		cds    []v1beta1.ConnectionDetail
+		want   want
+	}{
+		"AllOutputs": {
+			reason: "All outputs should be written, keyed by name, if no connection details are specified",
+			want: want{
+				cd: managed.ConnectionDetails{
+					"endpoint":       []byte("https://example.org"),
+					"db-credentials": []byte(`{"password":"hunter2","username":"admin"}`),
+					"ports":          []byte("[80,443]"),
+				},
+			},
+		},
+		"SelectedOutputs": {
+			reason: "Only the specified connection details should be written",
+			cds: []v1beta1.ConnectionDetail{
+				{OutputName: "endpoint", Key: "url"},
+				{OutputName: "db-credentials", FieldPath: "password", Encoding: v1beta1.ConnectionDetailEncodingBase64},
+				{OutputName: "ports", Key: "https-port", FieldPath: "[1]"},
+			},
+			want: want{
+				cd: managed.ConnectionDetails{
+					"url":            []byte("https://example.org"),
+					"db-credentials": []byte("aHVudGVyMg=="),
+					"https-port":     []byte("443"),
+				},
+			},
+		},
+		"MissingValues": {
+			reason: "Outputs and values within outputs that don't exist should be omitted",
+			cds: []v1beta1.ConnectionDetail{
+				{OutputName: "kubeconfig"},
+				{OutputName: "db-credentials", FieldPath: "host"},
+			},
+			want: want{
+				cd: managed.ConnectionDetails{},
+			},
+		},
+		"InvalidFieldPath": {
+			reason: "We should return an error if a field path cannot be resolved",
+			cds: []v1beta1.ConnectionDetail{
+				{OutputName: "endpoint", FieldPath: "host"},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := op2cd(tc.cds, op)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nop2cd(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cd, got); diff != "" {
+				t.Errorf("\n%s\nop2cd(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOp2CM(t *testing.T) {
+	op := []opentofu.Output{
+		opentofu.NewOutput("endpoint", opentofu.OutputTypeString, false, "https://example.org"),
+		opentofu.NewOutput("password", opentofu.OutputTypeString, true, "hunter2"),
+		opentofu.NewOutput("cluster", opentofu.OutputTypeObject, false, map[string]any{
+			"name": "example",
+			"node": map[string]any{"count": float64(3)},
+			"tags": []any{"a", "b"},
+		}),
+	}
+
+	cases := map[string]struct {
+		reason string
+		ref    *v1beta1.OutputsConfigMapReference
+		want   map[string]string
+	}{
+		"AllOutputs": {
+			reason: "All non-sensitive outputs should be written if none are selected",
+			ref:    &v1beta1.OutputsConfigMapReference{},
+			want: map[string]string{
+				"endpoint": "https://example.org",
+				"cluster":  `{"name":"example","node":{"count":3},"tags":["a","b"]}`,
+			},
+		},
+		"SelectedOutputs": {
+			reason: "Only selected outputs should be written, and sensitive outputs should never be written",
+			ref:    &v1beta1.OutputsConfigMapReference{Outputs: []string{"endpoint", "password"}},
+			want: map[string]string{
+				"endpoint": "https://example.org",
+			},
+		},
+		"Flatten": {
+			reason: "Object outputs should be flattened into one key per field",
+			ref:    &v1beta1.OutputsConfigMapReference{Outputs: []string{"cluster"}, Flatten: true},
+			want: map[string]string{
+				"cluster.name":       "example",
+				"cluster.node.count": "3",
+				"cluster.tags":       `["a","b"]`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := op2cm(tc.ref, op)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nop2cm(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPublishOutputs(t *testing.T) {
+	op := []opentofu.Output{
+		opentofu.NewOutput("endpoint", opentofu.OutputTypeString, false, "https://example.org"),
+	}
+	cr := &v1beta1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default", UID: "cool-uid"},
+		Spec: v1beta1.WorkspaceSpec{
+			ForProvider: v1beta1.WorkspaceParameters{
+				OutputsConfigMapRef: &v1beta1.OutputsConfigMapReference{Name: "outputs"},
+			},
+		},
+	}
+	owner := meta.AsController(meta.TypedReferenceTo(cr, v1beta1.WorkspaceGroupVersionKind))
+
+	type want struct {
+		cm  *corev1.ConfigMap
+		err error
+	}
+	cases := map[string]struct {
+		reason   string
+		existing *corev1.ConfigMap
+		want     want
+	}{
+		"Create": {
+			reason: "We should create a ConfigMap controlled by the Workspace if none exists.",
+			want: want{
+				cm: &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "outputs",
+						Namespace:       "default",
+						OwnerReferences: []metav1.OwnerReference{owner},
+					},
+					Data: map[string]string{"endpoint": "https://example.org"},
+				},
+			},
+		},
+		"UpdateControlled": {
+			reason: "We should update a ConfigMap the Workspace controls.",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "outputs",
+					Namespace:       "default",
+					ResourceVersion: "1",
+					OwnerReferences: []metav1.OwnerReference{owner},
+				},
+				Data: map[string]string{"endpoint": "https://old.example.org"},
+			},
+			want: want{
+				cm: &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "outputs",
+						Namespace:       "default",
+						ResourceVersion: "1",
+						OwnerReferences: []metav1.OwnerReference{owner},
+					},
+					Data: map[string]string{"endpoint": "https://example.org"},
+				},
+			},
+		},
+		"NotControlled": {
+			reason: "We should refuse to adopt and overwrite a ConfigMap the Workspace doesn't control.",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "outputs",
+					Namespace:       "default",
+					ResourceVersion: "1",
+				},
+				Data: map[string]string{"important": "data"},
+			},
+			want: want{
+				err: errors.Wrap(errors.New(errNotControlled), errPublishOutputs),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *corev1.ConfigMap
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					if tc.existing == nil {
+						return kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "outputs")
+					}
+					tc.existing.DeepCopyInto(obj.(*corev1.ConfigMap))
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					got = obj.(*corev1.ConfigMap)
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					got = obj.(*corev1.ConfigMap)
+					return nil
+				},
+			}
+
+			e := external{kube: kube, logger: logging.NewNopLogger()}
+			err := e.publishOutputs(context.Background(), cr, op)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.publishOutputs(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cm, got); diff != "" {
+				t.Errorf("\n%s\ne.publishOutputs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOptionsVars(t *testing.T) {
+	type want struct {
+		o   []opentofu.Option
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Client
+		p      v1beta1.WorkspaceParameters
+		want   want
+	}{
+		"TypedVars": {
+			reason: "Plain variables should be passed as is, JSON variables written to a JSON var file, and HCL variables to an HCL var file",
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{
+					{Key: "name", Value: "cool"},
+					{Key: "subnets", Value: `["a","b"]`},
+					{Key: "zones", JSONValue: &runtime.RawExtension{Raw: []byte(`["a","b"]`)}},
+					{Key: "tags", HCLValue: `{ team = "platform" }`},
+				},
+			},
+			want: want{
+				o: []opentofu.Option{
+					opentofu.WithVar("name", "cool"),
+					opentofu.WithVar("subnets", `["a","b"]`),
+					opentofu.WithJSONVars(map[string]json.RawMessage{"zones": json.RawMessage(`["a","b"]`)}),
+					opentofu.WithHCLVars(map[string]string{"tags": `{ team = "platform" }`}),
+				},
+			},
+		},
+		"ValueFrom": {
+			reason: "Variables read from ConfigMaps should be written to a JSON var file, and variables read from Secrets should be sensitive",
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *corev1.ConfigMap:
+						o.Data = map[string]string{"region": "us-east-1"}
+					case *corev1.Secret:
+						o.Data = map[string][]byte{"password": []byte("hunter2")}
+					}
+					return nil
+				},
+			},
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{
+					{Key: "region", ValueFrom: &v1beta1.VarSource{ConfigMapKeyReference: &v1beta1.KeyReference{Name: "cm", Key: "region"}}},
+					{Key: "password", ValueFrom: &v1beta1.VarSource{SecretKeyReference: &v1beta1.KeyReference{Name: "s", Key: "password"}}},
+				},
+			},
+			want: want{
+				o: []opentofu.Option{
+					opentofu.WithJSONVars(map[string]json.RawMessage{"region": json.RawMessage(`"us-east-1"`)}),
+					opentofu.WithSensitiveVars(map[string]string{"password": "hunter2"}),
+				},
+			},
+		},
+		"ValueFromMissingKey": {
+			reason: "We should return an error if a variable's Secret key does not exist",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+			},
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{
+					{Key: "password", ValueFrom: &v1beta1.VarSource{SecretKeyReference: &v1beta1.KeyReference{Name: "s", Key: "password"}}},
+				},
+			},
+			want: want{
+				err: errors.Wrap(errors.New("couldn't find key password in Secret default/s"), errVarResolution),
+			},
+		},
+		"WorkspaceOutputRef": {
+			reason: "Variables read from the output of a ready Workspace should be written to a JSON var file",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+					ws := obj.(*v1beta1.Workspace)
+					ws.SetConditions(xpv1.Available())
+					ws.Status.AtProvider.Outputs = map[string]extensionsV1.JSON{"subnets": {Raw: []byte(`["a","b"]`)}}
+					return nil
+				}),
+			},
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{
+					{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+				},
+			},
+			want: want{
+				o: []opentofu.Option{
+					opentofu.WithJSONVars(map[string]json.RawMessage{"subnets": json.RawMessage(`["a","b"]`)}),
+				},
+			},
+		},
+		"WorkspaceNotReady": {
+			reason: "We should wait for a referenced Workspace to be ready",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil),
+			},
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{
+					{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+				},
+			},
+			want: want{
+				err: errors.Wrap(&waitingError{msg: `waiting for Workspace "network" to be ready`}, errVarResolution),
+			},
+		},
+		"WorkspaceOutputMissing": {
+			reason: "We should wait for a referenced Workspace to have the referenced output",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+					obj.(*v1beta1.Workspace).SetConditions(xpv1.Available())
+					return nil
+				}),
+			},
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{
+					{Key: "subnets", ValueFrom: &v1beta1.VarSource{WorkspaceOutputReference: &v1beta1.WorkspaceOutputReference{Name: "network", Output: "subnets"}}},
+				},
+			},
+			want: want{
+				err: errors.Wrap(&waitingError{msg: `waiting for Workspace "network" to have non-sensitive output "subnets"`}, errVarResolution),
+			},
+		},
+		"InvalidHCLVarName": {
+			reason: "We should return an error if an HCL variable's name is not a valid identifier",
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{{Key: "a = 1\nb", HCLValue: "2"}},
+			},
+			want: want{
+				err: errors.Errorf(errVarName, "a = 1\nb"),
+			},
+		},
+		"InvalidJSONVar": {
+			reason: "We should return an error if a JSON variable is not valid JSON",
+			p: v1beta1.WorkspaceParameters{
+				Vars: []v1beta1.Var{{Key: "zones", JSONValue: &runtime.RawExtension{Raw: []byte(`["a",`)}}},
+			},
+			want: want{
+				err: errors.Errorf(errVarJSON, "zones"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: tc.kube, logger: logging.NewNopLogger()}
+			got, err := e.options(context.Background(), &v1beta1.Workspace{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.WorkspaceSpec{ForProvider: tc.p}})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.options(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(opentofu.Digest(tc.want.o...), opentofu.Digest(got...)); diff != "" {
+				t.Errorf("\n%s\ne.options(...): -want digest, +got digest:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateInlineFiles(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		files  map[string]v1beta1.InlineFile
+		module bool
+		want   error
+	}{
+		"Valid": {
+			reason: "Files within the workspace directory in a valid format should be valid.",
+			files: map[string]v1beta1.InlineFile{
+				"variables.tf":       {Content: `variable "a" {}`},
+				"outputs.tf.json":    {Content: `{"output": {}}`},
+				"templates/user.tpl": {Content: `${a}`},
+				"versions.tf":        {Content: `terraform {}`, Format: &v1beta1.FileFormatHCL},
+			},
+			module: true,
+		},
+		"ParentDirectory": {
+			reason: "Files outside the workspace directory should be invalid.",
+			files:  map[string]v1beta1.InlineFile{"../main.tf": {}},
+			want:   errors.Errorf(errFmtInlinePath, "../main.tf"),
+		},
+		"AbsolutePath": {
+			reason: "Files with absolute paths should be invalid.",
+			files:  map[string]v1beta1.InlineFile{"/etc/passwd": {}},
+			want:   errors.Errorf(errFmtInlinePath, "/etc/passwd"),
+		},
+		"UncleanPath": {
+			reason: "Files with paths that aren't clean should be invalid.",
+			files:  map[string]v1beta1.InlineFile{"a/../main.tf": {}},
+			want:   errors.Errorf(errFmtInlinePath, "a/../main.tf"),
+		},
+		"InvalidJSON": {
+			reason: "Files in JSON format should be valid JSON.",
+			files:  map[string]v1beta1.InlineFile{"main.tf.json": {Content: `{`}},
+			want:   errors.Errorf(errFmtInlineJSON, "main.tf.json"),
+		},
+		"MainConflict": {
+			reason: "A main file should be invalid if there is an inline module.",
+			files:  map[string]v1beta1.InlineFile{"main.tf": {}},
+			module: true,
+			want:   errors.Errorf(errFmtInlineMain, "main.tf"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateInlineFiles(tc.files, tfMain, tc.module)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nvalidateInlineFiles(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGitEnvDigest(t *testing.T) {
+	secret := func(data string) xpv1.CommonCredentialSelectors {
+		return xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Name: data}, Key: "key"}}
+	}
+	spec := func(creds, key string) v1beta1.ProviderConfigSpec {
+		return v1beta1.ProviderConfigSpec{
+			Credentials: []v1beta1.ProviderCredentials{{Filename: ".git-credentials", Source: xpv1.CredentialsSourceSecret, CommonCredentialSelectors: secret(creds)}},
+			GitSSH: &v1beta1.GitSSHConfig{
+				Keys: []v1beta1.GitSSHKey{{Host: "github.com", GitSSHCredentials: v1beta1.GitSSHCredentials{Source: xpv1.CredentialsSourceSecret, CommonCredentialSelectors: secret(key)}}},
+			},
+		}
+	}
+	c := &connector{
+		kube: &test.MockClient{
+			// The content of each Secret is its name.
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				obj.(*corev1.Secret).Data = map[string][]byte{"key": []byte(key.Name)}
+				return nil
+			},
+		},
+		fs: afero.Afero{Fs: afero.NewMemMapFs()},
+	}
+	digest := func(spec v1beta1.ProviderConfigSpec, dir string) string {
+		_, d, err := c.gitEnv(context.Background(), spec, dir)
+		if err != nil {
+			t.Fatalf("c.gitEnv(...): %v", err)
+		}
+		return d
+	}
+
+	if diff := cmp.Diff(digest(spec("creds", "key"), "/tmp/a"), digest(spec("creds", "key"), "/tmp/b")); diff != "" {
+		t.Errorf("c.gitEnv(...): the digest of the same credentials written to different directories should be equal: -a, +b:\n%s", diff)
+	}
+	if digest(spec("creds", "key"), "/tmp/a") == digest(spec("creds", "other"), "/tmp/a") {
+		t.Errorf("c.gitEnv(...): the digests of different credentials should differ")
+	}
+}
+
+func TestWriteInlineModule(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	dir := "/ws"
+
+	if err := writeInlineModule(fs, dir, v1beta1.WorkspaceParameters{Module: "a"}); err != nil {
+		t.Fatalf("writeInlineModule(...): %v", err)
+	}
+	if err := writeInlineModule(fs, dir, v1beta1.WorkspaceParameters{
+		Files: map[string]v1beta1.InlineFile{"variables.tf": {Content: "b"}},
+	}); err != nil {
+		t.Fatalf("writeInlineModule(...): %v", err)
+	}
+
+	want := map[string]string{
+		"variables.tf":      "b",
+		moduleFilesManifest: "variables.tf",
+	}
+	got := map[string]string{}
+	_ = fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, _ := fs.ReadFile(path)
+		got[strings.TrimPrefix(path, dir+"/")] = string(b)
+		return nil
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("writeInlineModule(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWriteModuleFiles(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	dir := "/ws"
+
+	if err := writeModuleFiles(fs, dir, map[string][]byte{
+		"variables.tf":       []byte("a"),
+		"templates/user.tpl": []byte("b"),
+	}); err != nil {
+		t.Fatalf("writeModuleFiles(...): %v", err)
+	}
+	if err := fs.WriteFile(filepath.Join(dir, tfConfig), []byte("c"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeModuleFiles(fs, dir, map[string][]byte{
+		"variables.tf": []byte("d"),
+	}); err != nil {
+		t.Fatalf("writeModuleFiles(...): %v", err)
+	}
+
+	want := map[string]string{
+		"variables.tf":      "d",
+		tfConfig:            "c",
+		moduleFilesManifest: "variables.tf",
+	}
+	got := map[string]string{}
+	_ = fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, _ := fs.ReadFile(path)
+		got[strings.TrimPrefix(path, dir+"/")] = string(b)
+		return nil
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("writeModuleFiles(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWriteImports(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	dir := "/ws"
+
+	imports := []v1beta1.Import{
+		{Address: "aws_vpc.main", ID: "vpc-0123"},
+		{Address: `module.net.aws_subnet.main["a"]`, ID: "${subnet}", Provider: "aws.west"},
+	}
+	if err := writeImports(fs, dir, imports); err != nil {
+		t.Fatalf("writeImports(...): %v", err)
+	}
+	got, _ := fs.ReadFile(filepath.Join(dir, tfImports))
+	want := `{"import":[{"to":"aws_vpc.main","id":"vpc-0123"},{"to":"module.net.aws_subnet.main[\"a\"]","id":"$${subnet}","provider":"aws.west"}]}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("writeImports(...): -want, +got:\n%s", diff)
+	}
+
+	// The imports file should be removed once there are no imports, and
+	// removing it again should not be an error.
+	for range 2 {
+		if err := writeImports(fs, dir, nil); err != nil {
+			t.Fatalf("writeImports(...): %v", err)
+		}
+	}
+	if exists, _ := fs.Exists(filepath.Join(dir, tfImports)); exists {
+		t.Errorf("writeImports(...): want %s removed", tfImports)
+	}
+}
+
+func TestFailedImports(t *testing.T) {
+	errBoom := errors.New("boom")
+	imports := []v1beta1.Import{
+		{Address: "aws_vpc.main", ID: "vpc-0123"},
+		{Address: "aws_subnet.main", ID: "subnet-0123"},
+		{Address: "aws_subnet.other", ID: "subnet-4567"},
+	}
+	previous := []v1beta1.ImportStatus{
+		{Address: "aws_vpc.main", ID: "vpc-0123", Result: v1beta1.ImportResultImported},
+		{Address: "aws_subnet.other", ID: "subnet-0000", Result: v1beta1.ImportResultImported},
+	}
+	errDiags := errors.Wrap(&opentofu.DiagnosticsError{Diagnostics: []opentofu.Diagnostic{
+		{Severity: opentofu.SeverityError, Summary: "Cannot import non-existent remote object", Address: "aws_subnet.main"},
+	}}, errDiff)
+
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   []v1beta1.ImportStatus
+	}{
+		"Diagnostics": {
+			reason: "Imports the diagnostics concern should fail, and others keep their result unless their ID changed.",
+			err:    errDiags,
+			want: []v1beta1.ImportStatus{
+				{Address: "aws_vpc.main", ID: "vpc-0123", Result: v1beta1.ImportResultImported},
+				{Address: "aws_subnet.main", ID: "subnet-0123", Result: v1beta1.ImportResultFailed, Message: "aws_subnet.main: Cannot import non-existent remote object"},
+				{Address: "aws_subnet.other", ID: "subnet-4567", Result: v1beta1.ImportResultPending},
+			},
+		},
+		"NoDiagnostics": {
+			reason: "No import should fail if the error has no diagnostics.",
+			err:    errBoom,
+			want: []v1beta1.ImportStatus{
+				{Address: "aws_vpc.main", ID: "vpc-0123", Result: v1beta1.ImportResultImported},
+				{Address: "aws_subnet.main", ID: "subnet-0123", Result: v1beta1.ImportResultPending},
+				{Address: "aws_subnet.other", ID: "subnet-4567", Result: v1beta1.ImportResultPending},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := failedImports(imports, previous, tc.err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nfailedImports(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestModuleFiles(t *testing.T) {
+	errBoom := errors.New("boom")
+	kube := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				switch key.Name {
+				case "module":
+					o.Data = map[string]string{"main.tf": "a", "modules__vpc__main.tf": "b"}
+					o.BinaryData = map[string][]byte{"files__logo.png": []byte("c")}
+				case "escape":
+					o.Data = map[string]string{"..__main.tf": "d"}
+				case "ambiguous":
+					o.Data = map[string]string{"modules___vpc.tf": "f"}
+				}
+			case *corev1.Secret:
+				if key.Name != "module" {
+					return errBoom
+				}
+				o.Data = map[string][]byte{"secrets.tf": []byte("e")}
+			}
+			return nil
+		},
+	}
+
+	cases := map[string]struct {
+		reason string
+		refs   []v1beta1.ModuleFilesReference
+		want   map[string][]byte
+		err    error
+	}{
+		"Files": {
+			reason: "Every key of every referenced object should be a file, with nested paths encoded in keys.",
+			refs: []v1beta1.ModuleFilesReference{
+				{Name: "module"},
+				{Kind: v1beta1.ModuleFilesKindSecret, Name: "module", Path: "secrets"},
+			},
+			want: map[string][]byte{
+				"main.tf":             []byte("a"),
+				"modules/vpc/main.tf": []byte("b"),
+				"files/logo.png":      []byte("c"),
+				"secrets/secrets.tf":  []byte("e"),
+			},
+		},
+		"GetError": {
+			reason: "Errors getting a referenced object should be returned.",
+			refs:   []v1beta1.ModuleFilesReference{{Kind: v1beta1.ModuleFilesKindSecret, Name: "missing"}},
+			err:    errors.Wrapf(errBoom, errFmtModuleRef, v1beta1.ModuleFilesKindSecret, types.NamespacedName{Namespace: "default", Name: "missing"}),
+		},
+		"ParentDirectory": {
+			reason: "Keys that would be written outside the module directory should be rejected.",
+			refs:   []v1beta1.ModuleFilesReference{{Name: "escape"}},
+			err:    errors.Errorf(errFmtModulePath, "../main.tf", "..__main.tf", v1beta1.ModuleFilesKindConfigMap, types.NamespacedName{Namespace: "default", Name: "escape"}),
+		},
+		"AmbiguousKey": {
+			reason: "Keys that don't identify exactly one path should be rejected.",
+			refs:   []v1beta1.ModuleFilesReference{{Name: "ambiguous"}},
+			err:    errors.Errorf(errFmtModuleKey, "modules___vpc.tf", v1beta1.ModuleFilesKindConfigMap, types.NamespacedName{Namespace: "default", Name: "ambiguous"}),
+		},
+		"Duplicate": {
+			reason: "Files defined by more than one referenced object should be rejected.",
+			refs:   []v1beta1.ModuleFilesReference{{Name: "module"}, {Name: "module"}},
+			err:    errors.Errorf(errFmtModuleDup, "files/logo.png"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &connector{kube: kube}
+			got, err := c.moduleFiles(context.Background(), tc.refs, "default")
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nc.moduleFiles(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nc.moduleFiles(...): -want, +got:\n%s\n", tc.reason, diff)
 			}
 		})
 	}
@@ -0,0 +1,131 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package gitauth scopes git credentials to the git commands that are run for
+// a workspace. Workspaces are reconciled concurrently, so their credentials
+// must never be configured via the environment or files of the provider
+// process.
+package gitauth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filenames of ProviderConfig credentials that authenticate git.
+const (
+	// CredentialsFilename is the filename of a git credential store file,
+	// used to authenticate to git repositories over HTTPS.
+	CredentialsFilename = ".git-credentials"
+
+	// SSHKeyFilename is the filename of a private key, used to authenticate
+	// to git repositories over SSH.
+	SSHKeyFilename = ".git-ssh-key"
+
+	// KnownHostsFilename is the filename of the known_hosts file in which the
+	// host keys of SSH servers are recorded.
+	KnownHostsFilename = ".git-known-hosts"
+
+	// SSHConfigFilename is the filename of the SSH config file that git's
+	// SSH commands read.
+	SSHConfigFilename = ".git-ssh-config"
+)
+
+// An SSHKey is a private key that authenticates to the SSH servers that match
+// a host pattern.
+type SSHKey struct {
+	// Host is an ssh_config(5) host pattern, e.g. *.example.org.
+	Host string
+
+	// File is the path of the private key.
+	File string
+}
+
+// Config configures the credentials git uses.
+type Config struct {
+	// CredentialsFile is the path of a git credential store file.
+	CredentialsFile string
+
+	// SSHKeys authenticate to SSH servers. Each server is authenticated to
+	// using only the first key whose host pattern matches it.
+	SSHKeys []SSHKey
+
+	// KnownHostsFile is the path of a known_hosts file. SSH's default
+	// known_hosts files are used if it's empty.
+	KnownHostsFile string
+
+	// AcceptNewHostKeys trusts the host keys of SSH servers that aren't
+	// known yet, and adds them to the known_hosts file. SSH only connects to
+	// known servers otherwise.
+	AcceptNewHostKeys bool
+
+	// SSHConfigFile is the path of an SSH config file, to which the output of
+	// SSHConfig must be written. SSH isn't configured if it's empty.
+	SSHConfigFile string
+}
+
+// Env returns environment variables that configure a git command, or a
+// command like tofu init that runs git, to use only the configured
+// credentials. Any credential helpers configured by git config files are
+// disabled, and git never prompts for credentials.
+func (c Config) Env() []string {
+	// An empty helper resets the list of helpers configured by any config
+	// files git read before the environment.
+	helpers := []string{""}
+	if c.CredentialsFile != "" {
+		helpers = append(helpers, "store --file="+quote(c.CredentialsFile))
+	}
+
+	env := []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_COUNT=" + strconv.Itoa(len(helpers)),
+	}
+	for i, h := range helpers {
+		env = append(env,
+			"GIT_CONFIG_KEY_"+strconv.Itoa(i)+"=credential.helper",
+			"GIT_CONFIG_VALUE_"+strconv.Itoa(i)+"="+h,
+		)
+	}
+
+	if c.SSHConfigFile != "" {
+		// SSH reads only the supplied config file, not the user's or the
+		// system's.
+		env = append(env, "GIT_SSH_COMMAND=ssh -F "+quote(c.SSHConfigFile))
+	}
+	return env
+}
+
+// SSHConfig returns an ssh_config(5) file that configures SSH to use only the
+// configured keys and known_hosts file.
+func (c Config) SSHConfig() []byte {
+	b := &strings.Builder{}
+	for i, k := range c.SSHKeys {
+		// SSH uses every IdentityFile of every Host block that matches, so
+		// each block excludes the hosts matched by the blocks before it.
+		hosts := []string{k.Host}
+		for _, prev := range c.SSHKeys[:i] {
+			hosts = append(hosts, "!"+prev.Host)
+		}
+		fmt.Fprintf(b, "Host %s\n\tIdentityFile %q\n", strings.Join(hosts, " "), k.File)
+	}
+
+	strict := "yes"
+	if c.AcceptNewHostKeys {
+		strict = "accept-new"
+	}
+	fmt.Fprintf(b, "Host *\n\tIdentitiesOnly yes\n\tBatchMode yes\n\tStrictHostKeyChecking %s\n", strict)
+	if c.KnownHostsFile != "" {
+		fmt.Fprintf(b, "\tUserKnownHostsFile %q\n", c.KnownHostsFile)
+	}
+	return []byte(b.String())
+}
+
+// quote the supplied string for a POSIX shell, which git uses to run
+// credential helpers and SSH commands.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,125 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package modcache
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtGit      = "git %s failed: %s"
+	errSSHKey      = "cannot decode sshkey"
+	errWriteSSHKey = "cannot write sshkey"
+)
+
+// A gitGetter fetches git repositories like go-getter's GitGetter, except that
+// it runs git with additional environment variables. go-getter's GitGetter
+// runs git with the environment of the provider process, which is shared by
+// all workspaces.
+type gitGetter struct {
+	getter.GitGetter
+
+	env []string
+}
+
+// Get clones the supplied repository into the supplied directory, which must
+// not exist. It supports the ref, depth and sshkey query parameters of
+// go-getter's GitGetter.
+func (g *gitGetter) Get(dst string, u *url.URL) error {
+	ctx := g.Context()
+
+	q := u.Query()
+	ref, sshKey := q.Get("ref"), q.Get("sshkey")
+	depth, _ := strconv.Atoi(q.Get("depth"))
+	q.Del("ref")
+	q.Del("sshkey")
+	q.Del("depth")
+	repo := *u
+	repo.RawQuery = q.Encode()
+
+	env := g.env
+	if sshKey != "" {
+		key, err := base64.StdEncoding.DecodeString(sshKey)
+		if err != nil {
+			return errors.Wrap(err, errSSHKey)
+		}
+		f, err := os.CreateTemp("", "git-ssh-key")
+		if err != nil {
+			return errors.Wrap(err, errWriteSSHKey)
+		}
+		defer os.Remove(f.Name()) //nolint:errcheck // Only fails if the file doesn't exist.
+		_, err = f.Write(key)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return errors.Wrap(err, errWriteSSHKey)
+		}
+		env = withSSHKey(env, f.Name())
+	}
+
+	// Shallow clones can only clone named refs.
+	args := []string{"clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+		if ref != "" {
+			args = append(args, "--branch", ref)
+		}
+	}
+	args = append(args, "--", repo.String(), dst)
+	if err := git(ctx, "", env, args...); err != nil {
+		return err
+	}
+	if ref != "" && depth < 1 {
+		if err := git(ctx, dst, env, "checkout", ref); err != nil {
+			return err
+		}
+	}
+
+	args = []string{"submodule", "update", "--init", "--recursive"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	return git(ctx, dst, env, args...)
+}
+
+// withSSHKey returns the supplied environment with the supplied key added to
+// its GIT_SSH_COMMAND.
+func withSSHKey(env []string, key string) []string {
+	const prefix = "GIT_SSH_COMMAND="
+	out := make([]string, 0, len(env)+1)
+	cmd := "ssh"
+	for _, e := range env {
+		if c, ok := strings.CutPrefix(e, prefix); ok {
+			cmd = c
+			continue
+		}
+		out = append(out, e)
+	}
+	return append(out, prefix+cmd+" -i '"+strings.ReplaceAll(key, "'", `'\''`)+"'")
+}
+
+// git runs a git command in the supplied directory with the supplied
+// additional environment variables.
+func git(ctx context.Context, dir string, env []string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, errFmtGit, args[0], strings.TrimSpace(string(out)))
+	}
+	return nil
+}
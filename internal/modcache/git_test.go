@@ -0,0 +1,99 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package modcache
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// repo creates a git repository with two commits, and returns its path and
+// the ID of its first commit.
+func repo(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.CommandContext(context.Background(), "git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.org", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.org")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("a")
+	run("add", "main.tf")
+	run("commit", "-q", "-m", "a")
+	first := run("rev-parse", "HEAD")
+	write("b")
+	run("commit", "-q", "-am", "b")
+	return dir, first
+}
+
+func TestFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, first := repo(t)
+
+	cases := map[string]struct {
+		reason  string
+		src     string
+		env     []string
+		want    string
+		wantErr bool
+	}{
+		"Ref": {
+			reason: "The supplied ref should be checked out.",
+			src:    "git::file://" + dir + "?ref=" + first,
+			want:   "a",
+		},
+		"DefaultBranch": {
+			reason: "The default branch should be checked out if there is no ref.",
+			src:    "git::file://" + dir,
+			want:   "b",
+		},
+		"Env": {
+			reason:  "Git should run with the supplied environment, which here forbids the file protocol.",
+			src:     "git::file://" + dir,
+			env:     []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=protocol.file.allow", "GIT_CONFIG_VALUE_0=never"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "module")
+			err := Fetch(context.Background(), tc.src, dst, tc.env)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\nFetch(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(dst, "main.tf")) //nolint:gosec // Test file.
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("\n%s\nFetch(...): want main.tf %q, got %q", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,654 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package modcache caches remote tofu modules, so that workspaces that use the
+// same module don't fetch it from its source on every reconcile.
+package modcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+
+	"github.com/upbound/provider-opentofu/internal/oci"
+)
+
+// Error strings.
+const (
+	errDetect     = "cannot detect module source"
+	errParseRef   = "cannot parse OCI reference"
+	errResolve    = "cannot resolve git ref"
+	errResolveTag = "cannot resolve OCI artifact tag"
+	errFmtNoRef   = "git ref %q not found"
+	errFetch      = "cannot fetch module"
+	errDigest     = "cannot calculate module digest"
+	errStore      = "cannot store module in cache"
+	errCopy       = "cannot copy module from cache"
+	errFmtReadDir = "cannot read directory %q"
+	errFmtPrune   = "cannot remove cache entries: %v"
+)
+
+const (
+	// manifest lists the files a module copied into a destination
+	// directory, so that they can be removed if a later revision of the
+	// module no longer contains them.
+	manifest = ".crossplane-module"
+
+	tmpPrefix = "tmp-"
+)
+
+var (
+	commitID      = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	shortCommitID = regexp.MustCompile(`^[0-9a-f]{7,39}$`)
+)
+
+// A Fetcher fetches the module at the supplied go-getter source into the
+// supplied directory. Any git commands it runs use the supplied additional
+// environment variables.
+type Fetcher func(ctx context.Context, src, dst string, env []string) error
+
+// A Resolver resolves the supplied ref of the supplied git repository to a
+// commit ID. An empty ref refers to the repository's default branch. Any git
+// commands it runs use the supplied additional environment variables.
+type Resolver func(ctx context.Context, repo, ref string, env []string) (string, error)
+
+// A Registry resolves and pulls OCI artifacts. Resolve must return an error
+// if the supplied credentials can't pull the artifact, even if the reference
+// specifies a digest.
+type Registry interface {
+	Resolve(ctx context.Context, ref oci.Reference, kc oci.Keychain) (string, error)
+	Pull(ctx context.Context, ref oci.Reference, kc oci.Keychain, dst string) error
+}
+
+// A Cache of remote modules. Each revision of a module is fetched once and
+// kept as an immutable cache entry, which is copied into the directory of
+// every workspace that uses it.
+//
+// The revision of a module from a git source is the commit its ref resolves
+// to, which is cheap to determine without fetching the module. Other sources
+// must be fetched to determine their revision, which is the digest of their
+// content. The revision of a module distributed as an OCI artifact is the
+// digest of its manifest. Either way a source is resolved at most once per
+// refresh interval.
+type Cache struct {
+	dir      string
+	fs       afero.Afero
+	fetch    Fetcher
+	resolve  Resolver
+	registry Registry
+	interval time.Duration
+	maxAge   time.Duration
+	log      logging.Logger
+	now      func() time.Time
+
+	mu       sync.Mutex
+	locks    map[string]*sync.Mutex
+	resolved map[string]resolution
+}
+
+type resolution struct {
+	revision string
+	entry    string
+	at       time.Time
+}
+
+// An Option configures a new Cache.
+type Option func(*Cache)
+
+// WithFs configures the afero filesystem implementation in which modules will
+// be cached. The default is the real operating system filesystem.
+func WithFs(fs afero.Afero) Option {
+	return func(c *Cache) { c.fs = fs }
+}
+
+// WithFetcher configures how modules are fetched. The default fetches modules
+// using go-getter.
+func WithFetcher(fn Fetcher) Option {
+	return func(c *Cache) { c.fetch = fn }
+}
+
+// WithResolver configures how git refs are resolved to commits. The default
+// uses git ls-remote.
+func WithResolver(fn Resolver) Option {
+	return func(c *Cache) { c.resolve = fn }
+}
+
+// WithRegistry configures how OCI artifacts are resolved and pulled. The
+// default pulls artifacts using an oci.Client.
+func WithRegistry(r Registry) Option {
+	return func(c *Cache) { c.registry = r }
+}
+
+// WithRefreshInterval configures how often a module source is resolved again
+// to determine whether it has a new revision. The default is one minute.
+func WithRefreshInterval(i time.Duration) Option {
+	return func(c *Cache) { c.interval = i }
+}
+
+// WithMaxAge configures how long a cache entry is kept after it was last used.
+// The default is 24 hours.
+func WithMaxAge(d time.Duration) Option {
+	return func(c *Cache) { c.maxAge = d }
+}
+
+// WithLogger configures the logger that will be used. The default is a no-op
+// logger never emits logs.
+func WithLogger(l logging.Logger) Option {
+	return func(c *Cache) { c.log = l }
+}
+
+// New returns a Cache that stores modules in the supplied directory.
+func New(dir string, o ...Option) *Cache {
+	c := &Cache{
+		dir:      dir,
+		fs:       afero.Afero{Fs: afero.NewOsFs()},
+		fetch:    Fetch,
+		resolve:  LsRemote,
+		interval: 1 * time.Minute,
+		maxAge:   24 * time.Hour,
+		log:      logging.NewNopLogger(),
+		now:      time.Now,
+		locks:    map[string]*sync.Mutex{},
+		resolved: map[string]resolution{},
+	}
+
+	for _, fn := range o {
+		fn(c)
+	}
+	if c.registry == nil {
+		c.registry = oci.NewClient(oci.WithFs(c.fs))
+	}
+
+	return c
+}
+
+// Fetch the module at the supplied go-getter source into the supplied
+// directory. Git sources are cloned with the supplied additional environment
+// variables, e.g. to configure credentials.
+func Fetch(ctx context.Context, src, dst string, env []string) error {
+	getters := make(map[string]getter.Getter, len(getter.Getters))
+	for k, g := range getter.Getters {
+		getters[k] = g
+	}
+	getters["git"] = &gitGetter{env: env}
+
+	gc := getter.Client{
+		Ctx:     ctx,
+		Src:     src,
+		Dst:     dst,
+		Pwd:     dst,
+		Mode:    getter.ClientModeDir,
+		Getters: getters,
+	}
+	return gc.Get()
+}
+
+// LsRemote resolves the supplied ref of the supplied git repository using git
+// ls-remote. Annotated tags are resolved to the commit they point to.
+func LsRemote(ctx context.Context, repo, ref string, env []string) (string, error) {
+	pattern := ref
+	if pattern == "" {
+		pattern = "HEAD"
+	}
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--", repo, pattern) //nolint:gosec // The repository comes from the module source.
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return "", errors.Wrap(err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", err
+	}
+	return parseLsRemote(out, ref)
+}
+
+// parseLsRemote returns the commit the supplied ref resolves to, given the
+// output of git ls-remote. Tags take precedence over branches, consistent with
+// git checkout.
+func parseLsRemote(out []byte, ref string) (string, error) {
+	refs := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 2 {
+			refs[f[1]] = f[0]
+		}
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, name := range []string{"refs/tags/" + ref + "^{}", "refs/tags/" + ref, "refs/heads/" + ref, ref} {
+		if id, ok := refs[name]; ok {
+			return id, nil
+		}
+	}
+	return "", errors.Errorf(errFmtNoRef, ref)
+}
+
+// Get the module at the supplied go-getter source, copying it into the
+// supplied directory. Relative sources are relative to that directory. Git
+// commands run with the supplied additional environment variables, which
+// typically configure credentials. The supplied digest identifies those
+// credentials. It must change when their content does, but not when only the
+// paths to which they were written do. Returns the revision of the module,
+// which is a git commit ID or the digest of the module's content.
+//
+// Files are copied rather than hard linked, because tofu and the provider
+// write to the directory and must never modify a cache entry.
+func (c *Cache) Get(ctx context.Context, src, dst string, env []string, digest string) (string, error) {
+	src, err := getter.Detect(src, dst, getter.Detectors)
+	if err != nil {
+		return "", errors.Wrap(err, errDetect)
+	}
+
+	// Resolutions are only reused by callers with the same credentials, so
+	// that a caller can't use a resolution made with another's credentials.
+	key := src
+	if digest != "" {
+		key += "#" + digest
+	}
+	return c.get(key, dst, func() (resolution, error) {
+		return c.refresh(ctx, src, env)
+	})
+}
+
+// GetArtifact gets the module distributed as the OCI artifact at the supplied
+// reference, copying it into the supplied directory. The supplied keychain is
+// used to authenticate to the artifact's registry. Returns the revision of the
+// module, which is the digest of the artifact's manifest.
+func (c *Cache) GetArtifact(ctx context.Context, ref string, kc oci.Keychain, dst string) (string, error) {
+	r, err := oci.ParseReference(ref)
+	if err != nil {
+		return "", errors.Wrap(err, errParseRef)
+	}
+
+	// Resolutions are made with the credentials of a workspace, so they're
+	// only reused by workspaces with the same credentials. Entries are
+	// shared by all workspaces, but every resolution requests the manifest
+	// with the workspace's credentials, even when the reference specifies a
+	// digest, so a workspace can only use an entry it could have pulled.
+	key := "oci::" + r.String()
+	if cred, ok := kc.Lookup(r.Registry); ok {
+		h := sha256.Sum256([]byte(cred.Username + "\x00" + cred.Password))
+		key += "#" + hex.EncodeToString(h[:])
+	}
+	return c.get(key, dst, func() (resolution, error) {
+		return c.refreshArtifact(ctx, r, kc)
+	})
+}
+
+// get the module with the supplied key, copying it into the supplied
+// directory. The module is refreshed using the supplied function unless it was
+// resolved within the refresh interval.
+func (c *Cache) get(key, dst string, refresh func() (resolution, error)) (string, error) {
+	l := c.lock(key)
+	l.Lock()
+	defer l.Unlock()
+
+	r, ok := c.lookup(key)
+	if ok {
+		// The entry may have been garbage collected since it was resolved.
+		_, err := c.fs.Stat(filepath.Join(c.dir, r.entry))
+		ok = err == nil
+	}
+	if !ok || c.now().Sub(r.at) >= c.interval {
+		var err error
+		if r, err = refresh(); err != nil {
+			return "", err
+		}
+		c.store(key, r)
+	}
+
+	entry := filepath.Join(c.dir, r.entry)
+	// Record when the entry was last used, so it isn't pruned.
+	_ = c.fs.Chtimes(entry, c.now(), c.now())
+	if err := c.copy(entry, dst); err != nil {
+		return "", errors.Wrap(err, errCopy)
+	}
+	return r.revision, nil
+}
+
+func (c *Cache) lock(src string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.locks[src]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[src] = l
+	}
+	return l
+}
+
+func (c *Cache) lookup(src string) (resolution, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.resolved[src]
+	return r, ok
+}
+
+// refresh resolves the revision of the supplied source, fetching it into the
+// cache unless that revision is already cached.
+func (c *Cache) refresh(ctx context.Context, src string, env []string) (resolution, error) {
+	fetch := src
+	r := resolution{at: c.now()}
+
+	if repo, ref, ok := gitSource(src); ok {
+		id := ref
+		var err error
+		if commitID.MatchString(ref) {
+			// A commit needn't be resolved, but the repository is still
+			// listed with the caller's credentials. Entries are shared, so
+			// this stops a caller that can't read the repository getting a
+			// commit another caller fetched.
+			_, err = c.resolve(ctx, repo, "", env)
+		} else {
+			id, err = c.resolve(ctx, repo, ref, env)
+		}
+		if err != nil {
+			return resolution{}, errors.Wrap(err, errResolve)
+		}
+		r.revision = id
+		r.entry = entryName(src, id)
+		if _, err := c.fs.Stat(filepath.Join(c.dir, r.entry)); err == nil {
+			return r, nil
+		}
+		fetch = withRef(src, id)
+	}
+
+	return c.fill(r, src, func(dst string) error {
+		return c.fetch(ctx, fetch, dst, env)
+	})
+}
+
+// refreshArtifact resolves the digest of the supplied OCI artifact, pulling it
+// into the cache unless that digest is already cached.
+func (c *Cache) refreshArtifact(ctx context.Context, ref oci.Reference, kc oci.Keychain) (resolution, error) {
+	d, err := c.registry.Resolve(ctx, ref, kc)
+	if err != nil {
+		return resolution{}, errors.Wrap(err, errResolveTag)
+	}
+	// Entries are named by repository, so that tags that point to the
+	// same digest share an entry.
+	r := resolution{revision: d, entry: entryName("oci::"+ref.Name(), d), at: c.now()}
+	if _, err := c.fs.Stat(filepath.Join(c.dir, r.entry)); err == nil {
+		return r, nil
+	}
+
+	pinned := ref
+	pinned.Digest = d
+	return c.fill(r, ref.Name(), func(dst string) error {
+		return c.registry.Pull(ctx, pinned, kc, dst)
+	})
+}
+
+// fill a new cache entry for the supplied resolution using the supplied fetch
+// function. The revision of the resolution is the digest of the entry's
+// content if it doesn't have one yet.
+func (c *Cache) fill(r resolution, src string, fetch func(dst string) error) (resolution, error) {
+	if err := c.fs.MkdirAll(c.dir, 0700); err != nil {
+		return resolution{}, errors.Wrap(err, errStore)
+	}
+	tmp, err := c.fs.TempDir(c.dir, tmpPrefix)
+	if err != nil {
+		return resolution{}, errors.Wrap(err, errStore)
+	}
+	defer c.fs.RemoveAll(tmp) //nolint:errcheck // Only fails if the directory was already renamed.
+
+	// go-getter requires that the destination doesn't exist yet.
+	mod := filepath.Join(tmp, "module")
+	if err := fetch(mod); err != nil {
+		return resolution{}, errors.Wrap(err, errFetch)
+	}
+	if err := c.fs.RemoveAll(filepath.Join(mod, ".git")); err != nil {
+		return resolution{}, errors.Wrap(err, errStore)
+	}
+
+	if r.revision == "" {
+		d, err := c.digest(mod)
+		if err != nil {
+			return resolution{}, errors.Wrap(err, errDigest)
+		}
+		r.revision = d
+		r.entry = entryName(src, d)
+	}
+
+	// Another process may have cached the same revision in the meantime,
+	// in which case the rename fails and we use its entry instead.
+	if err := c.fs.Rename(mod, filepath.Join(c.dir, r.entry)); err != nil {
+		if _, serr := c.fs.Stat(filepath.Join(c.dir, r.entry)); serr != nil {
+			return resolution{}, errors.Wrap(err, errStore)
+		}
+	}
+	return r, nil
+}
+
+func (c *Cache) store(src string, r resolution) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolved[src] = r
+}
+
+// gitSource returns the repository and ref of a detected go-getter git source.
+func gitSource(src string) (repo, ref string, ok bool) {
+	forced, rest, found := strings.Cut(src, "::")
+	if !found || forced != "git" {
+		return "", "", false
+	}
+	rest, _ = getter.SourceDirSubdir(rest)
+	u, err := url.Parse(rest)
+	if err != nil {
+		return "", "", false
+	}
+	q := u.Query()
+	// go-getter writes SSH keys to a temporary file for git to use, and
+	// git ls-remote can't resolve abbreviated commits. We can't resolve
+	// such sources without fetching them.
+	ref = q.Get("ref")
+	if q.Get("sshkey") != "" || shortCommitID.MatchString(ref) {
+		return "", "", false
+	}
+	for k := range q {
+		q.Del(k)
+	}
+	u.RawQuery = ""
+	return u.String(), ref, true
+}
+
+// withRef returns the supplied git source with its ref set to the supplied
+// commit, so that the fetched module matches the resolved revision even if
+// the ref moved since it was resolved. Shallow clones can only fetch named
+// refs, so their ref is left unchanged.
+func withRef(src, commit string) string {
+	forced, rest, _ := strings.Cut(src, "::")
+	rest, subdir := getter.SourceDirSubdir(rest)
+	u, err := url.Parse(rest)
+	if err != nil {
+		return src
+	}
+	q := u.Query()
+	if q.Get("depth") != "" {
+		return src
+	}
+	q.Set("ref", commit)
+	u.RawQuery = ""
+	s := forced + "::" + u.String()
+	if subdir != "" {
+		s += "//" + subdir
+	}
+	return s + "?" + q.Encode()
+}
+
+// entryName returns the name of the cache entry for the supplied revision of
+// the supplied source.
+func entryName(src, revision string) string {
+	h := sha256.New()
+	h.Write([]byte(src))
+	h.Write([]byte{0})
+	h.Write([]byte(revision))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// digest returns a digest of the files in the supplied directory.
+func (c *Cache) digest(dir string) (string, error) {
+	files, err := c.files(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+		r, err := c.fs.Open(filepath.Join(dir, f))
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// files returns the sorted paths of the regular files in the supplied
+// directory, relative to the directory.
+func (c *Cache) files(dir string) ([]string, error) {
+	var files []string
+	err := c.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// copy the files of a cache entry into the supplied directory, removing any
+// files a previous revision of the module copied there.
+func (c *Cache) copy(entry, dst string) error {
+	files, err := c.files(entry)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := c.copyFile(filepath.Join(entry, f), filepath.Join(dst, f)); err != nil {
+			return err
+		}
+	}
+
+	current := map[string]bool{}
+	for _, f := range files {
+		current[f] = true
+	}
+	if prev, err := c.fs.ReadFile(filepath.Join(dst, manifest)); err == nil {
+		for _, f := range strings.Split(string(prev), "\n") {
+			if f == "" || current[f] || !filepath.IsLocal(f) {
+				continue
+			}
+			if err := c.fs.Remove(filepath.Join(dst, f)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return c.fs.WriteFile(filepath.Join(dst, manifest), []byte(strings.Join(files, "\n")), 0600)
+}
+
+func (c *Cache) copyFile(src, dst string) error {
+	fi, err := c.fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := c.fs.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	in, err := c.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck // Only reading.
+	out, err := c.fs.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Run the cache's garbage collector, which removes entries that haven't been
+// used for longer than the maximum age. Blocks until the supplied context is
+// done.
+func (c *Cache) Run(ctx context.Context) {
+	t := time.NewTicker(c.maxAge / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := c.prune(); err != nil {
+				c.log.Info("Module cache garbage collection failed", "error", err)
+			}
+		}
+	}
+}
+
+func (c *Cache) prune() error {
+	fis, err := c.fs.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, errFmtReadDir, c.dir)
+	}
+
+	failed := make([]string, 0)
+	for _, fi := range fis {
+		if !fi.IsDir() || c.now().Sub(fi.ModTime()) < c.maxAge {
+			continue
+		}
+		path := filepath.Join(c.dir, fi.Name())
+		if err := c.fs.RemoveAll(path); err != nil {
+			failed = append(failed, path)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf(errFmtPrune, strings.Join(failed, ", "))
+	}
+	return nil
+}
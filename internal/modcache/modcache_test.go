@@ -0,0 +1,577 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package modcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+
+	"github.com/upbound/provider-opentofu/internal/oci"
+)
+
+// A fakeRemote serves a module whose files and commit can be changed.
+type fakeRemote struct {
+	fs      afero.Afero
+	files   map[string]string
+	commit  string
+	fetched []string
+	err     error
+}
+
+func (r *fakeRemote) fetch(_ context.Context, src, dst string, _ []string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.fetched = append(r.fetched, src)
+	for name, content := range r.files {
+		if err := r.fs.MkdirAll(filepath.Dir(filepath.Join(dst, name)), 0700); err != nil {
+			return err
+		}
+		if err := r.fs.WriteFile(filepath.Join(dst, name), []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeRemote) resolve(_ context.Context, _, _ string, _ []string) (string, error) {
+	return r.commit, nil
+}
+
+// A fakeRegistry serves an OCI artifact whose files and digest can be changed.
+// It requires credentials if it has a user.
+type fakeRegistry struct {
+	remote   *fakeRemote
+	digest   string
+	user     string
+	resolved int
+	pulled   []string
+}
+
+func (r *fakeRegistry) Resolve(_ context.Context, ref oci.Reference, kc oci.Keychain) (string, error) {
+	r.resolved++
+	if cred, ok := kc.Lookup(ref.Registry); r.user != "" && (!ok || cred.Username != r.user) {
+		return "", errDenied
+	}
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	return r.digest, r.remote.err
+}
+
+func (r *fakeRegistry) Pull(ctx context.Context, ref oci.Reference, _ oci.Keychain, dst string) error {
+	r.pulled = append(r.pulled, ref.String())
+	return r.remote.fetch(ctx, ref.String(), dst, nil)
+}
+
+var errDenied = errors.New("denied")
+
+const (
+	commitA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	commitB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestGet(t *testing.T) {
+	errBoom := errors.New("boom")
+	sum := sha256.Sum256([]byte("main.tf\x00a\x00"))
+	digestA := "sha256:" + hex.EncodeToString(sum[:])
+
+	// A step changes the remote, advances the clock, then gets the module.
+	type step struct {
+		files   map[string]string
+		commit  string
+		advance time.Duration
+		err     error
+	}
+	type want struct {
+		revisions []string
+		fetched   []string
+		files     map[string]string
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		src    string
+		steps  []step
+		want   want
+	}{
+		"GitFetchedOnce": {
+			reason: "A git module should only be fetched once per commit, pinned to that commit.",
+			src:    "github.com/crossplane/rocks",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a"}, commit: commitA},
+				{advance: 2 * time.Minute},
+			},
+			want: want{
+				revisions: []string{commitA, commitA},
+				fetched:   []string{"git::https://github.com/crossplane/rocks.git?ref=" + commitA},
+				files:     map[string]string{"main.tf": "a"},
+			},
+		},
+		"GitNewCommit": {
+			reason: "A git module should be fetched again when its ref resolves to a new commit.",
+			src:    "git::https://github.com/crossplane/rocks.git//modules/a?ref=main",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a"}, commit: commitA},
+				{files: map[string]string{"main.tf": "b"}, commit: commitB, advance: 2 * time.Minute},
+			},
+			want: want{
+				revisions: []string{commitA, commitB},
+				fetched: []string{
+					"git::https://github.com/crossplane/rocks.git//modules/a?ref=" + commitA,
+					"git::https://github.com/crossplane/rocks.git//modules/a?ref=" + commitB,
+				},
+				files: map[string]string{"main.tf": "b"},
+			},
+		},
+		"GitWithinRefreshInterval": {
+			reason: "A git ref should not be resolved again within the refresh interval.",
+			src:    "github.com/crossplane/rocks",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a"}, commit: commitA},
+				{files: map[string]string{"main.tf": "b"}, commit: commitB, advance: 30 * time.Second},
+			},
+			want: want{
+				revisions: []string{commitA, commitA},
+				fetched:   []string{"git::https://github.com/crossplane/rocks.git?ref=" + commitA},
+				files:     map[string]string{"main.tf": "a"},
+			},
+		},
+		"ArchiveWithinRefreshInterval": {
+			reason: "An archive should not be fetched again within the refresh interval.",
+			src:    "https://example.org/module.zip",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a"}},
+				{advance: 30 * time.Second},
+			},
+			want: want{
+				revisions: []string{digestA, digestA},
+				fetched:   []string{"https://example.org/module.zip"},
+				files:     map[string]string{"main.tf": "a"},
+			},
+		},
+		"StaleFilesRemoved": {
+			reason: "Files of a previous revision that the new revision doesn't contain should be removed.",
+			src:    "github.com/crossplane/rocks",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a", "old/old.tf": "a"}, commit: commitA},
+				{files: map[string]string{"main.tf": "b"}, commit: commitB, advance: 2 * time.Minute},
+			},
+			want: want{
+				revisions: []string{commitA, commitB},
+				fetched: []string{
+					"git::https://github.com/crossplane/rocks.git?ref=" + commitA,
+					"git::https://github.com/crossplane/rocks.git?ref=" + commitB,
+				},
+				files: map[string]string{"main.tf": "b"},
+			},
+		},
+		"FetchError": {
+			reason: "Errors fetching a module should be returned.",
+			src:    "github.com/crossplane/rocks",
+			steps: []step{
+				{commit: commitA, err: errBoom},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errFetch),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			r := &fakeRemote{fs: fs}
+			now := time.Now()
+			c := New("/cache", WithFs(fs), WithFetcher(r.fetch), WithResolver(r.resolve))
+			c.now = func() time.Time { return now }
+
+			var revisions []string
+			for _, s := range tc.steps {
+				if s.files != nil {
+					r.files = s.files
+				}
+				if s.commit != "" {
+					r.commit = s.commit
+				}
+				r.err = s.err
+				now = now.Add(s.advance)
+
+				rev, err := c.Get(context.Background(), tc.src, "/ws", nil, "")
+				if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+					t.Fatalf("\n%s\nc.Get(...): -want error, +got error:\n%s", tc.reason, diff)
+				}
+				if err != nil {
+					return
+				}
+				revisions = append(revisions, rev)
+			}
+
+			if diff := cmp.Diff(tc.want.revisions, revisions); diff != "" {
+				t.Errorf("\n%s\nc.Get(...): -want revisions, +got revisions:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.fetched, r.fetched); diff != "" {
+				t.Errorf("\n%s\nc.Get(...): -want fetched, +got fetched:\n%s", tc.reason, diff)
+			}
+			files := map[string]string{}
+			paths, _ := c.files("/ws")
+			for _, p := range paths {
+				if p == manifest {
+					continue
+				}
+				b, _ := fs.ReadFile(filepath.Join("/ws", p))
+				files[p] = string(b)
+			}
+			if diff := cmp.Diff(tc.want.files, files); diff != "" {
+				t.Errorf("\n%s\nc.Get(...): -want files, +got files:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetEnv(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	r := &fakeRemote{fs: fs, files: map[string]string{"main.tf": "a"}}
+
+	mu := sync.Mutex{}
+	resolved := map[string]int{}
+	resolve := func(_ context.Context, _, _ string, env []string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The credentials are the first environment variable.
+		k := ""
+		if len(env) > 0 {
+			k = env[0]
+		}
+		resolved[k]++
+		return commitA, nil
+	}
+	c := New("/cache", WithFs(fs), WithFetcher(r.fetch), WithResolver(resolve))
+
+	// Workspaces with different credentials get the same module at once.
+	// Workspaces with the same credentials write them to different paths, so
+	// their environments differ.
+	envs := [][]string{{"GIT_CONFIG_VALUE_1=a"}, {"GIT_CONFIG_VALUE_1=b"}, nil}
+	digests := []string{"a", "b", ""}
+	wg := sync.WaitGroup{}
+	for i := range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env := envs[i%len(envs)]
+			if env != nil {
+				env = append(env, "GIT_SSH_COMMAND=ssh -F /tmp/tofu/"+strconv.Itoa(i)+"/.git-ssh-config")
+			}
+			if _, err := c.Get(context.Background(), "github.com/crossplane/rocks", filepath.Join("/ws", strconv.Itoa(i)), env, digests[i%len(digests)]); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each ref should be resolved once per environment, with that
+	// environment, while the module should be fetched once.
+	want := map[string]int{"GIT_CONFIG_VALUE_1=a": 1, "GIT_CONFIG_VALUE_1=b": 1, "": 1}
+	if diff := cmp.Diff(want, resolved); diff != "" {
+		t.Errorf("c.Get(...): -want resolved, +got resolved:\n%s", diff)
+	}
+	if diff := cmp.Diff(1, len(r.fetched)); diff != "" {
+		t.Errorf("c.Get(...): -want fetches, +got fetches:\n%s", diff)
+	}
+}
+
+func TestGetPinnedCommit(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	r := &fakeRemote{fs: fs, files: map[string]string{"main.tf": "a"}}
+
+	// Only callers with credentials can read the repository.
+	resolve := func(_ context.Context, _, _ string, env []string) (string, error) {
+		if len(env) == 0 {
+			return "", errDenied
+		}
+		return commitB, nil
+	}
+	c := New("/cache", WithFs(fs), WithFetcher(r.fetch), WithResolver(resolve))
+	src := "git::https://github.com/crossplane/rocks.git?ref=" + commitA
+
+	rev, err := c.Get(context.Background(), src, "/ws/a", []string{"GIT_CONFIG_VALUE_1=a"}, "a")
+	if err != nil {
+		t.Fatalf("c.Get(...): %v", err)
+	}
+	if diff := cmp.Diff(commitA, rev); diff != "" {
+		t.Errorf("c.Get(...): -want revision, +got revision:\n%s", diff)
+	}
+
+	// A caller without credentials shouldn't get the commit the first caller
+	// fetched.
+	_, err = c.Get(context.Background(), src, "/ws/b", nil, "")
+	if diff := cmp.Diff(errors.Wrap(errDenied, errResolve), err, test.EquateErrors()); diff != "" {
+		t.Errorf("c.Get(...): -want error, +got error:\n%s", diff)
+	}
+	if ok, _ := fs.Exists("/ws/b/main.tf"); ok {
+		t.Errorf("c.Get(...): caller without credentials got the cached module")
+	}
+	if diff := cmp.Diff(1, len(r.fetched)); diff != "" {
+		t.Errorf("c.Get(...): -want fetches, +got fetches:\n%s", diff)
+	}
+}
+
+func TestGetArtifact(t *testing.T) {
+	errBoom := errors.New("boom")
+	digestA := "sha256:" + strings.Repeat("a", 64)
+	digestB := "sha256:" + strings.Repeat("b", 64)
+
+	// A step changes the artifact, advances the clock, then gets the module.
+	type step struct {
+		files   map[string]string
+		digest  string
+		advance time.Duration
+		err     error
+	}
+	type want struct {
+		revisions []string
+		resolved  int
+		pulled    []string
+		files     map[string]string
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		ref    string
+		steps  []step
+		want   want
+	}{
+		"PulledOnce": {
+			reason: "An artifact should only be pulled once per digest, pinned to that digest.",
+			ref:    "registry.example.org/modules/vpc:v1",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a"}, digest: digestA},
+				{advance: 2 * time.Minute},
+			},
+			want: want{
+				revisions: []string{digestA, digestA},
+				resolved:  2,
+				pulled:    []string{"registry.example.org/modules/vpc:v1@" + digestA},
+				files:     map[string]string{"main.tf": "a"},
+			},
+		},
+		"NewDigest": {
+			reason: "An artifact should be pulled again when its tag resolves to a new digest.",
+			ref:    "registry.example.org/modules/vpc:v1",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a", "old.tf": "a"}, digest: digestA},
+				{files: map[string]string{"main.tf": "b"}, digest: digestB, advance: 2 * time.Minute},
+			},
+			want: want{
+				revisions: []string{digestA, digestB},
+				resolved:  2,
+				pulled: []string{
+					"registry.example.org/modules/vpc:v1@" + digestA,
+					"registry.example.org/modules/vpc:v1@" + digestB,
+				},
+				files: map[string]string{"main.tf": "b"},
+			},
+		},
+		"WithinRefreshInterval": {
+			reason: "A tag should not be resolved again within the refresh interval.",
+			ref:    "registry.example.org/modules/vpc:v1",
+			steps: []step{
+				{files: map[string]string{"main.tf": "a"}, digest: digestA},
+				{files: map[string]string{"main.tf": "b"}, digest: digestB, advance: 30 * time.Second},
+			},
+			want: want{
+				revisions: []string{digestA, digestA},
+				resolved:  1,
+				pulled:    []string{"registry.example.org/modules/vpc:v1@" + digestA},
+				files:     map[string]string{"main.tf": "a"},
+			},
+		},
+		"ResolveError": {
+			reason: "Errors resolving a tag should be returned.",
+			ref:    "registry.example.org/modules/vpc:v1",
+			steps: []step{
+				{err: errBoom},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errResolveTag),
+			},
+		},
+		"InvalidReference": {
+			reason: "Invalid references should return an error.",
+			ref:    "registry.example.org/Modules",
+			steps:  []step{{}},
+			want: want{
+				err: errors.Wrap(errors.New(`invalid repository "Modules"`), errParseRef),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			r := &fakeRegistry{remote: &fakeRemote{fs: fs}}
+			now := time.Now()
+			c := New("/cache", WithFs(fs), WithRegistry(r))
+			c.now = func() time.Time { return now }
+
+			var revisions []string
+			for _, s := range tc.steps {
+				if s.files != nil {
+					r.remote.files = s.files
+				}
+				if s.digest != "" {
+					r.digest = s.digest
+				}
+				r.remote.err = s.err
+				now = now.Add(s.advance)
+
+				rev, err := c.GetArtifact(context.Background(), tc.ref, nil, "/ws")
+				if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+					t.Fatalf("\n%s\nc.GetArtifact(...): -want error, +got error:\n%s", tc.reason, diff)
+				}
+				if err != nil {
+					return
+				}
+				revisions = append(revisions, rev)
+			}
+
+			if diff := cmp.Diff(tc.want.revisions, revisions); diff != "" {
+				t.Errorf("\n%s\nc.GetArtifact(...): -want revisions, +got revisions:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.resolved, r.resolved); diff != "" {
+				t.Errorf("\n%s\nc.GetArtifact(...): -want resolved, +got resolved:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pulled, r.pulled); diff != "" {
+				t.Errorf("\n%s\nc.GetArtifact(...): -want pulled, +got pulled:\n%s", tc.reason, diff)
+			}
+			files := map[string]string{}
+			paths, _ := c.files("/ws")
+			for _, p := range paths {
+				if p == manifest {
+					continue
+				}
+				b, _ := fs.ReadFile(filepath.Join("/ws", p))
+				files[p] = string(b)
+			}
+			if diff := cmp.Diff(tc.want.files, files); diff != "" {
+				t.Errorf("\n%s\nc.GetArtifact(...): -want files, +got files:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetArtifactPinnedDigest(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	r := &fakeRegistry{remote: &fakeRemote{fs: fs, files: map[string]string{"main.tf": "a"}}, user: "cool"}
+	c := New("/cache", WithFs(fs), WithRegistry(r))
+	d := "sha256:" + strings.Repeat("a", 64)
+	ref := "registry.example.org/modules/vpc@" + d
+
+	kc := oci.Keychain{"registry.example.org": {Username: "cool", Password: "secret"}}
+	rev, err := c.GetArtifact(context.Background(), ref, kc, "/ws/a")
+	if err != nil {
+		t.Fatalf("c.GetArtifact(...): %v", err)
+	}
+	if diff := cmp.Diff(d, rev); diff != "" {
+		t.Errorf("c.GetArtifact(...): -want revision, +got revision:\n%s", diff)
+	}
+
+	// A caller without credentials shouldn't get the artifact the first
+	// caller pulled.
+	_, err = c.GetArtifact(context.Background(), ref, nil, "/ws/b")
+	if diff := cmp.Diff(errors.Wrap(errDenied, errResolveTag), err, test.EquateErrors()); diff != "" {
+		t.Errorf("c.GetArtifact(...): -want error, +got error:\n%s", diff)
+	}
+	if ok, _ := fs.Exists("/ws/b/main.tf"); ok {
+		t.Errorf("c.GetArtifact(...): caller without credentials got the cached module")
+	}
+	if diff := cmp.Diff(1, len(r.pulled)); diff != "" {
+		t.Errorf("c.GetArtifact(...): -want pulls, +got pulls:\n%s", diff)
+	}
+}
+
+func TestParseLsRemote(t *testing.T) {
+	out := []byte(commitA + "\tHEAD\n" +
+		commitA + "\trefs/heads/main\n" +
+		commitB + "\trefs/tags/v1.0.0\n" +
+		commitA + "\trefs/tags/v1.0.0^{}\n")
+
+	cases := map[string]struct {
+		reason string
+		ref    string
+		want   string
+		err    error
+	}{
+		"DefaultBranch": {
+			reason: "An empty ref should resolve to HEAD.",
+			want:   commitA,
+		},
+		"Branch": {
+			reason: "A branch should resolve to its commit.",
+			ref:    "main",
+			want:   commitA,
+		},
+		"AnnotatedTag": {
+			reason: "An annotated tag should resolve to the commit it points to.",
+			ref:    "v1.0.0",
+			want:   commitA,
+		},
+		"NotFound": {
+			reason: "A ref that doesn't exist should return an error.",
+			ref:    "nope",
+			err:    errors.Errorf(errFmtNoRef, "nope"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseLsRemote(out, tc.ref)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nparseLsRemote(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nparseLsRemote(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	now := time.Now()
+	_ = fs.MkdirAll("/cache/old", 0700)
+	_ = fs.MkdirAll("/cache/new", 0700)
+	_ = fs.Chtimes("/cache/old", now.Add(-25*time.Hour), now.Add(-25*time.Hour))
+	_ = fs.Chtimes("/cache/new", now.Add(-1*time.Hour), now.Add(-1*time.Hour))
+
+	c := New("/cache", WithFs(fs))
+	c.now = func() time.Time { return now }
+	if err := c.prune(); err != nil {
+		t.Fatalf("c.prune(): %v", err)
+	}
+
+	got := []string{}
+	fis, _ := fs.ReadDir("/cache")
+	for _, fi := range fis {
+		got = append(got, fi.Name())
+	}
+	if diff := cmp.Diff([]string{"new"}, got); diff != "" {
+		t.Errorf("c.prune(): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package modpolicy restricts the sources from which modules may be fetched.
+package modpolicy
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+
+	"github.com/upbound/provider-opentofu/internal/oci"
+)
+
+// Error strings.
+const (
+	errDetect        = "cannot detect module source"
+	errParse         = "cannot parse module source"
+	errParseRef      = "cannot parse OCI reference"
+	errFmtNotAllowed = "module source %q does not match any allowed module source"
+	errFmtDenied     = "module source %q matches denied module source %q"
+)
+
+// A Policy determines the sources from which modules may be fetched.
+//
+// Patterns are matched against the location of a module, which is its host and
+// path, e.g. github.com/upbound/modules/vpc. A pattern matches a location, or
+// any location within it, so github.com/upbound matches every repository of the
+// upbound organization. Patterns may use path.Match wildcards, which don't
+// match across a '/'. Patterns that include a scheme, e.g.
+// https://github.com/upbound, are matched against the scheme of the location
+// too. OCI artifacts have the oci scheme.
+type Policy struct {
+	// Allowed patterns. Any location is allowed if there are none.
+	Allowed []string
+
+	// Denied patterns, which take precedence over allowed patterns.
+	Denied []string
+}
+
+// CheckRemote returns an error if the policy doesn't allow the supplied
+// go-getter module source. Relative sources are relative to the supplied
+// directory.
+func (p Policy) CheckRemote(src, pwd string) error {
+	l, err := RemoteLocation(src, pwd)
+	if err != nil {
+		return err
+	}
+	return p.check(l)
+}
+
+// CheckOCI returns an error if the policy doesn't allow the supplied OCI
+// artifact reference.
+func (p Policy) CheckOCI(ref string) error {
+	r, err := oci.ParseReference(ref)
+	if err != nil {
+		return errors.Wrap(err, errParseRef)
+	}
+	return p.check("oci://" + r.Name())
+}
+
+func (p Policy) check(location string) error {
+	for _, pattern := range p.Denied {
+		if Match(pattern, location) {
+			return errors.Errorf(errFmtDenied, location, pattern)
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allowed {
+		if Match(pattern, location) {
+			return nil
+		}
+	}
+	return errors.Errorf(errFmtNotAllowed, location)
+}
+
+// RemoteLocation returns the location of the supplied go-getter module
+// source, e.g. https://github.com/upbound/modules/vpc for
+// git::https://github.com/upbound/modules.git//vpc?ref=main. Credentials,
+// query parameters and the .git suffix of repositories are omitted, and the
+// path is cleaned so that it can't escape a matching pattern.
+func RemoteLocation(src, pwd string) (string, error) {
+	src, err := getter.Detect(src, pwd, getter.Detectors)
+	if err != nil {
+		return "", errors.Wrap(err, errDetect)
+	}
+	if i := strings.Index(src, "::"); i >= 0 && i < strings.Index(src, "://") {
+		src = src[i+2:]
+	}
+	src, subdir := getter.SourceDirSubdir(src)
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", errors.Wrap(err, errParse)
+	}
+	p := path.Clean("/" + strings.TrimSuffix(u.Path, ".git") + "/" + subdir)
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + strings.TrimSuffix(p, "/"), nil
+}
+
+// Match returns true if the supplied pattern matches the supplied location,
+// or any location that contains it.
+func Match(pattern, location string) bool {
+	scheme, rest, _ := strings.Cut(location, "://")
+	if s, p, ok := strings.Cut(pattern, "://"); ok {
+		if !strings.EqualFold(s, scheme) {
+			return false
+		}
+		pattern = p
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	for l := rest; l != ""; {
+		if ok, _ := path.Match(pattern, l); ok {
+			return true
+		}
+		i := strings.LastIndex(l, "/")
+		if i < 0 {
+			return false
+		}
+		l = l[:i]
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package modpolicy
+
+import (
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestRemoteLocation(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		src    string
+		want   string
+	}{
+		"GitHub": {
+			reason: "GitHub shorthand should be detected as a git repository.",
+			src:    "github.com/upbound/modules",
+			want:   "https://github.com/upbound/modules",
+		},
+		"GitSubdir": {
+			reason: "The subdirectory of a git source should be part of its location, and its ref and .git suffix should not.",
+			src:    "git::https://github.com/upbound/modules.git//vpc?ref=main",
+			want:   "https://github.com/upbound/modules/vpc",
+		},
+		"SSH": {
+			reason: "The user of an SSH source should not be part of its location.",
+			src:    "git@github.com:upbound/modules.git",
+			want:   "ssh://github.com/upbound/modules",
+		},
+		"ParentDirectory": {
+			reason: "Parent directory references should be resolved.",
+			src:    "git::https://github.com/upbound/../evil/modules.git",
+			want:   "https://github.com/evil/modules",
+		},
+		"S3": {
+			reason: "Forced getters should not be part of a location.",
+			src:    "s3::https://s3.amazonaws.com/bucket/module.zip",
+			want:   "https://s3.amazonaws.com/bucket/module.zip",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := RemoteLocation(tc.src, "/tofu")
+			if err != nil {
+				t.Fatalf("\n%s\nRemoteLocation(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nRemoteLocation(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		policy   Policy
+		location string
+		want     error
+	}{
+		"NoPatterns": {
+			reason:   "Any location should be allowed if there are no patterns.",
+			location: "https://github.com/evil/modules",
+		},
+		"Host": {
+			reason:   "A host should allow every location on that host.",
+			policy:   Policy{Allowed: []string{"github.com"}},
+			location: "https://github.com/upbound/modules",
+		},
+		"Organization": {
+			reason:   "A path should allow every location within it.",
+			policy:   Policy{Allowed: []string{"github.com/upbound/"}},
+			location: "ssh://github.com/upbound/modules/vpc",
+		},
+		"OrganizationPrefix": {
+			reason:   "A path should not allow locations that merely start with the same characters.",
+			policy:   Policy{Allowed: []string{"github.com/upbound"}},
+			location: "https://github.com/upbound-evil/modules",
+			want:     errors.Errorf(errFmtNotAllowed, "https://github.com/upbound-evil/modules"),
+		},
+		"URLPrefix": {
+			reason:   "A pattern with a scheme should only allow locations with that scheme.",
+			policy:   Policy{Allowed: []string{"https://github.com/upbound"}},
+			location: "ssh://github.com/upbound/modules",
+			want:     errors.Errorf(errFmtNotAllowed, "ssh://github.com/upbound/modules"),
+		},
+		"Glob": {
+			reason:   "Patterns may use wildcards.",
+			policy:   Policy{Allowed: []string{"*.example.org/modules/*"}},
+			location: "oci://registry.example.org/modules/vpc",
+		},
+		"Denied": {
+			reason:   "Denied patterns should take precedence over allowed patterns.",
+			policy:   Policy{Allowed: []string{"github.com"}, Denied: []string{"github.com/evil"}},
+			location: "https://github.com/evil/modules",
+			want:     errors.Errorf(errFmtDenied, "https://github.com/evil/modules", "github.com/evil"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.check(tc.location)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\np.check(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package oci pulls tofu modules distributed as OCI artifacts.
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// Error strings.
+const (
+	errFmtRequest        = "cannot %s %s"
+	errFmtStatus         = "cannot %s %s: %s"
+	errFmtChallenge      = "unsupported authentication challenge %q"
+	errFmtUnauthorized   = "no credentials for registry %q"
+	errToken             = "cannot get registry token"
+	errParseManifest     = "cannot parse manifest"
+	errFmtManifestType   = "unsupported manifest media type %q"
+	errFmtManifestDigest = "manifest digest %s does not match %s"
+	errFmtLayer          = "cannot unpack layer %s"
+	errFmtLayerType      = "unsupported media type %q of untitled layer"
+	errFmtBlobDigest     = "blob digest %s does not match %s"
+	errFmtPath           = "invalid path %q"
+	errFmtTooLarge       = "artifact unpacks to more than %d bytes"
+)
+
+// Media types.
+const (
+	mediaTypeOCIManifest     = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCILayer        = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeOCILayerGzip    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeDockerLayerGzip = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+const (
+	// annotationTitle is the file name of a layer that isn't an archive.
+	annotationTitle = "org.opencontainers.image.title"
+
+	maxManifestSize  = 4 << 20
+	maxErrorBodySize = 1 << 10
+
+	defaultMaxUnpackedSize = 1 << 30
+)
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// A Client pulls OCI artifacts from registries that implement the OCI
+// distribution API.
+type Client struct {
+	http *http.Client
+	fs   afero.Afero
+
+	maxUnpackedSize int64
+}
+
+// An Option configures a new Client.
+type Option func(*Client)
+
+// WithHTTPClient configures the HTTP client used to connect to registries.
+// The default is http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithFs configures the afero filesystem implementation to which artifacts
+// are unpacked. The default is the real operating system filesystem.
+func WithFs(fs afero.Afero) Option {
+	return func(c *Client) { c.fs = fs }
+}
+
+// WithMaxUnpackedSize configures the maximum number of bytes the files of an
+// artifact may total once unpacked. Compressed layers are only bounded by
+// their digests, so this stops a small layer unpacking to fill the disk. The
+// default is 1GiB.
+func WithMaxUnpackedSize(n int64) Option {
+	return func(c *Client) { c.maxUnpackedSize = n }
+}
+
+// NewClient returns a new Client.
+func NewClient(o ...Option) *Client {
+	c := &Client{
+		http:            http.DefaultClient,
+		fs:              afero.Afero{Fs: afero.NewOsFs()},
+		maxUnpackedSize: defaultMaxUnpackedSize,
+	}
+	for _, fn := range o {
+		fn(c)
+	}
+	return c
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// Resolve the supplied reference to the digest of its manifest, using the
+// supplied credentials if the registry requires authentication. A reference
+// that specifies a digest resolves to it, but its manifest is still requested
+// so that callers without access to the artifact can't resolve it.
+func (c *Client) Resolve(ctx context.Context, ref Reference, kc Keychain) (string, error) {
+	s := c.session(ref, kc)
+	if ref.Digest != "" {
+		rsp, err := s.head(ctx, "manifests/"+ref.Digest, mediaTypeOCIManifest, mediaTypeDockerManifest)
+		if err != nil {
+			return "", err
+		}
+		_ = rsp.Body.Close()
+		return ref.Digest, nil
+	}
+	_, d, err := c.manifest(ctx, s, ref)
+	return d, err
+}
+
+// Pull the artifact at the supplied reference, unpacking its layers into the
+// supplied directory. Layers that are tar archives are extracted, and other
+// layers are written to the file named by their title annotation. The digest
+// of the manifest is verified if the reference specifies one, and the digest
+// of every layer is verified, and the unpacked files must not exceed the
+// client's maximum unpacked size. Files may have been written to the directory
+// when verification fails, so callers should pull into a directory they
+// discard on error.
+func (c *Client) Pull(ctx context.Context, ref Reference, kc Keychain, dst string) error {
+	s := c.session(ref, kc)
+	m, d, err := c.manifest(ctx, s, ref)
+	if err != nil {
+		return err
+	}
+	if ref.Digest != "" && d != ref.Digest {
+		return errors.Errorf(errFmtManifestDigest, d, ref.Digest)
+	}
+	if err := c.fs.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	b := &budget{remaining: c.maxUnpackedSize}
+	for _, l := range m.Layers {
+		if err := c.layer(ctx, s, l, dst, b); err != nil {
+			return errors.Wrapf(err, errFmtLayer, l.Digest)
+		}
+	}
+	return nil
+}
+
+// manifest returns the manifest of the supplied reference, and its digest.
+func (c *Client) manifest(ctx context.Context, s *session, ref Reference) (*manifest, string, error) {
+	rsp, err := s.get(ctx, "manifests/"+ref.identifier(), mediaTypeOCIManifest, mediaTypeDockerManifest)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rsp.Body.Close() //nolint:errcheck // Only reading.
+
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", errors.Wrap(err, errParseManifest)
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, "", errors.Wrap(err, errParseManifest)
+	}
+	mt := m.MediaType
+	if mt == "" {
+		mt, _, _ = strings.Cut(rsp.Header.Get("Content-Type"), ";")
+	}
+	if mt != mediaTypeOCIManifest && mt != mediaTypeDockerManifest {
+		return nil, "", errors.Errorf(errFmtManifestType, mt)
+	}
+	sum := sha256.Sum256(body)
+	return m, "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// layer fetches the supplied layer and unpacks it into the supplied
+// directory, verifying its digest.
+func (c *Client) layer(ctx context.Context, s *session, l descriptor, dst string, b *budget) error {
+	if !digest.MatchString(l.Digest) {
+		return errors.Errorf(errFmtDigest, l.Digest)
+	}
+	title := l.Annotations[annotationTitle]
+	switch l.MediaType {
+	case mediaTypeOCILayer, mediaTypeOCILayerGzip, mediaTypeDockerLayerGzip:
+	default:
+		if title == "" {
+			return errors.Errorf(errFmtLayerType, l.MediaType)
+		}
+	}
+
+	rsp, err := s.get(ctx, "blobs/"+l.Digest)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close() //nolint:errcheck // Only reading.
+
+	h := sha256.New()
+	r := io.TeeReader(rsp.Body, h)
+	switch l.MediaType {
+	case mediaTypeOCILayer:
+		err = c.untar(r, dst, b)
+	case mediaTypeOCILayerGzip, mediaTypeDockerLayerGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(r); err == nil {
+			err = c.untar(zr, dst, b)
+		}
+	default:
+		err = c.writeFile(dst, title, r, 0600, b)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Read anything the archive readers didn't, e.g. padding.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != l.Digest {
+		return errors.Errorf(errFmtBlobDigest, got, l.Digest)
+	}
+	return nil
+}
+
+// untar the regular files of the supplied tar archive into the supplied
+// directory. Modules consist of regular files, so links and other special
+// files are ignored.
+func (c *Client) untar(r io.Reader, dst string, b *budget) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if !filepath.IsLocal(filepath.Clean(hdr.Name)) {
+				return errors.Errorf(errFmtPath, hdr.Name)
+			}
+			if err := c.fs.MkdirAll(filepath.Join(dst, hdr.Name), 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := c.writeFile(dst, hdr.Name, tr, hdr.FileInfo().Mode().Perm(), b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// A budget is the number of bytes that remain to be unpacked from an artifact
+// before it exceeds the client's maximum unpacked size.
+type budget struct {
+	remaining int64
+}
+
+// writeFile writes the content of the supplied reader to the named file in
+// the supplied directory, deducting its size from the supplied budget. The
+// name must be local to the directory.
+func (c *Client) writeFile(dir, name string, r io.Reader, perm os.FileMode, b *budget) error {
+	if !filepath.IsLocal(name) {
+		return errors.Errorf(errFmtPath, name)
+	}
+	p := filepath.Join(dir, name)
+	if err := c.fs.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	f, err := c.fs.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm|0600)
+	if err != nil {
+		return err
+	}
+	// Read one more byte than the budget allows, to tell whether it was
+	// exceeded.
+	n, err := io.Copy(f, io.LimitReader(r, b.remaining+1))
+	b.remaining -= n
+	if err == nil && b.remaining < 0 {
+		err = errors.Errorf(errFmtTooLarge, c.maxUnpackedSize)
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// A session makes authenticated requests to the repository of a reference.
+type session struct {
+	http *http.Client
+	ref  Reference
+	cred *Credential
+
+	// auth is the value of the Authorization header, if the registry
+	// challenged us to authenticate.
+	auth string
+}
+
+func (c *Client) session(ref Reference, kc Keychain) *session {
+	s := &session{http: c.http, ref: ref}
+	if cred, ok := kc.Lookup(ref.Registry); ok {
+		s.cred = &cred
+	}
+	return s
+}
+
+// get the supplied path of the repository's API.
+func (s *session) get(ctx context.Context, path string, accept ...string) (*http.Response, error) {
+	return s.request(ctx, http.MethodGet, path, accept)
+}
+
+// head the supplied path of the repository's API.
+func (s *session) head(ctx context.Context, path string, accept ...string) (*http.Response, error) {
+	return s.request(ctx, http.MethodHead, path, accept)
+}
+
+// request the supplied path of the repository's API, authenticating and
+// retrying once if the registry challenges the request.
+func (s *session) request(ctx context.Context, method, path string, accept []string) (*http.Response, error) {
+	u := "https://" + host(s.ref.Registry) + "/v2/" + s.ref.Repository + "/" + path
+	rsp, err := s.do(ctx, method, u, accept)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtRequest, method, u)
+	}
+	if rsp.StatusCode == http.StatusUnauthorized && s.auth == "" {
+		challenge := rsp.Header.Get("WWW-Authenticate")
+		_ = rsp.Body.Close()
+		if err := s.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if rsp, err = s.do(ctx, method, u, accept); err != nil {
+			return nil, errors.Wrapf(err, errFmtRequest, method, u)
+		}
+	}
+	if rsp.StatusCode != http.StatusOK {
+		defer rsp.Body.Close() //nolint:errcheck // Only reading.
+		return nil, errors.Errorf(errFmtStatus, method, u, status(rsp))
+	}
+	return rsp, nil
+}
+
+func (s *session) do(ctx context.Context, method, u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if s.auth != "" {
+		req.Header.Set("Authorization", s.auth)
+	}
+	return s.http.Do(req)
+}
+
+// authenticate according to the supplied WWW-Authenticate challenge, per the
+// distribution token authentication specification.
+func (s *session) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if s.cred == nil {
+			return errors.Errorf(errFmtUnauthorized, s.ref.Registry)
+		}
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+		req.SetBasicAuth(s.cred.Username, s.cred.Password)
+		s.auth = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+		t, err := s.token(ctx, params)
+		if err != nil {
+			return errors.Wrap(err, errToken)
+		}
+		s.auth = "Bearer " + t
+		return nil
+	}
+	return errors.Errorf(errFmtChallenge, challenge)
+}
+
+// token gets a bearer token from the realm of the supplied challenge
+// parameters. Anonymous tokens are requested if we have no credentials.
+func (s *session) token(ctx context.Context, params string) (string, error) {
+	p := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		p[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(p["realm"])
+	if err != nil || p["realm"] == "" {
+		return "", errors.Errorf(errFmtChallenge, params)
+	}
+	q := realm.Query()
+	if svc := p["service"]; svc != "" {
+		q.Set("service", svc)
+	}
+	scope := p["scope"]
+	if scope == "" {
+		scope = "repository:" + s.ref.Repository + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if s.cred != nil {
+		req.SetBasicAuth(s.cred.Username, s.cred.Password)
+	}
+	rsp, err := s.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close() //nolint:errcheck // Only reading.
+	if rsp.StatusCode != http.StatusOK {
+		return "", errors.Errorf(errFmtStatus, http.MethodGet, realm.String(), status(rsp))
+	}
+
+	t := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(rsp.Body, maxManifestSize)).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	return t.AccessToken, nil
+}
+
+// status returns the status of the supplied response, and the start of its
+// body, which usually explains the status.
+func status(rsp *http.Response) string {
+	b, _ := io.ReadAll(io.LimitReader(rsp.Body, maxErrorBodySize))
+	if msg := strings.TrimSpace(string(b)); msg != "" {
+		return rsp.Status + ": " + msg
+	}
+	return rsp.Status
+}
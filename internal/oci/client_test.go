@@ -0,0 +1,336 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+const token = "t0k3n"
+
+// A registry is a minimal in-process implementation of the pull side of the
+// OCI distribution API. It requires token authentication if it has a user.
+type registry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	user      string
+	password  string
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if u, p, ok := req.BasicAuth(); !ok || u != r.user || p != r.password {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+		return
+	}
+	if r.user != "" && req.Header.Get("Authorization") != "Bearer "+token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="https://`+req.Host+`/token",service="registry"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var content map[string][]byte
+	var id string
+	switch path := strings.TrimPrefix(req.URL.Path, "/v2/"); {
+	case strings.Contains(path, "/manifests/"):
+		content, id = r.manifests, path[strings.LastIndex(path, "/")+1:]
+		w.Header().Set("Content-Type", mediaTypeOCIManifest)
+	case strings.Contains(path, "/blobs/"):
+		content, id = r.blobs, path[strings.LastIndex(path, "/")+1:]
+	}
+	b, ok := content[id]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	_, _ = w.Write(b)
+}
+
+func sha(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func targz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(zw)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// artifact returns a manifest for the supplied layers, and the registry
+// content that serves it at the supplied tag and its digest.
+func artifact(t *testing.T, tag string, layers ...descriptor) ([]byte, map[string][]byte) {
+	t.Helper()
+	m, err := json.Marshal(manifest{MediaType: mediaTypeOCIManifest, Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, map[string][]byte{tag: m, sha(m): m}
+}
+
+func TestPull(t *testing.T) {
+	module := targz(t, map[string]string{"main.tf": "a", "modules/vpc/main.tf": "b"})
+	readme := []byte("# Module")
+	evil := targz(t, map[string]string{"../evil.tf": "evil"})
+	bomb := targz(t, map[string]string{"main.tf": strings.Repeat("a", 1<<20)})
+
+	layers := []descriptor{
+		{MediaType: mediaTypeOCILayerGzip, Digest: sha(module)},
+		{MediaType: "text/markdown", Digest: sha(readme), Annotations: map[string]string{annotationTitle: "README.md"}},
+	}
+	m, manifests := artifact(t, "v1", layers...)
+	blobs := map[string][]byte{sha(module): module, sha(readme): readme}
+	files := map[string]string{"main.tf": "a", "modules/vpc/main.tf": "b", "README.md": "# Module"}
+
+	type want struct {
+		files map[string]string
+		err   func(host string) error
+	}
+	cases := map[string]struct {
+		reason string
+		reg    *registry
+		ref    string
+		kc     Keychain
+		max    int64
+		want   want
+	}{
+		"Tag": {
+			reason: "We should unpack the layers of an artifact referenced by tag.",
+			reg:    &registry{manifests: manifests, blobs: blobs},
+			ref:    ":v1",
+			want:   want{files: files},
+		},
+		"Digest": {
+			reason: "We should unpack the layers of an artifact referenced by digest.",
+			reg:    &registry{manifests: manifests, blobs: blobs},
+			ref:    "@" + sha(m),
+			want:   want{files: files},
+		},
+		"Authenticated": {
+			reason: "We should authenticate using the registry's credentials if it challenges us.",
+			reg:    &registry{manifests: manifests, blobs: blobs, user: "cool", password: "secret"},
+			ref:    ":v1",
+			kc:     Keychain{"": {Username: "cool", Password: "secret"}},
+			want:   want{files: files},
+		},
+		"Unauthorized": {
+			reason: "We should return an error if the registry rejects our credentials.",
+			reg:    &registry{manifests: manifests, blobs: blobs, user: "cool", password: "secret"},
+			ref:    ":v1",
+			want: want{err: func(host string) error {
+				return errors.Wrap(errors.Errorf(errFmtStatus, http.MethodGet, "https://"+host+"/token?scope=repository%3Amodules%2Fvpc%3Apull&service=registry", "401 Unauthorized: bad credentials"), errToken)
+			}},
+		},
+		"ManifestDigestMismatch": {
+			reason: "We should return an error if the manifest doesn't match the referenced digest.",
+			reg:    &registry{manifests: map[string][]byte{sha([]byte("other")): m}, blobs: blobs},
+			ref:    "@" + sha([]byte("other")),
+			want: want{err: func(_ string) error {
+				return errors.Errorf(errFmtManifestDigest, sha(m), sha([]byte("other")))
+			}},
+		},
+		"BlobDigestMismatch": {
+			reason: "We should return an error if a layer doesn't match its digest.",
+			reg:    &registry{manifests: manifests, blobs: map[string][]byte{sha(module): module, sha(readme): []byte("# Tampered")}},
+			ref:    ":v1",
+			want: want{err: func(_ string) error {
+				return errors.Wrapf(errors.Errorf(errFmtBlobDigest, sha([]byte("# Tampered")), sha(readme)), errFmtLayer, sha(readme))
+			}},
+		},
+		"PathTraversal": {
+			reason: "We should refuse to unpack files outside the destination directory.",
+			reg: func() *registry {
+				_, manifests := artifact(t, "v1", descriptor{MediaType: mediaTypeOCILayerGzip, Digest: sha(evil)})
+				return &registry{manifests: manifests, blobs: map[string][]byte{sha(evil): evil}}
+			}(),
+			ref: ":v1",
+			want: want{err: func(_ string) error {
+				return errors.Wrapf(errors.Errorf(errFmtPath, "../evil.tf"), errFmtLayer, sha(evil))
+			}},
+		},
+		"TooLarge": {
+			reason: "We should refuse to unpack more than the maximum unpacked size, however small the compressed layer.",
+			reg: func() *registry {
+				_, manifests := artifact(t, "v1", descriptor{MediaType: mediaTypeOCILayerGzip, Digest: sha(bomb)})
+				return &registry{manifests: manifests, blobs: map[string][]byte{sha(bomb): bomb}}
+			}(),
+			ref: ":v1",
+			max: 1 << 10,
+			want: want{err: func(_ string) error {
+				return errors.Wrapf(errors.Errorf(errFmtTooLarge, 1<<10), errFmtLayer, sha(bomb))
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(tc.reg)
+			defer srv.Close()
+			host := srv.Listener.Addr().String()
+
+			ref, err := ParseReference(host + "/modules/vpc" + tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kc := Keychain{}
+			for _, c := range tc.kc {
+				kc[host] = c
+			}
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			o := []Option{WithHTTPClient(srv.Client()), WithFs(fs)}
+			if tc.max != 0 {
+				o = append(o, WithMaxUnpackedSize(tc.max))
+			}
+			c := NewClient(o...)
+			err = c.Pull(context.Background(), ref, kc, "/module")
+
+			var wantErr error
+			if tc.want.err != nil {
+				wantErr = tc.want.err(host)
+			}
+			if diff := cmp.Diff(wantErr, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nc.Pull(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+
+			got := map[string]string{}
+			_ = fs.Walk("/module", func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return err
+				}
+				b, _ := fs.ReadFile(path)
+				rel, _ := filepath.Rel("/module", path)
+				got[rel] = string(b)
+				return nil
+			})
+			if diff := cmp.Diff(tc.want.files, got); diff != "" {
+				t.Errorf("\n%s\nc.Pull(...): -want files, +got files:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m, manifests := artifact(t, "v1")
+
+	type want struct {
+		digest string
+		err    func(host string) error
+	}
+	cases := map[string]struct {
+		reason string
+		reg    *registry
+		ref    string
+		kc     Keychain
+		want   want
+	}{
+		"Tag": {
+			reason: "We should resolve a tag to the digest of its manifest.",
+			reg:    &registry{manifests: manifests},
+			ref:    ":v1",
+			want:   want{digest: sha(m)},
+		},
+		"Digest": {
+			reason: "We should resolve a digest to itself.",
+			reg:    &registry{manifests: manifests, user: "cool", password: "secret"},
+			ref:    "@" + sha(m),
+			kc:     Keychain{"": {Username: "cool", Password: "secret"}},
+			want:   want{digest: sha(m)},
+		},
+		"DigestUnauthorized": {
+			reason: "We should return an error if a caller without credentials resolves a digest of a private artifact.",
+			reg:    &registry{manifests: manifests, user: "cool", password: "secret"},
+			ref:    "@" + sha(m),
+			want: want{err: func(host string) error {
+				return errors.Wrap(errors.Errorf(errFmtStatus, http.MethodGet, "https://"+host+"/token?scope=repository%3Amodules%2Fvpc%3Apull&service=registry", "401 Unauthorized: bad credentials"), errToken)
+			}},
+		},
+		"DigestNotFound": {
+			reason: "We should return an error if the referenced digest doesn't exist.",
+			reg:    &registry{manifests: manifests},
+			ref:    "@" + sha([]byte("other")),
+			want: want{err: func(host string) error {
+				return errors.Errorf(errFmtStatus, http.MethodHead, "https://"+host+"/v2/modules/vpc/manifests/"+sha([]byte("other")), "404 Not Found")
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(tc.reg)
+			defer srv.Close()
+			host := srv.Listener.Addr().String()
+
+			ref, err := ParseReference(host + "/modules/vpc" + tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kc := Keychain{}
+			for _, c := range tc.kc {
+				kc[host] = c
+			}
+
+			got, err := NewClient(WithHTTPClient(srv.Client())).Resolve(context.Background(), ref, kc)
+
+			var wantErr error
+			if tc.want.err != nil {
+				wantErr = tc.want.err(host)
+			}
+			if diff := cmp.Diff(wantErr, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nc.Resolve(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.digest, got); diff != "" {
+				t.Errorf("\n%s\nc.Resolve(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
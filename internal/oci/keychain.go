@@ -0,0 +1,79 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Error strings.
+const (
+	errParseDockerConfig = "cannot parse docker config JSON"
+	errFmtDecodeAuth     = "cannot decode auth of registry %q"
+)
+
+// A Credential authenticates to a registry.
+type Credential struct {
+	Username string
+	Password string
+}
+
+// A Keychain holds the credentials of registries, keyed by registry.
+type Keychain map[string]Credential
+
+// ParseDockerConfig parses the registry credentials of the supplied docker
+// config JSON, i.e. a ~/.docker/config.json file or the .dockerconfigjson key
+// of a kubernetes.io/dockerconfigjson Secret.
+func ParseDockerConfig(data []byte) (Keychain, error) {
+	cfg := struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, errParseDockerConfig)
+	}
+
+	kc := make(Keychain, len(cfg.Auths))
+	for registry, a := range cfg.Auths {
+		c := Credential{Username: a.Username, Password: a.Password}
+		if a.Auth != "" {
+			b, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, errors.Wrapf(err, errFmtDecodeAuth, registry)
+			}
+			c.Username, c.Password, _ = strings.Cut(string(b), ":")
+		}
+		kc[normalize(registry)] = c
+	}
+	return kc, nil
+}
+
+// Lookup returns the credential of the supplied registry, if any.
+func (k Keychain) Lookup(registry string) (Credential, bool) {
+	c, ok := k[normalize(registry)]
+	return c, ok
+}
+
+// normalize the supplied docker config registry key, which may be a URL like
+// https://index.docker.io/v1/.
+func normalize(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry, _, _ = strings.Cut(registry, "/")
+	switch registry {
+	case "index.docker.io", dockerHubAPI:
+		return DefaultRegistry
+	}
+	return registry
+}
@@ -0,0 +1,34 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package oci
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDockerConfig(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("cool:secret"))
+	cfg := `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "` + auth + `"},
+		"registry.example.org": {"username": "user", "password": "pass"}
+	}}`
+
+	kc, err := ParseDockerConfig([]byte(cfg))
+	if err != nil {
+		t.Fatalf("ParseDockerConfig(...): %v", err)
+	}
+	want := Keychain{
+		DefaultRegistry:        {Username: "cool", Password: "secret"},
+		"registry.example.org": {Username: "user", Password: "pass"},
+	}
+	if diff := cmp.Diff(want, kc); diff != "" {
+		t.Errorf("ParseDockerConfig(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package oci
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Error strings.
+const (
+	errFmtRepository = "invalid repository %q"
+	errFmtTag        = "invalid tag %q"
+	errFmtDigest     = "invalid digest %q - only sha256 digests are supported"
+)
+
+const (
+	// DefaultRegistry is the registry of references that don't specify
+	// one, consistent with docker pull.
+	DefaultRegistry = "docker.io"
+
+	defaultTag = "latest"
+
+	// dockerHubAPI serves the registry API for the default registry.
+	dockerHubAPI = "registry-1.docker.io"
+)
+
+var (
+	repository = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tag        = regexp.MustCompile(`^\w[\w.-]{0,127}$`)
+	digest     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// A Reference to an OCI artifact, e.g. registry.example.org/modules/vpc:1.0.0
+// or registry.example.org/modules/vpc@sha256:... A reference may specify both
+// a tag and a digest, in which case the digest takes precedence.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses the supplied OCI artifact reference. References that
+// specify neither a tag nor a digest refer to the latest tag.
+func ParseReference(s string) (Reference, error) {
+	name, d, pinned := strings.Cut(s, "@")
+	if pinned && !digest.MatchString(d) {
+		return Reference{}, errors.Errorf(errFmtDigest, d)
+	}
+
+	r := Reference{Registry: DefaultRegistry, Digest: d}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.Tag = name[:i], name[i+1:]
+		if !tag.MatchString(r.Tag) {
+			return Reference{}, errors.Errorf(errFmtTag, r.Tag)
+		}
+	}
+
+	// The first component of a name is a registry if it looks like a host,
+	// consistent with docker pull.
+	if host, repo, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		r.Registry, name = host, repo
+	}
+	if r.Registry == DefaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if !repository.MatchString(name) {
+		return Reference{}, errors.Errorf(errFmtRepository, name)
+	}
+	r.Repository = name
+
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+	return r, nil
+}
+
+// Name returns the registry and repository of the reference.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the reference in its canonical form.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// identifier returns the digest of the reference, or its tag if it doesn't
+// specify a digest.
+func (r Reference) identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// host returns the host that serves the registry API of the supplied registry.
+func host(registry string) string {
+	if registry == DefaultRegistry {
+		return dockerHubAPI
+	}
+	return registry
+}
@@ -0,0 +1,73 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package oci
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestParseReference(t *testing.T) {
+	d := "sha256:" + strings.Repeat("a", 64)
+
+	type want struct {
+		ref Reference
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		s      string
+		want   want
+	}{
+		"Tag": {
+			reason: "A reference may specify a registry, repository and tag.",
+			s:      "registry.example.org/modules/vpc:1.0.0",
+			want:   want{ref: Reference{Registry: "registry.example.org", Repository: "modules/vpc", Tag: "1.0.0"}},
+		},
+		"Digest": {
+			reason: "A reference may specify a digest instead of a tag.",
+			s:      "localhost:5000/vpc@" + d,
+			want:   want{ref: Reference{Registry: "localhost:5000", Repository: "vpc", Digest: d}},
+		},
+		"TagAndDigest": {
+			reason: "A reference may specify both a tag and a digest.",
+			s:      "localhost/vpc:1.0.0@" + d,
+			want:   want{ref: Reference{Registry: "localhost", Repository: "vpc", Tag: "1.0.0", Digest: d}},
+		},
+		"DefaultRegistry": {
+			reason: "A reference that doesn't specify a registry or tag should refer to the latest tag on the default registry.",
+			s:      "vpc",
+			want:   want{ref: Reference{Registry: DefaultRegistry, Repository: "library/vpc", Tag: "latest"}},
+		},
+		"InvalidRepository": {
+			reason: "Repositories must be lower case.",
+			s:      "registry.example.org/Modules/vpc",
+			want:   want{err: errors.Errorf(errFmtRepository, "Modules/vpc")},
+		},
+		"InvalidDigest": {
+			reason: "Only sha256 digests are supported.",
+			s:      "registry.example.org/vpc@md5:abc",
+			want:   want{err: errors.Errorf(errFmtDigest, "md5:abc")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReference(tc.s)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseReference(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ref, got); diff != "" {
+				t.Errorf("\n%s\nParseReference(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
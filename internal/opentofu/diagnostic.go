@@ -0,0 +1,178 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Severity of a diagnostic.
+type Severity string
+
+// Diagnostic severities.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// A Pos is a position in a source file.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// A SourceRange is a range of a source file, relative to the working
+// directory.
+type SourceRange struct {
+	Filename string `json:"filename"`
+	Start    Pos    `json:"start"`
+	End      Pos    `json:"end"`
+}
+
+// A Diagnostic is an error or a warning reported by tofu.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Summary  string   `json:"summary"`
+	Detail   string   `json:"detail,omitempty"`
+
+	// Address of the resource the diagnostic concerns, if any.
+	Address string `json:"address,omitempty"`
+
+	// Range of the configuration the diagnostic concerns, if any.
+	Range *SourceRange `json:"range,omitempty"`
+}
+
+// String returns a single line description of the diagnostic, prefixed with
+// the file and line it concerns, e.g. main.tf:3: Unsupported argument: An
+// argument named "name" is not expected here.
+func (d Diagnostic) String() string {
+	parts := make([]string, 0, 4)
+	if d.Range != nil && d.Range.Filename != "" {
+		parts = append(parts, fmt.Sprintf("%s:%d", d.Range.Filename, d.Range.Start.Line))
+	}
+	if d.Address != "" {
+		parts = append(parts, d.Address)
+	}
+	parts = append(parts, d.Summary)
+	// Details are often wrapped over several lines, which don't belong in a
+	// condition message.
+	if detail := strings.Join(strings.Fields(d.Detail), " "); detail != "" {
+		parts = append(parts, detail)
+	}
+	return strings.Join(parts, ": ")
+}
+
+// A DiagnosticsError is returned when tofu reports error diagnostics.
+type DiagnosticsError struct {
+	// Diagnostics reported by tofu, including any warnings.
+	Diagnostics []Diagnostic
+}
+
+// Errors returns the error diagnostics.
+func (e *DiagnosticsError) Errors() []Diagnostic {
+	errs := make([]Diagnostic, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+func (e *DiagnosticsError) Error() string {
+	errs := e.Errors()
+	msgs := make([]string, len(errs))
+	for i, d := range errs {
+		msgs[i] = d.String()
+	}
+	if len(msgs) == 1 {
+		return "OpenTofu encountered an error: " + msgs[0]
+	}
+	return fmt.Sprintf("OpenTofu encountered %d errors: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// A uiMessage is a line of the machine readable UI tofu emits when it is
+// invoked with -json.
+type uiMessage struct {
+	Message    string      `json:"@message"`
+	Type       string      `json:"type"`
+	Diagnostic *Diagnostic `json:"diagnostic,omitempty"`
+}
+
+// parseDiagnostics returns the diagnostics in the supplied machine readable
+// UI output. Lines that aren't JSON are ignored.
+func parseDiagnostics(out []byte) []Diagnostic {
+	var diags []Diagnostic
+	s := bufio.NewScanner(bytes.NewReader(out))
+	s.Buffer(nil, len(out)+1)
+	for s.Scan() {
+		m := uiMessage{}
+		if err := json.Unmarshal(s.Bytes(), &m); err != nil {
+			continue
+		}
+		if m.Type == "diagnostic" && m.Diagnostic != nil {
+			diags = append(diags, *m.Diagnostic)
+		}
+	}
+	return diags
+}
+
+// messages returns the human readable messages of the supplied machine
+// readable UI output, one per line. Lines that aren't JSON are returned as is.
+func messages(out []byte) []byte {
+	b := &bytes.Buffer{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	s.Buffer(nil, len(out)+1)
+	for s.Scan() {
+		m := uiMessage{}
+		if err := json.Unmarshal(s.Bytes(), &m); err != nil || m.Message == "" {
+			b.Write(s.Bytes())
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(m.Message)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// classifyJSON classifies errors returned from the OpenTofu CLI when it is
+// invoked with -json, using the error diagnostics in its machine readable UI
+// output. Errors without diagnostics, for example because tofu crashed, fall
+// back to Classify.
+func classifyJSON(out []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	if e := (&DiagnosticsError{Diagnostics: parseDiagnostics(out)}); len(e.Errors()) > 0 {
+		ce := &Error{Category: categorizeDiagnostics(e.Errors()), err: e}
+		if ce.Category == CategoryStateLocked {
+			for _, d := range e.Errors() {
+				if ce.Lock = parseLockInfo(d.Detail); ce.Lock != nil {
+					break
+				}
+			}
+		}
+		return ce
+	}
+
+	// Tofu writes diagnostics to stdout rather than stderr when it is invoked
+	// with -json, so the messages on stdout are part of the full error.
+	ee := &exec.ExitError{}
+	if errors.As(err, &ee) {
+		ee.Stderr = append(messages(out), ee.Stderr...)
+	}
+	return Classify(err)
+}
@@ -0,0 +1,178 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// planOutput is the machine readable UI output of a tofu plan that failed.
+const planOutput = `{"@level":"info","@message":"OpenTofu 1.10.0","@module":"tofu.ui","@timestamp":"2025-06-01T12:00:00.000000Z","terraform":"1.10.0","type":"version","ui":"1.2"}
+{"@level":"warn","@message":"Warning: Deprecated attribute","@module":"tofu.ui","@timestamp":"2025-06-01T12:00:00.000000Z","diagnostic":{"severity":"warning","summary":"Deprecated attribute","detail":"The attribute \"triggers\" is deprecated.\nUse \"triggers_replace\" instead.","range":{"filename":"main.tf","start":{"line":4,"column":3,"byte":52},"end":{"line":4,"column":11,"byte":60}}},"type":"diagnostic"}
+{"@level":"error","@message":"Error: Unsupported argument","@module":"tofu.ui","@timestamp":"2025-06-01T12:00:00.000000Z","diagnostic":{"severity":"error","summary":"Unsupported argument","detail":"An argument named \"name\" is not expected here.","range":{"filename":"main.tf","start":{"line":10,"column":3,"byte":120},"end":{"line":10,"column":7,"byte":124}},"snippet":{"context":"resource \"null_resource\" \"example\"","code":"  name = \"example\"","start_line":10,"highlight_start_offset":2,"highlight_end_offset":6,"values":[]}},"type":"diagnostic"}
+{"@level":"error","@message":"Error: Invalid provider configuration","@module":"tofu.ui","@timestamp":"2025-06-01T12:00:00.000000Z","diagnostic":{"severity":"error","summary":"Invalid provider configuration","detail":"","address":"null_resource.example"},"type":"diagnostic"}
+`
+
+func TestParseDiagnostics(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		out    string
+		want   []Diagnostic
+	}{
+		"Diagnostics": {
+			reason: "Errors and warnings should be parsed from the machine readable UI output.",
+			out:    planOutput,
+			want: []Diagnostic{
+				{
+					Severity: SeverityWarning,
+					Summary:  "Deprecated attribute",
+					Detail:   "The attribute \"triggers\" is deprecated.\nUse \"triggers_replace\" instead.",
+					Range: &SourceRange{
+						Filename: "main.tf",
+						Start:    Pos{Line: 4, Column: 3, Byte: 52},
+						End:      Pos{Line: 4, Column: 11, Byte: 60},
+					},
+				},
+				{
+					Severity: SeverityError,
+					Summary:  "Unsupported argument",
+					Detail:   "An argument named \"name\" is not expected here.",
+					Range: &SourceRange{
+						Filename: "main.tf",
+						Start:    Pos{Line: 10, Column: 3, Byte: 120},
+						End:      Pos{Line: 10, Column: 7, Byte: 124},
+					},
+				},
+				{
+					Severity: SeverityError,
+					Summary:  "Invalid provider configuration",
+					Address:  "null_resource.example",
+				},
+			},
+		},
+		"NotJSON": {
+			reason: "Output that isn't machine readable should be ignored.",
+			out:    "╷\n│ Error: Unsupported argument\n╵\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := parseDiagnostics([]byte(tc.out))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nparseDiagnostics(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsError(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		diags  []Diagnostic
+		want   string
+	}{
+		"OneError": {
+			reason: "A single error should be described with the file and line it concerns.",
+			diags: []Diagnostic{
+				{Severity: SeverityWarning, Summary: "Deprecated attribute"},
+				{
+					Severity: SeverityError,
+					Summary:  "Unsupported argument",
+					Detail:   "An argument named \"name\" is\nnot expected here.",
+					Range:    &SourceRange{Filename: "main.tf", Start: Pos{Line: 10}},
+				},
+			},
+			want: `OpenTofu encountered an error: main.tf:10: Unsupported argument: An argument named "name" is not expected here.`,
+		},
+		"SeveralErrors": {
+			reason: "Several errors should all be described.",
+			diags: []Diagnostic{
+				{Severity: SeverityError, Summary: "Unsupported argument", Range: &SourceRange{Filename: "main.tf", Start: Pos{Line: 10}}},
+				{Severity: SeverityError, Summary: "Invalid provider configuration", Address: "null_resource.example"},
+			},
+			want: `OpenTofu encountered 2 errors: main.tf:10: Unsupported argument; null_resource.example: Invalid provider configuration`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &DiagnosticsError{Diagnostics: tc.diags}
+			if diff := cmp.Diff(tc.want, e.Error()); diff != "" {
+				t.Errorf("\n%s\ne.Error(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestClassifyJSON(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		reason string
+		out    string
+		err    error
+		want   error
+	}{
+		"NoError": {
+			reason: "No error should be returned if tofu succeeded.",
+			out:    planOutput,
+		},
+		"NotExitError": {
+			reason: "Errors other than exit errors should be returned unchanged.",
+			err:    errBoom,
+			want:   errBoom,
+		},
+		"Diagnostics": {
+			reason: "The error diagnostics tofu reported should be returned.",
+			out:    planOutput,
+			err:    &exec.ExitError{},
+			want:   &Error{Category: CategoryConfigInvalid, err: &DiagnosticsError{Diagnostics: parseDiagnostics([]byte(planOutput))}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := classifyJSON([]byte(tc.out), tc.err)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nclassifyJSON(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestClassifyJSONFallback(t *testing.T) {
+	// Without error diagnostics the full error, including the messages tofu
+	// wrote to stdout, should be returned as a blob.
+	ee := &exec.ExitError{Stderr: []byte("panic: boom\n")}
+	out := `{"@level":"info","@message":"OpenTofu 1.10.0","type":"version"}` + "\n"
+
+	err := classifyJSON([]byte(out), ee)
+	if !strings.HasPrefix(err.Error(), "OpenTofu encountered an error. Summary: . To see the full error run:") {
+		t.Errorf("classifyJSON(...): want a blob, got %q", err)
+	}
+	if diff := cmp.Diff("OpenTofu 1.10.0\npanic: boom\n", string(ee.Stderr)); diff != "" {
+		t.Errorf("classifyJSON(...): -want stderr, +got stderr:\n%s", diff)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	out := `{"@level":"info","@message":"OpenTofu 1.10.0","type":"version"}
+not JSON
+{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy.","type":"change_summary"}
+`
+	want := "OpenTofu 1.10.0\nnot JSON\nPlan: 1 to add, 0 to change, 0 to destroy.\n"
+	if diff := cmp.Diff(want, string(messages([]byte(out)))); diff != "" {
+		t.Errorf("messages(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Category of tofu failure.
+type Category string
+
+// Categories of tofu failure.
+const (
+	// CategoryConfigInvalid failures are caused by configuration tofu can't
+	// decode or evaluate, e.g. invalid HCL.
+	CategoryConfigInvalid Category = "ConfigInvalid"
+
+	// CategoryAuth failures are caused by missing, invalid, expired or
+	// insufficiently privileged credentials.
+	CategoryAuth Category = "AuthFailed"
+
+	// CategoryStateLocked failures are caused by a state lock held by another
+	// tofu process.
+	CategoryStateLocked Category = "StateLocked"
+
+	// CategoryProviderCrash failures are caused by a provider plugin that
+	// crashed or stopped responding.
+	CategoryProviderCrash Category = "ProviderCrashed"
+
+	// CategoryRateLimited failures are caused by an API that throttled tofu.
+	CategoryRateLimited Category = "RateLimited"
+
+	// CategoryNetwork failures are caused by an API tofu couldn't connect to.
+	CategoryNetwork Category = "NetworkError"
+
+	// CategoryQuota failures are caused by an exhausted quota or service
+	// limit.
+	CategoryQuota Category = "QuotaExceeded"
+
+	// CategoryUnknown failures couldn't be categorized.
+	CategoryUnknown Category = "Unknown"
+)
+
+// Terminal returns true if failures of this category are unlikely to be
+// resolved by retrying until the configuration, credentials or quota change.
+func (c Category) Terminal() bool {
+	switch c {
+	case CategoryConfigInvalid, CategoryAuth, CategoryQuota:
+		return true
+	case CategoryStateLocked, CategoryProviderCrash, CategoryRateLimited, CategoryNetwork, CategoryUnknown:
+		return false
+	}
+	return false
+}
+
+// An Error is a failure of the tofu CLI. All errors returned by the Harness
+// because tofu failed are an *Error.
+type Error struct {
+	Category Category
+
+	// Lock that couldn't be acquired, if the failure is CategoryStateLocked
+	// and tofu reported the lock's info.
+	Lock *LockInfo
+
+	err error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// CategoryOf returns the category of the supplied error, which may wrap an
+// *Error. It returns an empty category if the error isn't a failure of tofu.
+func CategoryOf(err error) Category {
+	e := &Error{}
+	if !errors.As(err, &e) {
+		return ""
+	}
+	return e.Category
+}
+
+// IsTerminal returns true if the supplied error is a tofu failure that is
+// unlikely to be resolved by retrying.
+func IsTerminal(err error) bool {
+	return CategoryOf(err).Terminal()
+}
+
+// patterns identify each category by (lower case) substrings of tofu's
+// output. They're matched in order, so e.g. RequestLimitExceeded is a rate
+// limit rather than a quota.
+var patterns = []struct {
+	category Category
+	matches  []string
+}{
+	{CategoryStateLocked, []string{
+		"error acquiring the state lock",
+	}},
+	{CategoryProviderCrash, []string{
+		"plugin did not respond",
+		"plugin exited",
+		"the plugin encountered an error",
+		"panic:",
+	}},
+	{CategoryRateLimited, []string{
+		"throttl",
+		"rate limit",
+		"ratelimit",
+		"rate exceeded",
+		"too many requests",
+		"toomanyrequests",
+		"requestlimitexceeded",
+		"status code: 429",
+		"statuscode: 429",
+	}},
+	{CategoryQuota, []string{
+		"quota",
+		"limitexceeded",
+		"limit exceeded",
+		"resource_exhausted",
+	}},
+	{CategoryAuth, []string{
+		"unauthorized",
+		"unauthenticated",
+		"accessdenied",
+		"access denied",
+		"authorizationfailed",
+		"invalidclienttokenid",
+		"expiredtoken",
+		"signaturedoesnotmatch",
+		"no valid credential sources",
+		"invalid credentials",
+		"permission denied",
+		"status code: 401",
+		"status code: 403",
+	}},
+	{CategoryNetwork, []string{
+		"dial tcp",
+		"connection refused",
+		"connection reset",
+		"no such host",
+		"i/o timeout",
+		"tls handshake timeout",
+		"network is unreachable",
+		"timeout awaiting response headers",
+	}},
+	{CategoryConfigInvalid, []string{
+		"unsupported argument",
+		"unsupported attribute",
+		"unsupported block type",
+		"missing required argument",
+		"argument or block definition required",
+		"invalid reference",
+		"reference to undeclared",
+		"invalid expression",
+		"invalid block definition",
+		"invalid function argument",
+		"call to unknown function",
+		"no value for required variable",
+		"invalid value for variable",
+		"invalid tofu configuration",
+	}},
+}
+
+// categorize the supplied tofu output.
+func categorize(output string) Category {
+	output = strings.ToLower(output)
+	for _, p := range patterns {
+		for _, m := range p.matches {
+			if strings.Contains(output, m) {
+				return p.category
+			}
+		}
+	}
+	return CategoryUnknown
+}
+
+// categorizeDiagnostics categorizes the supplied error diagnostics by the
+// first of them that can be categorized. Errors in the configuration that
+// don't concern a resource are assumed to be invalid configuration.
+func categorizeDiagnostics(diags []Diagnostic) Category {
+	for _, d := range diags {
+		if c := categorize(d.Summary + "\n" + d.Detail); c != CategoryUnknown {
+			return c
+		}
+	}
+	for _, d := range diags {
+		if d.Range != nil && d.Address == "" {
+			return CategoryConfigInvalid
+		}
+	}
+	return CategoryUnknown
+}
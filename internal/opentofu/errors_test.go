@@ -0,0 +1,158 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestCategorize(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		output string
+		want   Category
+	}{
+		"StateLocked": {
+			reason: "A state lock held by another process should be categorized.",
+			output: "Error: Error acquiring the state lock\n\nError message: ConditionalCheckFailedException",
+			want:   CategoryStateLocked,
+		},
+		"ProviderCrash": {
+			reason: "A crashed provider should be categorized.",
+			output: "Error: Plugin did not respond\n\nThe plugin encountered an error, and failed to respond to the plugin.(*GRPCProvider).ApplyResourceChange call.",
+			want:   CategoryProviderCrash,
+		},
+		"RateLimited": {
+			reason: "A rate limit should be categorized as such, rather than as a quota.",
+			output: "Error: creating EC2 Instance: RequestLimitExceeded: Request limit exceeded.",
+			want:   CategoryRateLimited,
+		},
+		"Quota": {
+			reason: "An exhausted quota should be categorized.",
+			output: "Error: googleapi: Error 403: Quota 'CPUS' exceeded. Limit: 24.0 in region us-central1.",
+			want:   CategoryQuota,
+		},
+		"Auth": {
+			reason: "Invalid credentials should be categorized.",
+			output: "Error: creating S3 Bucket: operation error S3: CreateBucket, https response error StatusCode: 403, api error AccessDenied: Access Denied",
+			want:   CategoryAuth,
+		},
+		"Network": {
+			reason: "An API tofu can't connect to should be categorized.",
+			output: `Error: Get "https://10.0.0.1/api": dial tcp 10.0.0.1:443: connect: connection refused`,
+			want:   CategoryNetwork,
+		},
+		"ConfigInvalid": {
+			reason: "Invalid configuration should be categorized.",
+			output: "Error: Unsupported argument\n\n  on main.tf line 10\n\nAn argument named \"name\" is not expected here.",
+			want:   CategoryConfigInvalid,
+		},
+		"Unknown": {
+			reason: "Failures that match no category should be unknown.",
+			output: "Error: something unexpected happened",
+			want:   CategoryUnknown,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := categorize(tc.output)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ncategorize(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCategorizeDiagnostics(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		diags  []Diagnostic
+		want   Category
+	}{
+		"Summary": {
+			reason: "Diagnostics should be categorized by their summary and detail.",
+			diags: []Diagnostic{
+				{Severity: SeverityError, Summary: "Error acquiring the state lock", Detail: "Lock Info:\n  ID: 1234"},
+			},
+			want: CategoryStateLocked,
+		},
+		"FirstCategorized": {
+			reason: "Diagnostics should be categorized by the first that can be categorized.",
+			diags: []Diagnostic{
+				{Severity: SeverityError, Summary: "something unexpected happened", Address: "null_resource.a"},
+				{Severity: SeverityError, Summary: "creating bucket", Detail: "Throttling: Rate exceeded", Address: "aws_s3_bucket.b"},
+			},
+			want: CategoryRateLimited,
+		},
+		"ConfigRange": {
+			reason: "An uncategorized error in the configuration that doesn't concern a resource should be invalid configuration.",
+			diags: []Diagnostic{
+				{Severity: SeverityError, Summary: "Invalid for_each argument", Range: &SourceRange{Filename: "main.tf"}},
+			},
+			want: CategoryConfigInvalid,
+		},
+		"Resource": {
+			reason: "An uncategorized error that concerns a resource should be unknown.",
+			diags: []Diagnostic{
+				{Severity: SeverityError, Summary: "creating bucket", Address: "aws_s3_bucket.b", Range: &SourceRange{Filename: "main.tf"}},
+			},
+			want: CategoryUnknown,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := categorizeDiagnostics(tc.diags)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ncategorizeDiagnostics(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCategoryOf(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		err      error
+		want     Category
+		terminal bool
+	}{
+		"NotTofu": {
+			reason: "Errors that aren't tofu failures should have no category.",
+			err:    errors.New("boom"),
+			want:   "",
+		},
+		"Wrapped": {
+			reason: "The category of a wrapped tofu failure should be returned.",
+			err:    errors.Wrap(Classify(&exec.ExitError{Stderr: []byte("Error: Unsupported argument\n")}), "cannot plan"),
+			want:   CategoryConfigInvalid,
+			// Retrying invalid configuration won't fix it.
+			terminal: true,
+		},
+		"Transient": {
+			reason: "Transient failures should not be terminal.",
+			err:    Classify(&exec.ExitError{Stderr: []byte("Error: dial tcp: lookup example.org: no such host\n")}),
+			want:   CategoryNetwork,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, CategoryOf(tc.err)); diff != "" {
+				t.Errorf("\n%s\nCategoryOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if got := IsTerminal(tc.err); got != tc.terminal {
+				t.Errorf("\n%s\nIsTerminal(...): want %t, got %t", tc.reason, tc.terminal, got)
+			}
+		})
+	}
+}
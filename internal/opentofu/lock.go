@@ -0,0 +1,154 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockCreated is the layout in which tofu reports when a lock was created,
+// i.e. that of time.Time's String method.
+const lockCreated = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// lockField matches the fields of the lock info tofu reports when it can't
+// acquire a state lock, e.g. "  ID:        8c2a1c0e-...".
+var lockField = regexp.MustCompile(`(?m)^[\s│]*(ID|Path|Operation|Who|Version|Created|Info):[ \t]*(.*?)[ \t]*$`)
+
+// revisionHash matches the hash Crossplane appends to the name of a provider
+// revision, which is also the name of the revision's Deployment.
+var revisionHash = regexp.MustCompile(`-[0-9a-f]{12}$`)
+
+// LockInfo describes a state lock held by a tofu process.
+type LockInfo struct {
+	// ID of the lock. It is required to force unlock the state.
+	ID string
+
+	// Path of the locked state.
+	Path string
+
+	// Operation the lock was acquired for, e.g. OperationTypeApply.
+	Operation string
+
+	// Who acquired the lock, as user@host.
+	Who string
+
+	// Version of tofu that acquired the lock.
+	Version string
+
+	// Created is when the lock was acquired. It is zero if unknown.
+	Created time.Time
+
+	// Info is any additional information about the lock.
+	Info string
+}
+
+// HeldBy returns true if the lock was acquired by the supplied holder, as
+// returned by LockHolder. Hosts are compared without the suffixes Kubernetes
+// appends to the names of the pods of a Deployment, or the hash Crossplane
+// appends to the name of a provider revision's Deployment, so a lock acquired
+// by a pod that has since been replaced, even by an upgrade, is held by its
+// replacement. Tofu records only the pod's host name, so locks acquired by a
+// Deployment that was since renamed, e.g. by a DeploymentRuntimeConfig, are
+// never held by the holder.
+func (l LockInfo) HeldBy(holder string) bool {
+	lu, lh, _ := strings.Cut(l.Who, "@")
+	hu, hh, _ := strings.Cut(holder, "@")
+	return lu == hu && provider(lh) == provider(hh)
+}
+
+// provider returns the name of the provider the supplied pod belongs to, i.e.
+// the name of its Deployment without any provider revision hash.
+func provider(pod string) string {
+	return revisionHash.ReplaceAllString(deployment(pod), "")
+}
+
+// deployment returns the supplied pod name without the pod template hash and
+// random suffix Kubernetes appends to the names of a Deployment's pods, e.g.
+// provider-opentofu-6d4f8b7c9-x2k8p is a pod of provider-opentofu.
+func deployment(pod string) string {
+	parts := strings.Split(pod, "-")
+	if len(parts) < 3 {
+		return pod
+	}
+	return strings.Join(parts[:len(parts)-2], "-")
+}
+
+// LockHolder returns the user@host identity tofu run by this process records
+// as the holder of the state locks it acquires.
+func LockHolder() string {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", name, host)
+}
+
+// LockOf returns the state lock that caused the supplied error, which may wrap
+// an *Error. It returns nil if the error wasn't caused by a state lock.
+func LockOf(err error) *LockInfo {
+	e := &Error{}
+	if !errors.As(err, &e) {
+		return nil
+	}
+	return e.Lock
+}
+
+// parseLockInfo parses the lock info tofu reports when it can't acquire a
+// state lock. It returns nil if the supplied output doesn't identify a lock.
+func parseLockInfo(output string) *LockInfo {
+	l := &LockInfo{}
+	for _, m := range lockField.FindAllStringSubmatch(output, -1) {
+		switch m[1] {
+		case "ID":
+			l.ID = m[2]
+		case "Path":
+			l.Path = m[2]
+		case "Operation":
+			l.Operation = m[2]
+		case "Who":
+			l.Who = m[2]
+		case "Version":
+			l.Version = m[2]
+		case "Created":
+			l.Created, _ = time.Parse(lockCreated, m[2])
+		case "Info":
+			l.Info = m[2]
+		}
+	}
+	if l.ID == "" {
+		return nil
+	}
+	return l
+}
+
+// ForceUnlock removes the state lock with the supplied ID. It must only be
+// used to remove a lock whose holder is no longer running.
+func (h Harness) ForceUnlock(ctx context.Context, id string) error {
+	cmd := exec.Command(h.Path, "force-unlock", "-force", "-no-color", id) //nolint:gosec
+	cmd.Dir = h.Dir
+	if len(h.Envs) > 0 {
+		cmd.Env = append(os.Environ(), h.Envs...)
+	}
+
+	if h.UsePluginCache {
+		rwmutex.RLock()
+		defer rwmutex.RUnlock()
+	}
+
+	_, err := runCommand(ctx, cmd)
+	return Classify(err)
+}
@@ -0,0 +1,180 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"encoding/json"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// lockedDetail is the detail of the diagnostic tofu reports when it can't
+// acquire a state lock.
+const lockedDetail = `Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        8c2a1c0e-6f4e-4b7a-9d0e-1f2a3b4c5d6e
+  Path:      state/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       nobody@provider-opentofu-6d4f8b7c9-x2k8p
+  Version:   1.10.0
+  Created:   2025-06-01 12:00:00.123456789 +0000 UTC
+  Info:
+
+OpenTofu acquires a state lock to protect the state from being written
+by multiple users at the same time.`
+
+func TestParseLockInfo(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		output string
+		want   *LockInfo
+	}{
+		"LockInfo": {
+			reason: "The lock info should be parsed from tofu's output.",
+			output: lockedDetail,
+			want: &LockInfo{
+				ID:        "8c2a1c0e-6f4e-4b7a-9d0e-1f2a3b4c5d6e",
+				Path:      "state/terraform.tfstate",
+				Operation: "OperationTypeApply",
+				Who:       "nobody@provider-opentofu-6d4f8b7c9-x2k8p",
+				Version:   "1.10.0",
+				Created:   time.Date(2025, 6, 1, 12, 0, 0, 123456789, time.UTC),
+			},
+		},
+		"HumanReadable": {
+			reason: "The lock info should be parsed from tofu's human readable output.",
+			output: "╷\n│ Error: Error acquiring the state lock\n│ \n│ Lock Info:\n│   ID:        8c2a1c0e\n│   Who:       nobody@host\n╵\n",
+			want:   &LockInfo{ID: "8c2a1c0e", Who: "nobody@host"},
+		},
+		"NoLock": {
+			reason: "Output without lock info should not identify a lock.",
+			output: "Error: Unsupported argument",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := parseLockInfo(tc.output)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nparseLockInfo(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHeldBy(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		who    string
+		holder string
+		want   bool
+	}{
+		"SamePod": {
+			reason: "A lock acquired by the holder should be held by it.",
+			who:    "nobody@provider-opentofu-6d4f8b7c9-x2k8p",
+			holder: "nobody@provider-opentofu-6d4f8b7c9-x2k8p",
+			want:   true,
+		},
+		"ReplacedPod": {
+			reason: "A lock acquired by another pod of the same Deployment should be held by the holder.",
+			who:    "nobody@provider-opentofu-6d4f8b7c9-x2k8p",
+			holder: "nobody@provider-opentofu-7f9c6d5b4-q8w2e",
+			want:   true,
+		},
+		"UpgradedProvider": {
+			reason: "A lock acquired by a pod of a previous revision of the same provider should be held by the holder.",
+			who:    "nobody@upbound-provider-opentofu-0a1b2c3d4e5f-6d4f8b7c9-x2k8p",
+			holder: "nobody@upbound-provider-opentofu-9f8e7d6c5b4a-7f9c6d5b4-q8w2e",
+			want:   true,
+		},
+		"OtherProvider": {
+			reason: "A lock acquired by a pod of another provider's revision should not be held by the holder.",
+			who:    "nobody@upbound-provider-other-0a1b2c3d4e5f-6d4f8b7c9-x2k8p",
+			holder: "nobody@upbound-provider-opentofu-0a1b2c3d4e5f-6d4f8b7c9-x2k8p",
+		},
+		"OtherDeployment": {
+			reason: "A lock acquired by a pod of another Deployment should not be held by the holder.",
+			who:    "nobody@other-provider-6d4f8b7c9-x2k8p",
+			holder: "nobody@provider-opentofu-6d4f8b7c9-x2k8p",
+		},
+		"OtherUser": {
+			reason: "A lock acquired by another user should not be held by the holder.",
+			who:    "alice@provider-opentofu-6d4f8b7c9-x2k8p",
+			holder: "nobody@provider-opentofu-6d4f8b7c9-x2k8p",
+		},
+		"Workstation": {
+			reason: "Hosts that aren't pods should be compared as is.",
+			who:    "nobody@laptop",
+			holder: "nobody@desktop",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := LockInfo{Who: tc.who}.HeldBy(tc.holder)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nHeldBy(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLockOf(t *testing.T) {
+	locked := `{"@level":"error","@message":"Error: Error acquiring the state lock","type":"diagnostic","diagnostic":{"severity":"error","summary":"Error acquiring the state lock","detail":` + quote(lockedDetail) + `}}` + "\n"
+
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   string
+	}{
+		"Locked": {
+			reason: "The lock should be returned for a state lock failure.",
+			err:    errors.Wrap(classifyJSON([]byte(locked), &exec.ExitError{}), "cannot apply"),
+			want:   "8c2a1c0e-6f4e-4b7a-9d0e-1f2a3b4c5d6e",
+		},
+		"NotLocked": {
+			reason: "No lock should be returned for other failures.",
+			err:    classifyJSON([]byte(planOutput), &exec.ExitError{}),
+		},
+		"NotTofu": {
+			reason: "No lock should be returned for errors that aren't tofu failures.",
+			err:    errors.New("boom"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if l := LockOf(tc.err); l != nil {
+				got = l.ID
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nLockOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWithLockTimeout(t *testing.T) {
+	o := &options{}
+	WithLockTimeout(90 * time.Second)(o)
+	if diff := cmp.Diff([]string{"-lock-timeout=1m30s"}, o.lockArgs()); diff != "" {
+		t.Errorf("lockArgs(): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(Digest(), Digest(WithLockTimeout(time.Minute))); diff != "" {
+		t.Errorf("Digest(...): the lock timeout should not affect the digest: -want, +got:\n%s", diff)
+	}
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s) //nolint:errchkjson // Marshalling strings cannot fail.
+	return string(b)
+}
@@ -12,7 +12,9 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -24,6 +26,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
 	"github.com/pkg/errors"
@@ -32,6 +35,7 @@ import (
 // Error strings.
 const (
 	errParse            = "cannot parse tofu output"
+	errParsePlan        = "cannot parse tofu plan"
 	errWriteVarFile     = "cannot write tfvars file"
 	errFmtInvalidConfig = "invalid tofu configuration: found %d errors"
 	errRunCommand       = "shutdown while running tofu command"
@@ -44,11 +48,20 @@ const (
 
 const varFilePrefix = "crossplane-provider-opentofu-"
 
+// PlanFile is the name of the file, relative to the harness directory, to
+// which Plan saves its binary plan.
+const PlanFile = "crossplane-provider-opentofu.tfplan"
+
 // OpenTofu often returns a summary of the error it encountered on a single
 // line, prefixed with 'Error: '.
 var tfError = regexp.MustCompile(`Error: (.+)\n`)
 
 // Classify errors returned from the OpenTofu CLI by inspecting its stderr.
+// Errors are returned as an *Error, categorized by the failure tofu reported.
+// The full stderr is embedded in the error as a gzipped, base64 encoded blob.
+// Commands invoked with -json are classified using the diagnostics in their
+// machine readable output instead, and only fall back to Classify if there are
+// none.
 func Classify(err error) error {
 	ee := &exec.ExitError{}
 	if !errors.As(err, &ee) {
@@ -62,7 +75,11 @@ func Classify(err error) error {
 
 	formatString := "OpenTofu encountered an error. Summary: %s. To see the full error run: echo \"%s\" | base64 -d | gunzip"
 
-	return errors.New(fmt.Sprintf(formatString, summary, base64FullErr))
+	e := &Error{Category: categorize(string(ee.Stderr)), err: errors.New(fmt.Sprintf(formatString, summary, base64FullErr))}
+	if e.Category == CategoryStateLocked {
+		e.Lock = parseLockInfo(string(ee.Stderr))
+	}
+	return e
 }
 
 // Format OpenTofu error output as gzipped and base64 encoded string
@@ -309,9 +326,9 @@ func (h Harness) DeleteCurrentWorkspace(ctx context.Context) error {
 	return Classify(err)
 }
 
-// GenerateChecksum calculates the md5sum of the workspace (excluding installed providers) to see if opentofu init needs to run
+// GenerateChecksum calculates the md5sum of the workspace (excluding installed providers and saved plans) to see if opentofu init needs to run
 func (h Harness) GenerateChecksum(ctx context.Context) (string, error) {
-	command := "/usr/bin/find . -path ./.git -prune -o -path ./.opentofu/providers -prune -o -type f -exec /usr/bin/md5sum {} + | LC_ALL=C /usr/bin/sort | /usr/bin/md5sum | /usr/bin/awk '{print $1}'"
+	command := "/usr/bin/find . -path ./.git -prune -o -path ./.opentofu/providers -prune -o -name '*.tfplan' -prune -o -type f -exec /usr/bin/md5sum {} + | LC_ALL=C /usr/bin/sort | /usr/bin/md5sum | /usr/bin/awk '{print $1}'"
 	cmd := exec.Command("/bin/sh", "-c", command) //nolint:gosec
 	cmd.Dir = h.Dir
 
@@ -359,6 +376,13 @@ type Output struct {
 	value any
 }
 
+// NewOutput returns an output of the supplied type with the supplied value.
+// The value must be of the type encoding/json would decode the output's JSON
+// representation to, for example map[string]any for an object.
+func NewOutput(name string, t OutputType, sensitive bool, value any) Output {
+	return Output{Name: name, Type: t, Sensitive: sensitive, value: value}
+}
+
 // Value returns the output's actual value.
 func (o Output) Value() any {
 	return o.value
@@ -485,8 +509,41 @@ type varFile struct {
 }
 
 type options struct {
-	args     []string
-	varFiles []varFile
+	args        []string
+	varFiles    []varFile
+	sensitive   []string
+	lockTimeout time.Duration
+}
+
+// lockArgs returns the arguments that configure how long tofu waits to acquire
+// the state lock.
+func (o *options) lockArgs() []string {
+	if o.lockTimeout <= 0 {
+		return nil
+	}
+	return []string{"-lock-timeout=" + o.lockTimeout.String()}
+}
+
+// redacted replaces sensitive values in tofu's output.
+const redacted = "(sensitive value)"
+
+// redact sensitive values from the supplied command output, and from the
+// stderr of the supplied error if it is an *exec.ExitError. Values are also
+// redacted as they appear in tofu's machine readable output, where they are
+// JSON string escaped.
+func (o *options) redact(out []byte, err error) ([]byte, error) {
+	ee := &exec.ExitError{}
+	isExitErr := errors.As(err, &ee)
+	for _, v := range o.sensitive {
+		escaped, _ := json.Marshal(v) //nolint:errchkjson // Marshalling strings cannot fail.
+		for _, b := range [][]byte{[]byte(v), escaped[1 : len(escaped)-1]} {
+			out = bytes.ReplaceAll(out, b, []byte(redacted))
+			if isExitErr {
+				ee.Stderr = bytes.ReplaceAll(ee.Stderr, b, []byte(redacted))
+			}
+		}
+	}
+	return out, err
 }
 
 // An Option affects how tofu is invoked.
@@ -499,6 +556,15 @@ func WithArgs(v []string) Option {
 	}
 }
 
+// WithLockTimeout configures how long tofu retries acquiring the state lock
+// before it fails. Unlike arguments supplied by WithArgs it also applies to
+// saved plans, and doesn't affect the Digest of the options.
+func WithLockTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.lockTimeout = d
+	}
+}
+
 // WithVar supplies a Terraform variable.
 func WithVar(k, v string) Option {
 	return func(o *options) {
@@ -506,6 +572,51 @@ func WithVar(k, v string) Option {
 	}
 }
 
+// WithJSONVars supplies Terraform variables with JSON encoded values. Unlike
+// WithVar the variables are written to a generated tfvars.json file rather
+// than passed on the command line, so values of any type are supported and
+// values do not appear in the process list. Values must be valid JSON.
+func WithJSONVars(vars map[string]json.RawMessage) Option {
+	// Marshalling only fails if a value is not valid JSON, in which case tofu
+	// will fail to parse the resulting empty var file.
+	data, _ := json.Marshal(vars) //nolint:errchkjson // See above.
+	return WithVarFile(data, JSON)
+}
+
+// WithSensitiveVars supplies Terraform variables with sensitive string
+// values. Like WithJSONVars the variables are written to a generated
+// tfvars.json file. Their values are also redacted from tofu's output, both
+// when it is logged and when it is returned as an error.
+func WithSensitiveVars(vars map[string]string) Option {
+	data, _ := json.Marshal(vars) //nolint:errchkjson // Marshalling strings cannot fail.
+	vf := WithVarFile(data, JSON)
+	return func(o *options) {
+		vf(o)
+		for _, v := range vars {
+			if v != "" {
+				o.sensitive = append(o.sensitive, v)
+			}
+		}
+	}
+}
+
+// WithHCLVars supplies Terraform variables whose values are HCL expressions.
+// The variables are written to a generated tfvars file rather than passed on
+// the command line. Variable names must be valid HCL identifiers.
+func WithHCLVars(vars map[string]string) Option {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	for _, k := range names {
+		fmt.Fprintf(b, "%s = %s\n", k, vars[k])
+	}
+	return WithVarFile([]byte(b.String()), HCL)
+}
+
 // The FileFormat of a Terraform file.
 type FileFormat int
 
@@ -529,6 +640,29 @@ func WithVarFile(data []byte, f FileFormat) Option {
 	}
 }
 
+// Digest returns a digest of the arguments and variable files the supplied
+// options would pass to tofu. Callers may use it to determine whether a saved
+// plan was created from the same inputs.
+func Digest(o ...Option) string {
+	do := &options{}
+	for _, fn := range o {
+		fn(do)
+	}
+
+	h := sha256.New()
+	for _, a := range do.args {
+		h.Write([]byte(a))
+		h.Write([]byte{0})
+	}
+	for _, vf := range do.varFiles {
+		h.Write([]byte(vf.filename))
+		h.Write([]byte{0})
+		h.Write(vf.data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Diff invokes 'opentofu plan' to determine whether there is a diff between
 // the desired and the actual state of the configuration. It returns true if
 // there is a diff.
@@ -544,7 +678,7 @@ func (h Harness) Diff(ctx context.Context, o ...Option) (bool, error) {
 		}
 	}
 
-	args := append([]string{"plan", "-no-color", "-input=false", "-detailed-exitcode", "-lock=false"}, ao.args...)
+	args := append([]string{"plan", "-json", "-no-color", "-input=false", "-detailed-exitcode", "-lock=false"}, ao.args...)
 	cmd := exec.Command(h.Path, args...) //nolint:gosec
 	cmd.Dir = h.Dir
 	if len(h.Envs) > 0 {
@@ -559,21 +693,97 @@ func (h Harness) Diff(ctx context.Context, o ...Option) (bool, error) {
 	// 0 - Succeeded, diff is empty (no changes)
 	// 1 - Errored
 	// 2 - Succeeded, there is a diff
-	log, err := runCommand(ctx, cmd)
+	log, err := ao.redact(runCommand(ctx, cmd))
 	switch cmd.ProcessState.ExitCode() {
 	case 1:
 		ee := &exec.ExitError{}
 		errors.As(err, &ee)
 		if h.EnableTofuCLILogging {
-			h.Logger.Info(string(ee.Stderr), "operation", "plan")
+			h.Logger.Info(string(messages(log))+string(ee.Stderr), "operation", "plan")
 		}
 	case 2:
 		if h.EnableTofuCLILogging {
-			h.Logger.Info(string(log), "operation", "plan")
+			h.Logger.Info(string(messages(log)), "operation", "plan")
 		}
 		return true, nil
 	}
-	return false, Classify(err)
+	return false, classifyJSON(log, err)
+}
+
+// Plan invokes 'tofu plan' and saves the resulting binary plan to PlanFile in
+// the harness directory. It returns the saved plan as reported by 'tofu show
+// -json'.
+func (h Harness) Plan(ctx context.Context, o ...Option) (*Plan, error) {
+	return h.plan(ctx, nil, o...)
+}
+
+// RefreshOnlyPlan invokes 'tofu plan -refresh-only' and saves the resulting
+// binary plan to PlanFile in the harness directory. A refresh-only plan only
+// updates the state to match the actual infrastructure; it never proposes
+// changes to make the infrastructure match the configuration. Use the plan's
+// Drift to determine what changed outside of tofu.
+func (h Harness) RefreshOnlyPlan(ctx context.Context, o ...Option) (*Plan, error) {
+	return h.plan(ctx, []string{"-refresh-only"}, o...)
+}
+
+// plan invokes 'tofu plan' in the supplied mode, e.g. -refresh-only, and
+// saves the resulting binary plan to PlanFile.
+func (h Harness) plan(ctx context.Context, mode []string, o ...Option) (*Plan, error) {
+	po := &options{}
+	for _, fn := range o {
+		fn(po)
+	}
+
+	for _, vf := range po.varFiles {
+		if err := os.WriteFile(filepath.Join(h.Dir, vf.filename), vf.data, 0600); err != nil {
+			return nil, errors.Wrap(err, errWriteVarFile)
+		}
+	}
+
+	args := append([]string{"plan", "-json", "-no-color", "-input=false", "-lock=false", "-out=" + PlanFile}, mode...)
+	args = append(args, po.args...)
+	cmd := exec.Command(h.Path, args...) //nolint:gosec
+	cmd.Dir = h.Dir
+	if len(h.Envs) > 0 {
+		cmd.Env = append(os.Environ(), h.Envs...)
+	}
+
+	// Like Diff, Plan does not take the opentofu lock or the rwmutex.
+	log, err := po.redact(runCommand(ctx, cmd))
+	if err != nil {
+		ee := &exec.ExitError{}
+		if errors.As(err, &ee) && h.EnableTofuCLILogging {
+			h.Logger.Info(string(messages(log))+string(ee.Stderr), "operation", "plan")
+		}
+		return nil, classifyJSON(log, err)
+	}
+	if h.EnableTofuCLILogging {
+		h.Logger.Info(string(messages(log)), "operation", "plan")
+	}
+
+	return h.showPlan(ctx)
+}
+
+// showPlan renders the saved PlanFile as JSON and parses it.
+func (h Harness) showPlan(ctx context.Context) (*Plan, error) {
+	cmd := exec.Command(h.Path, "show", "-json", PlanFile) //nolint:gosec
+	cmd.Dir = h.Dir
+	if len(h.Envs) > 0 {
+		cmd.Env = append(os.Environ(), h.Envs...)
+	}
+
+	if h.UsePluginCache {
+		rwmutex.RLock()
+		defer rwmutex.RUnlock()
+	}
+
+	out, err := runCommand(ctx, cmd)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	p, err := parsePlan(out)
+	return p, errors.Wrap(err, errParsePlan)
 }
 
 // Apply a tofu configuration.
@@ -589,7 +799,8 @@ func (h Harness) Apply(ctx context.Context, o ...Option) error {
 		}
 	}
 
-	args := append([]string{"apply", "-no-color", "-auto-approve", "-input=false"}, ao.args...)
+	args := append([]string{"apply", "-json", "-no-color", "-auto-approve", "-input=false"}, ao.lockArgs()...)
+	args = append(args, ao.args...)
 	cmd := exec.Command(h.Path, args...) //nolint:gosec
 	cmd.Dir = h.Dir
 	if len(h.Envs) > 0 {
@@ -605,20 +816,59 @@ func (h Harness) Apply(ctx context.Context, o ...Option) error {
 	// 0 - Succeeded
 	// Non Zero output - Errored
 
-	log, err := runCommand(ctx, cmd)
+	log, err := ao.redact(runCommand(ctx, cmd))
 	switch cmd.ProcessState.ExitCode() {
 	case 0:
 		if h.EnableTofuCLILogging {
-			h.Logger.Info(string(log), "operation", "apply")
+			h.Logger.Info(string(messages(log)), "operation", "apply")
 		}
 	default:
 		ee := &exec.ExitError{}
 		errors.As(err, &ee)
 		if h.EnableTofuCLILogging {
-			h.Logger.Info(string(ee.Stderr), "operation", "apply")
+			h.Logger.Info(string(messages(log))+string(ee.Stderr), "operation", "apply")
 		}
 	}
-	return Classify(err)
+	return classifyJSON(log, err)
+}
+
+// ApplyPlan applies the plan previously saved to PlanFile by Plan. Unlike
+// Apply it does not plan again, so exactly the observed changes are made. Tofu
+// refuses to apply a saved plan if the state has changed since it was created.
+// Variables and arguments supplied by the options are ignored because they
+// are part of the saved plan, but sensitive values are still redacted.
+func (h Harness) ApplyPlan(ctx context.Context, o ...Option) error {
+	ao := &options{}
+	for _, fn := range o {
+		fn(ao)
+	}
+
+	args := append([]string{"apply", "-json", "-no-color", "-input=false"}, ao.lockArgs()...)
+	cmd := exec.Command(h.Path, append(args, PlanFile)...) //nolint:gosec
+	cmd.Dir = h.Dir
+	if len(h.Envs) > 0 {
+		cmd.Env = append(os.Environ(), h.Envs...)
+	}
+
+	if h.UsePluginCache {
+		rwmutex.RLock()
+		defer rwmutex.RUnlock()
+	}
+
+	log, err := ao.redact(runCommand(ctx, cmd))
+	switch cmd.ProcessState.ExitCode() {
+	case 0:
+		if h.EnableTofuCLILogging {
+			h.Logger.Info(string(messages(log)), "operation", "apply")
+		}
+	default:
+		ee := &exec.ExitError{}
+		errors.As(err, &ee)
+		if h.EnableTofuCLILogging {
+			h.Logger.Info(string(messages(log))+string(ee.Stderr), "operation", "apply")
+		}
+	}
+	return classifyJSON(log, err)
 }
 
 // Destroy a tofu configuration.
@@ -634,7 +884,8 @@ func (h Harness) Destroy(ctx context.Context, o ...Option) error {
 		}
 	}
 
-	args := append([]string{"destroy", "-no-color", "-auto-approve", "-input=false"}, do.args...)
+	args := append([]string{"destroy", "-json", "-no-color", "-auto-approve", "-input=false"}, do.lockArgs()...)
+	args = append(args, do.args...)
 	cmd := exec.Command(h.Path, args...) //nolint:gosec
 	cmd.Dir = h.Dir
 	if len(h.Envs) > 0 {
@@ -646,7 +897,7 @@ func (h Harness) Destroy(ctx context.Context, o ...Option) error {
 		defer rwmutex.RUnlock()
 	}
 
-	log, err := runCommand(ctx, cmd)
+	log, err := do.redact(runCommand(ctx, cmd))
 
 	// In case of opentofu destroy
 	// 0 - Succeeded
@@ -654,16 +905,16 @@ func (h Harness) Destroy(ctx context.Context, o ...Option) error {
 	switch cmd.ProcessState.ExitCode() {
 	case 0:
 		if h.EnableTofuCLILogging {
-			h.Logger.Info(string(log), "operation", "destroy")
+			h.Logger.Info(string(messages(log)), "operation", "destroy")
 		}
 	default:
 		ee := &exec.ExitError{}
 		errors.As(err, &ee)
 		if h.EnableTofuCLILogging {
-			h.Logger.Info(string(ee.Stderr), "operation", "destroy")
+			h.Logger.Info(string(messages(log))+string(ee.Stderr), "operation", "destroy")
 		}
 	}
-	return Classify(err)
+	return classifyJSON(log, err)
 }
 
 // cmdResult represents the result of the command execution
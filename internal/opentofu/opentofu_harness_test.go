@@ -466,6 +466,22 @@ func TestInitDiffApplyDestroy(t *testing.T) {
 	}
 }
 
+func TestVersion(t *testing.T) {
+	// Reading the version is a read-only operation, so we operate directly on
+	// our test data instead of creating a temporary directory.
+	tf := Harness{Path: tofuBinaryPath, Dir: "testdata/outputmodule"}
+	v, err := tf.Version(context.Background())
+	if err != nil {
+		t.Fatalf("tf.Version(...): %v", err)
+	}
+	if err := v.CheckSupported(); err != nil {
+		t.Errorf("v.CheckSupported(): %v", err)
+	}
+	if v.Platform == "" {
+		t.Errorf("tf.Version(...): want a platform, got none")
+	}
+}
+
 func TestGenerateChecksum(t *testing.T) {
 	type want struct {
 		output string
@@ -503,3 +519,95 @@ func TestGenerateChecksum(t *testing.T) {
 		})
 	}
 }
+
+func TestPlan(t *testing.T) {
+	type want struct {
+		summary string
+		changes bool
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		o      []Option
+		want   want
+	}{
+		"NewModule": {
+			reason: "Planning a module that has never been applied should create all of its resources and save the plan.",
+			want: want{
+				summary: "2 to add, 0 to change, 0 to destroy",
+				changes: true,
+			},
+		},
+		"UndeclaredVar": {
+			reason: "Plan should return an error when supplied a variable not declared by the module",
+			o:      []Option{WithVar("boop", "doop!")},
+			want: want{
+				err: errors.New("value for undeclared variable"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "provider-opentofu-test")
+			if err != nil {
+				t.Fatalf("Cannot create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			tf := Harness{Path: tofuBinaryPath, Dir: dir, UsePluginCache: false}
+			if err := tf.Init(context.Background(), FromModule(filepath.Join(tofuTestDataPath(), "nullmodule"))); err != nil {
+				t.Fatalf("tf.Init(...): %v", err)
+			}
+
+			p, err := tf.Plan(context.Background(), tc.o...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ntf.Plan(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.summary, p.Summary().String()); diff != "" {
+				t.Errorf("\n%s\ntf.Plan(...): -want summary, +got summary:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.changes, p.HasChanges()); diff != "" {
+				t.Errorf("\n%s\ntf.Plan(...): -want changes, +got changes:\n%s", tc.reason, diff)
+			}
+			if _, err := os.Stat(filepath.Join(dir, PlanFile)); err != nil {
+				t.Errorf("\n%s\ntf.Plan(...): expected saved plan file: %v", tc.reason, err)
+			}
+			if err := tf.ApplyPlan(context.Background()); err != nil {
+				t.Errorf("\n%s\ntf.ApplyPlan(...): unexpected error: %v", tc.reason, err)
+			}
+			if err := tf.Destroy(context.Background(), tc.o...); err != nil {
+				t.Errorf("\n%s\ntf.Destroy(...): unexpected error: %v", tc.reason, err)
+			}
+		})
+	}
+}
+
+func TestRefreshOnlyPlan(t *testing.T) {
+	dir, err := os.MkdirTemp("", "provider-opentofu-test")
+	if err != nil {
+		t.Fatalf("Cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tf := Harness{Path: tofuBinaryPath, Dir: dir, UsePluginCache: false}
+	if err := tf.Init(context.Background(), FromModule(filepath.Join(tofuTestDataPath(), "nullmodule"))); err != nil {
+		t.Fatalf("tf.Init(...): %v", err)
+	}
+
+	// A refresh-only plan never proposes changes to match the configuration,
+	// even for a module that has never been applied.
+	p, err := tf.RefreshOnlyPlan(context.Background())
+	if err != nil {
+		t.Fatalf("tf.RefreshOnlyPlan(...): %v", err)
+	}
+	if diff := cmp.Diff("0 to add, 0 to change, 0 to destroy", p.Summary().String()); diff != "" {
+		t.Errorf("tf.RefreshOnlyPlan(...): -want summary, +got summary:\n%s", diff)
+	}
+	if diff := cmp.Diff(0, len(p.Drift())); diff != "" {
+		t.Errorf("tf.RefreshOnlyPlan(...): -want drift, +got drift:\n%s", diff)
+	}
+}
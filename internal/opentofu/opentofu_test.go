@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package opentofu
 
 import (
+	"encoding/json"
 	"os/exec"
 	"testing"
 
@@ -148,6 +149,94 @@ func TestOutputJSONValue(t *testing.T) {
 	}
 }
 
+func TestWithVars(t *testing.T) {
+	type want struct {
+		args     []string
+		varFiles []varFile
+	}
+	cases := map[string]struct {
+		reason string
+		o      []Option
+		want   want
+	}{
+		"JSONVars": {
+			reason: "JSON variables should be written to a tfvars.json file, sorted by name.",
+			o: []Option{WithJSONVars(map[string]json.RawMessage{
+				"zones": json.RawMessage(`["a", "b"]`),
+				"name":  json.RawMessage(`"cool"`),
+			})},
+			want: want{
+				args: []string{"-var-file=crossplane-provider-opentofu-0.tfvars.json"},
+				varFiles: []varFile{{
+					filename: "crossplane-provider-opentofu-0.tfvars.json",
+					data:     []byte(`{"name":"cool","zones":["a","b"]}`),
+				}},
+			},
+		},
+		"HCLVars": {
+			reason: "HCL variables should be written to a tfvars file after any preceding var files, sorted by name.",
+			o: []Option{
+				WithVarFile([]byte(`a = "b"`), HCL),
+				WithHCLVars(map[string]string{
+					"tags": `{ team = "platform" }`,
+					"size": "3",
+				}),
+			},
+			want: want{
+				args: []string{"-var-file=crossplane-provider-opentofu-0.tfvars", "-var-file=crossplane-provider-opentofu-1.tfvars"},
+				varFiles: []varFile{
+					{filename: "crossplane-provider-opentofu-0.tfvars", data: []byte(`a = "b"`)},
+					{filename: "crossplane-provider-opentofu-1.tfvars", data: []byte("size = 3\ntags = { team = \"platform\" }\n")},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := &options{}
+			for _, fn := range tc.o {
+				fn(got)
+			}
+			if diff := cmp.Diff(tc.want.args, got.args); diff != "" {
+				t.Errorf("\n%s\nargs: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.varFiles, got.varFiles, cmp.AllowUnexported(varFile{})); diff != "" {
+				t.Errorf("\n%s\nvarFiles: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	o := &options{}
+	WithSensitiveVars(map[string]string{"password": "hunter2", "empty": ""})(o)
+
+	ee := &exec.ExitError{Stderr: []byte("Error: invalid password hunter2")}
+	out, err := o.redact([]byte("password = hunter2"), errors.Wrap(ee, "boom"))
+
+	if diff := cmp.Diff("password = (sensitive value)", string(out)); diff != "" {
+		t.Errorf("redact(...): -want output, +got output:\n%s", diff)
+	}
+	if diff := cmp.Diff("Error: invalid password (sensitive value)", string(ee.Stderr)); diff != "" {
+		t.Errorf("redact(...): -want stderr, +got stderr:\n%s", diff)
+	}
+	if !errors.Is(err, ee) {
+		t.Errorf("redact(...): want the supplied error to be returned, got %v", err)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	o := &options{}
+	WithSensitiveVars(map[string]string{"password": `"hunter2"<`})(o)
+
+	out, _ := o.redact([]byte(`{"@message":"password = \"hunter2\"\u003c"}`), nil)
+
+	if diff := cmp.Diff(`{"@message":"password = (sensitive value)"}`, string(out)); diff != "" {
+		t.Errorf("redact(...): -want output, +got output:\n%s", diff)
+	}
+}
+
 func TestClassify(t *testing.T) {
 	tferrs := make(map[string]error)
 	expectedOutput := make(map[string]error)
@@ -0,0 +1,277 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"reflect"
+	"sort"
+)
+
+// An Action that tofu plans to take against a resource or output.
+type Action string
+
+// Plan actions. A replacement is represented as a combination of the delete
+// and create actions.
+const (
+	ActionNoOp   Action = "no-op"
+	ActionCreate Action = "create"
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// A Change describes how a resource or output will change when a plan is
+// applied. Values are kept as raw JSON, exactly as rendered by tofu show.
+type Change struct {
+	Actions         []Action        `json:"actions"`
+	Before          json.RawMessage `json:"before,omitempty"`
+	After           json.RawMessage `json:"after,omitempty"`
+	AfterUnknown    json.RawMessage `json:"after_unknown,omitempty"`
+	BeforeSensitive json.RawMessage `json:"before_sensitive,omitempty"`
+	AfterSensitive  json.RawMessage `json:"after_sensitive,omitempty"`
+}
+
+func (c Change) is(a ...Action) bool {
+	if len(c.Actions) != len(a) {
+		return false
+	}
+	for i := range a {
+		if c.Actions[i] != a[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsNoOp returns true if the change does nothing.
+func (c Change) IsNoOp() bool {
+	return len(c.Actions) == 0 || c.is(ActionNoOp) || c.is(ActionRead)
+}
+
+// IsCreate returns true if the change creates a new object.
+func (c Change) IsCreate() bool {
+	return c.is(ActionCreate)
+}
+
+// IsUpdate returns true if the change updates an object in place.
+func (c Change) IsUpdate() bool {
+	return c.is(ActionUpdate)
+}
+
+// IsDelete returns true if the change deletes an object.
+func (c Change) IsDelete() bool {
+	return c.is(ActionDelete)
+}
+
+// IsReplace returns true if the change deletes and recreates an object, in
+// either order.
+func (c Change) IsReplace() bool {
+	return c.is(ActionDelete, ActionCreate) || c.is(ActionCreate, ActionDelete)
+}
+
+// A ResourceChange describes a planned change to a single resource instance.
+type ResourceChange struct {
+	Address       string `json:"address"`
+	ModuleAddress string `json:"module_address,omitempty"`
+	Mode          string `json:"mode"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	ProviderName  string `json:"provider_name"`
+	ActionReason  string `json:"action_reason,omitempty"`
+	Change        Change `json:"change"`
+}
+
+// An OutputChange describes a planned change to a root module output.
+type OutputChange struct {
+	Name string
+	Change
+}
+
+// A Plan is the machine readable representation of a tofu plan.
+type Plan struct {
+	// ResourceChanges that will be made when the plan is applied.
+	ResourceChanges []ResourceChange
+
+	// OutputChanges that will be made when the plan is applied.
+	OutputChanges []OutputChange
+
+	// ResourceDrift contains changes tofu detected between the last known
+	// state and the actual infrastructure while refreshing.
+	ResourceDrift []ResourceChange
+}
+
+// A PlanSummary counts the resources a plan will add, change and destroy.
+// Replaced resources are counted as both added and destroyed, consistent
+// with the summary printed by tofu plan.
+type PlanSummary struct {
+	Add     int
+	Change  int
+	Destroy int
+}
+
+// String returns the summary in the format used by tofu plan, e.g. "3 to
+// add, 1 to change, 0 to destroy".
+func (s PlanSummary) String() string {
+	return fmt.Sprintf("%d to add, %d to change, %d to destroy", s.Add, s.Change, s.Destroy)
+}
+
+// Summary returns a summary of the managed resource changes in the plan.
+func (p *Plan) Summary() PlanSummary {
+	s := PlanSummary{}
+	for _, rc := range p.ResourceChanges {
+		if rc.Mode != "managed" {
+			continue
+		}
+		switch {
+		case rc.Change.IsCreate():
+			s.Add++
+		case rc.Change.IsUpdate():
+			s.Change++
+		case rc.Change.IsDelete():
+			s.Destroy++
+		case rc.Change.IsReplace():
+			s.Add++
+			s.Destroy++
+		}
+	}
+	return s
+}
+
+// HasChanges returns true if applying the plan would change any resource or
+// output.
+func (p *Plan) HasChanges() bool {
+	for _, rc := range p.ResourceChanges {
+		if !rc.Change.IsNoOp() {
+			return true
+		}
+	}
+	for _, oc := range p.OutputChanges {
+		if !oc.IsNoOp() {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns a digest of the changes the plan would make. Plans that would
+// make the same changes have the same hash, even if they were saved at
+// different times or the state they were planned against was refreshed in the
+// meantime. Changes that do nothing don't contribute to the hash.
+func (p *Plan) Hash() string {
+	h := sha256.New()
+	for _, rc := range p.ResourceChanges {
+		if !rc.Change.IsNoOp() {
+			writeChange(h, rc.Address, rc.Change)
+		}
+	}
+	for _, oc := range p.OutputChanges {
+		if !oc.IsNoOp() {
+			writeChange(h, "output."+oc.Name, oc.Change)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeChange writes the supplied change to the supplied hash. The state
+// before the change is omitted, because refreshing it doesn't change what the
+// change would do.
+func writeChange(h hash.Hash, address string, c Change) {
+	h.Write([]byte(address))
+	h.Write([]byte{0})
+	for _, a := range c.Actions {
+		h.Write([]byte(a))
+		h.Write([]byte{0})
+	}
+	for _, v := range []json.RawMessage{c.After, c.AfterUnknown} {
+		h.Write(v)
+		h.Write([]byte{0})
+	}
+}
+
+// A Drift describes how a resource changed outside of tofu since tofu last
+// recorded it in the state.
+type Drift struct {
+	// Address of the resource instance, e.g. aws_s3_bucket.example.
+	Address string
+
+	// Deleted is true if the resource no longer exists.
+	Deleted bool
+
+	// Attributes whose values changed, sorted by name. Only top level
+	// attributes are reported. It is empty if the resource was deleted.
+	Attributes []string
+}
+
+// Drift returns the managed resources that changed outside of tofu, in the
+// order tofu reported them.
+func (p *Plan) Drift() []Drift {
+	d := make([]Drift, 0, len(p.ResourceDrift))
+	for _, rc := range p.ResourceDrift {
+		if rc.Mode != "managed" || rc.Change.IsNoOp() {
+			continue
+		}
+		if rc.Change.IsDelete() {
+			d = append(d, Drift{Address: rc.Address, Deleted: true})
+			continue
+		}
+		d = append(d, Drift{Address: rc.Address, Attributes: changedAttributes(rc.Change.Before, rc.Change.After)})
+	}
+	return d
+}
+
+// changedAttributes returns the sorted names of the top level attributes
+// whose values differ between the supplied JSON objects.
+func changedAttributes(before, after json.RawMessage) []string {
+	b := map[string]any{}
+	a := map[string]any{}
+	_ = json.Unmarshal(before, &b)
+	_ = json.Unmarshal(after, &a)
+
+	changed := make([]string, 0)
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || !reflect.DeepEqual(bv, v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// parsePlan parses the output of tofu show -json for a saved plan file.
+func parsePlan(data []byte) (*Plan, error) {
+	type plan struct {
+		ResourceChanges []ResourceChange  `json:"resource_changes"`
+		OutputChanges   map[string]Change `json:"output_changes"`
+		ResourceDrift   []ResourceChange  `json:"resource_drift"`
+	}
+
+	pl := &plan{}
+	if err := json.Unmarshal(data, pl); err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		ResourceChanges: pl.ResourceChanges,
+		ResourceDrift:   pl.ResourceDrift,
+		OutputChanges:   make([]OutputChange, 0, len(pl.OutputChanges)),
+	}
+	for name, c := range pl.OutputChanges {
+		p.OutputChanges = append(p.OutputChanges, OutputChange{Name: name, Change: c})
+	}
+	sort.Slice(p.OutputChanges, func(i, j int) bool { return p.OutputChanges[i].Name < p.OutputChanges[j].Name })
+	return p, nil
+}
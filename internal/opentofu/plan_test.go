@@ -0,0 +1,216 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParsePlan(t *testing.T) {
+	type want struct {
+		p       *Plan
+		summary string
+		changes bool
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"NoChanges": {
+			reason: "A plan without changes should have no changes and an empty summary.",
+			data: `{
+				"format_version": "1.2",
+				"resource_changes": [
+					{"address": "null_resource.test", "mode": "managed", "type": "null_resource", "name": "test", "provider_name": "registry.opentofu.org/hashicorp/null", "change": {"actions": ["no-op"]}}
+				],
+				"output_changes": {
+					"coolness": {"actions": ["no-op"], "before": "very", "after": "very"}
+				}
+			}`,
+			want: want{
+				p: &Plan{
+					ResourceChanges: []ResourceChange{
+						{Address: "null_resource.test", Mode: "managed", Type: "null_resource", Name: "test", ProviderName: "registry.opentofu.org/hashicorp/null", Change: Change{Actions: []Action{ActionNoOp}}},
+					},
+					OutputChanges: []OutputChange{
+						{Name: "coolness", Change: Change{Actions: []Action{ActionNoOp}, Before: json.RawMessage(`"very"`), After: json.RawMessage(`"very"`)}},
+					},
+				},
+				summary: "0 to add, 0 to change, 0 to destroy",
+				changes: false,
+			},
+		},
+		"ManyChanges": {
+			reason: "Replacements should be counted as both an add and a destroy, and data sources should not be counted.",
+			data: `{
+				"resource_changes": [
+					{"address": "null_resource.a", "mode": "managed", "change": {"actions": ["create"]}},
+					{"address": "null_resource.b", "mode": "managed", "change": {"actions": ["update"]}},
+					{"address": "null_resource.c", "mode": "managed", "change": {"actions": ["delete"]}},
+					{"address": "null_resource.d", "mode": "managed", "change": {"actions": ["delete", "create"]}, "action_reason": "replace_because_cannot_update"},
+					{"address": "data.null_data_source.e", "mode": "data", "change": {"actions": ["read"]}}
+				],
+				"resource_drift": [
+					{"address": "null_resource.b", "mode": "managed", "change": {"actions": ["update"]}}
+				]
+			}`,
+			want: want{
+				p: &Plan{
+					ResourceChanges: []ResourceChange{
+						{Address: "null_resource.a", Mode: "managed", Change: Change{Actions: []Action{ActionCreate}}},
+						{Address: "null_resource.b", Mode: "managed", Change: Change{Actions: []Action{ActionUpdate}}},
+						{Address: "null_resource.c", Mode: "managed", Change: Change{Actions: []Action{ActionDelete}}},
+						{Address: "null_resource.d", Mode: "managed", ActionReason: "replace_because_cannot_update", Change: Change{Actions: []Action{ActionDelete, ActionCreate}}},
+						{Address: "data.null_data_source.e", Mode: "data", Change: Change{Actions: []Action{ActionRead}}},
+					},
+					OutputChanges: []OutputChange{},
+					ResourceDrift: []ResourceChange{
+						{Address: "null_resource.b", Mode: "managed", Change: Change{Actions: []Action{ActionUpdate}}},
+					},
+				},
+				summary: "2 to add, 1 to change, 2 to destroy",
+				changes: true,
+			},
+		},
+		"OutputChangesOnly": {
+			reason: "A plan that only changes outputs should have changes.",
+			data: `{
+				"output_changes": {
+					"b": {"actions": ["update"]},
+					"a": {"actions": ["create"]}
+				}
+			}`,
+			want: want{
+				p: &Plan{
+					OutputChanges: []OutputChange{
+						{Name: "a", Change: Change{Actions: []Action{ActionCreate}}},
+						{Name: "b", Change: Change{Actions: []Action{ActionUpdate}}},
+					},
+				},
+				summary: "0 to add, 0 to change, 0 to destroy",
+				changes: true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parsePlan([]byte(tc.data))
+			if err != nil {
+				t.Fatalf("\n%s\nparsePlan(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.p, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nparsePlan(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.summary, got.Summary().String()); diff != "" {
+				t.Errorf("\n%s\np.Summary(): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.changes, got.HasChanges()); diff != "" {
+				t.Errorf("\n%s\np.HasChanges(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPlanHash(t *testing.T) {
+	create := Change{Actions: []Action{ActionCreate}, After: json.RawMessage(`{"a":"b"}`)}
+	update := Change{Actions: []Action{ActionUpdate}, After: json.RawMessage(`{"a":"b"}`)}
+
+	cases := map[string]struct {
+		reason string
+		a      *Plan
+		b      *Plan
+		want   bool
+	}{
+		"SameChanges": {
+			reason: "Plans that make the same changes should have the same hash.",
+			a:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: create}}},
+			b:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: create}}},
+			want:   true,
+		},
+		"DifferentActions": {
+			reason: "Plans that take different actions should have different hashes.",
+			a:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: create}}},
+			b:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: update}}},
+			want:   false,
+		},
+		"DifferentAddresses": {
+			reason: "Plans that change different resources should have different hashes.",
+			a:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: create}}},
+			b:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.c", Change: create}}},
+			want:   false,
+		},
+		"DifferentBefore": {
+			reason: "Plans that make the same changes to refreshed resources should have the same hash.",
+			a:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: Change{Actions: []Action{ActionUpdate}, Before: json.RawMessage(`{"a":"c","t":1}`), After: json.RawMessage(`{"a":"b"}`)}}}},
+			b:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: Change{Actions: []Action{ActionUpdate}, Before: json.RawMessage(`{"a":"c","t":2}`), After: json.RawMessage(`{"a":"b"}`)}}}},
+			want:   true,
+		},
+		"DifferentNoOps": {
+			reason: "Plans that make the same changes should have the same hash, regardless of the resources they don't change.",
+			a: &Plan{ResourceChanges: []ResourceChange{
+				{Address: "a.b", Change: create},
+				{Address: "a.c", Change: Change{Actions: []Action{ActionNoOp}, Before: json.RawMessage(`{"t":1}`), After: json.RawMessage(`{"t":1}`)}},
+			}},
+			b:    &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Change: create}}},
+			want: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.a.Hash() == tc.b.Hash()
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\na.Hash() == b.Hash(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPlanDrift(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		p      *Plan
+		want   []Drift
+	}{
+		"NoDrift": {
+			reason: "A plan without drift should report none.",
+			p:      &Plan{ResourceChanges: []ResourceChange{{Address: "a.b", Mode: "managed", Change: Change{Actions: []Action{ActionCreate}}}}},
+		},
+		"Drift": {
+			reason: "Changed top level attributes and deleted resources should be reported, but not data sources or unchanged resources.",
+			p: &Plan{ResourceDrift: []ResourceChange{
+				{Address: "aws_s3_bucket.a", Mode: "managed", Change: Change{
+					Actions: []Action{ActionUpdate},
+					Before:  json.RawMessage(`{"bucket":"a","tags":{"team":"x"},"acl":"private","policy":"{}"}`),
+					After:   json.RawMessage(`{"bucket":"a","tags":{"team":"y"},"acl":"public-read","versioning":true}`),
+				}},
+				{Address: "aws_instance.b", Mode: "managed", Change: Change{Actions: []Action{ActionDelete}, Before: json.RawMessage(`{"id":"i-123"}`)}},
+				{Address: "aws_instance.c", Mode: "managed", Change: Change{Actions: []Action{ActionNoOp}}},
+				{Address: "data.aws_ami.d", Mode: "data", Change: Change{Actions: []Action{ActionUpdate}}},
+			}},
+			want: []Drift{
+				{Address: "aws_s3_bucket.a", Attributes: []string{"acl", "policy", "tags", "versioning"}},
+				{Address: "aws_instance.b", Deleted: true},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.p.Drift()
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\np.Drift(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+// Error strings.
+const (
+	errParseVersion      = "cannot parse tofu version"
+	errFmtUnsupported    = "tofu %s is not supported; the minimum supported version is %s"
+	errFmtInvalidVersion = "tofu reported invalid version %q"
+)
+
+// MinimumVersion is the oldest version of tofu the Harness supports. It is
+// the first generally available release of OpenTofu.
+const MinimumVersion = "1.6.0"
+
+// A Feature of tofu that only some versions support.
+type Feature string
+
+// Features of tofu.
+const (
+	// FeatureStateEncryption is client-side encryption of state and plan
+	// files, configured by the encryption block.
+	FeatureStateEncryption Feature = "StateEncryption"
+
+	// FeatureExclude is the -exclude flag of plan and apply, which is the
+	// inverse of -target.
+	FeatureExclude Feature = "Exclude"
+)
+
+// features maps each feature to the versions that support it.
+var features = map[Feature]version.Constraints{
+	FeatureStateEncryption: version.MustConstraints(version.NewConstraint(">= 1.7.0")),
+	FeatureExclude:         version.MustConstraints(version.NewConstraint(">= 1.9.0")),
+}
+
+var minimum = version.Must(version.NewVersion(MinimumVersion))
+
+// A Version of tofu, as reported by tofu version -json.
+type Version struct {
+	// Version of tofu, e.g. 1.10.0.
+	Version string
+
+	// Platform tofu was built for, e.g. linux_amd64.
+	Platform string
+
+	// Providers maps the source address of each provider selected by the
+	// working directory's dependency lock file to its version. It is empty
+	// if the working directory hasn't been initialized.
+	Providers map[string]string
+
+	v *version.Version
+}
+
+// Supports returns true if this version of tofu supports the supplied feature.
+func (v Version) Supports(f Feature) bool {
+	c, ok := features[f]
+	return ok && v.v != nil && c.Check(v.v.Core())
+}
+
+// CheckSupported returns an error if the Harness doesn't support this version
+// of tofu.
+func (v Version) CheckSupported() error {
+	if v.v == nil || v.v.Core().LessThan(minimum) {
+		return errors.Errorf(errFmtUnsupported, v.Version, MinimumVersion)
+	}
+	return nil
+}
+
+// NewVersion returns the supplied version of tofu, built for the supplied
+// platform.
+func NewVersion(v, platform string) (*Version, error) {
+	parsed, err := version.NewVersion(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtInvalidVersion, v)
+	}
+	return &Version{Version: v, Platform: platform, v: parsed}, nil
+}
+
+// Version returns the version of tofu, and the versions of the providers
+// selected by the working directory.
+func (h Harness) Version(ctx context.Context) (*Version, error) {
+	cmd := exec.Command(h.Path, "version", "-json") //nolint:gosec
+	cmd.Dir = h.Dir
+	if len(h.Envs) > 0 {
+		cmd.Env = append(os.Environ(), h.Envs...)
+	}
+
+	out, err := runCommand(ctx, cmd)
+	if err != nil {
+		return nil, Classify(err)
+	}
+	return parseVersion(out)
+}
+
+// tofu version -json uses the field names of terraform version -json.
+type versionJSON struct {
+	Version   string            `json:"terraform_version"`
+	Platform  string            `json:"platform"`
+	Providers map[string]string `json:"provider_selections"`
+}
+
+func parseVersion(data []byte) (*Version, error) {
+	j := versionJSON{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, errors.Wrap(err, errParseVersion)
+	}
+	v, err := NewVersion(j.Version, j.Platform)
+	if err != nil {
+		return nil, err
+	}
+	v.Providers = j.Providers
+	return v, nil
+}
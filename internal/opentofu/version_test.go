@@ -0,0 +1,111 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package opentofu
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseVersion(t *testing.T) {
+	type want struct {
+		v         *Version
+		supported bool
+		features  map[Feature]bool
+		err       bool
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Initialized": {
+			reason: "The versions of the providers selected by an initialized working directory should be returned.",
+			data: `{
+				"terraform_version": "1.10.0",
+				"platform": "linux_amd64",
+				"provider_selections": {"registry.opentofu.org/hashicorp/null": "3.2.3"},
+				"terraform_outdated": false
+			}`,
+			want: want{
+				v: &Version{
+					Version:   "1.10.0",
+					Platform:  "linux_amd64",
+					Providers: map[string]string{"registry.opentofu.org/hashicorp/null": "3.2.3"},
+				},
+				supported: true,
+				features:  map[Feature]bool{FeatureStateEncryption: true, FeatureExclude: true},
+			},
+		},
+		"PreRelease": {
+			reason: "A pre-release should support the features of the release.",
+			data:   `{"terraform_version": "1.9.0-beta1", "platform": "darwin_arm64", "provider_selections": {}}`,
+			want: want{
+				v: &Version{
+					Version:   "1.9.0-beta1",
+					Platform:  "darwin_arm64",
+					Providers: map[string]string{},
+				},
+				supported: true,
+				features:  map[Feature]bool{FeatureStateEncryption: true, FeatureExclude: true},
+			},
+		},
+		"Old": {
+			reason: "A version that supports only some features should report that.",
+			data:   `{"terraform_version": "1.7.3", "platform": "linux_arm64"}`,
+			want: want{
+				v:         &Version{Version: "1.7.3", Platform: "linux_arm64"},
+				supported: true,
+				features:  map[Feature]bool{FeatureStateEncryption: true, FeatureExclude: false},
+			},
+		},
+		"Unsupported": {
+			reason: "Versions older than the minimum version should not be supported.",
+			data:   `{"terraform_version": "1.5.7", "platform": "linux_amd64"}`,
+			want: want{
+				v:         &Version{Version: "1.5.7", Platform: "linux_amd64"},
+				supported: false,
+				features:  map[Feature]bool{FeatureStateEncryption: false, FeatureExclude: false},
+			},
+		},
+		"InvalidVersion": {
+			reason: "An invalid version should return an error.",
+			data:   `{"terraform_version": "latest"}`,
+			want:   want{err: true},
+		},
+		"InvalidJSON": {
+			reason: "Output that isn't JSON should return an error.",
+			data:   `OpenTofu v1.10.0`,
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := parseVersion([]byte(tc.data))
+			if (err != nil) != tc.want.err {
+				t.Fatalf("\n%s\nparseVersion(...): want error %t, got %v", tc.reason, tc.want.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.v, v, cmpopts.IgnoreUnexported(Version{})); diff != "" {
+				t.Errorf("\n%s\nparseVersion(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if got := v.CheckSupported() == nil; got != tc.want.supported {
+				t.Errorf("\n%s\nv.CheckSupported(): want supported %t, got %t", tc.reason, tc.want.supported, got)
+			}
+			for f, want := range tc.want.features {
+				if got := v.Supports(f); got != want {
+					t.Errorf("\n%s\nv.Supports(%s): want %t, got %t", tc.reason, f, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package tofuversion resolves OpenTofu versions to tofu binaries, so that
+// workspaces can keep using a version of tofu other than the one installed in
+// the provider image.
+package tofuversion
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/afero"
+
+	"github.com/upbound/provider-opentofu/internal/opentofu"
+)
+
+// Error strings.
+const (
+	errFmtVersion      = "invalid tofu version %q"
+	errFmtNotInstalled = "tofu version %s is not installed, and no mirror is configured"
+	errFmtDownload     = "cannot download %s"
+	errFmtStatus       = "cannot download %s: %s"
+	errFmtNoChecksum   = "%s does not list a checksum for %s"
+	errFmtChecksum     = "checksum of %s is %s, not %s"
+	errFmtNoBinary     = "%s does not contain a tofu binary"
+	errStat            = "cannot determine whether tofu version is installed"
+	errInstall         = "cannot install tofu"
+	errUnzip           = "cannot unzip tofu release"
+)
+
+const (
+	// Binary is the filename of the tofu binary.
+	Binary = "tofu"
+
+	tmpPrefix = "tmp-"
+)
+
+// version matches the OpenTofu versions that may be resolved, e.g. 1.10.0 or
+// 1.11.0-beta1.
+var version = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.]+)?$`)
+
+// An Installer resolves OpenTofu versions to tofu binaries. Each version is
+// installed in a directory named for the version, e.g. 1.10.0/tofu. Versions
+// that aren't installed are downloaded from a mirror of OpenTofu releases, if
+// one is configured.
+//
+// An Installer is also a prometheus.Collector, which exports the versions of
+// tofu that ran workspaces.
+type Installer struct {
+	dir            string
+	fs             afero.Afero
+	client         *http.Client
+	mirror         string
+	defaultPath    string
+	defaultVersion string
+	os             string
+	arch           string
+	log            logging.Logger
+	info           *prometheus.GaugeVec
+
+	mu sync.Mutex
+
+	// versions reported by the binaries that were run, keyed by path.
+	versions   map[string]opentofu.Version
+	versionsMu sync.Mutex
+}
+
+// An Option configures a new Installer.
+type Option func(*Installer)
+
+// WithFs configures the afero filesystem implementation in which versions are
+// installed. The default is the real operating system filesystem.
+func WithFs(fs afero.Afero) Option {
+	return func(i *Installer) { i.fs = fs }
+}
+
+// WithHTTPClient configures the HTTP client used to download versions from
+// the mirror. The default is http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(i *Installer) { i.client = c }
+}
+
+// WithMirror configures the base URL of a mirror of OpenTofu releases, e.g.
+// https://github.com/opentofu/opentofu/releases/download. The mirror must have
+// the layout of OpenTofu's GitHub releases, i.e. v1.10.0/tofu_1.10.0_SHA256SUMS
+// and v1.10.0/tofu_1.10.0_linux_amd64.zip. By default versions aren't
+// downloaded.
+func WithMirror(url string) Option {
+	return func(i *Installer) { i.mirror = strings.TrimSuffix(url, "/") }
+}
+
+// WithDefault configures the tofu binary that is used when no version is
+// requested, and its version. The version may be empty if it isn't known. The
+// default binary is tofu, looked up in the PATH.
+func WithDefault(path, version string) Option {
+	return func(i *Installer) {
+		i.defaultPath = path
+		i.defaultVersion = strings.TrimPrefix(version, "v")
+	}
+}
+
+// WithPlatform configures the operating system and architecture of the tofu
+// binaries that are downloaded. The default is the platform of the provider.
+func WithPlatform(os, arch string) Option {
+	return func(i *Installer) { i.os, i.arch = os, arch }
+}
+
+// WithLogger configures the logger.
+func WithLogger(l logging.Logger) Option {
+	return func(i *Installer) { i.log = l }
+}
+
+// New returns an Installer that installs versions in the supplied directory.
+func New(dir string, o ...Option) *Installer {
+	i := &Installer{
+		dir:         dir,
+		fs:          afero.Afero{Fs: afero.NewOsFs()},
+		client:      http.DefaultClient,
+		defaultPath: Binary,
+		os:          runtime.GOOS,
+		arch:        runtime.GOARCH,
+		log:         logging.NewNopLogger(),
+		versions:    map[string]opentofu.Version{},
+		info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "opentofu_version_info",
+			Help: "Versions of tofu that ran workspaces since the provider started. The value is always 1.",
+		}, []string{"version", "platform"}),
+	}
+	for _, fn := range o {
+		fn(i)
+	}
+	return i
+}
+
+// Resolve returns the path of the tofu binary of the supplied version, and the
+// version it resolved to. The default binary is returned if the supplied
+// version is empty, or is the version of the default binary. Versions that
+// aren't installed are downloaded from the mirror.
+func (i *Installer) Resolve(ctx context.Context, v string) (string, string, error) {
+	v = strings.TrimPrefix(v, "v")
+	if v == "" || v == i.defaultVersion {
+		return i.defaultPath, i.defaultVersion, nil
+	}
+	if !version.MatchString(v) {
+		return "", "", errors.Errorf(errFmtVersion, v)
+	}
+
+	path := filepath.Join(i.dir, v, Binary)
+	ok, err := i.installed(path)
+	if err != nil || ok {
+		return path, v, err
+	}
+	if i.mirror == "" {
+		return "", "", errors.Errorf(errFmtNotInstalled, v)
+	}
+
+	// Versions are rarely installed, so they're installed one at a time.
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if ok, err := i.installed(path); err != nil || ok {
+		return path, v, err
+	}
+	i.log.Debug("Installing tofu", "version", v, "mirror", i.mirror)
+	return path, v, i.install(ctx, v, path)
+}
+
+// Version returns the version of the tofu binary at the supplied path, as
+// reported by the supplied function, e.g. Harness.Version. The function is only
+// called the first time the version of a binary is requested, so the returned
+// version never includes the providers selected by a working directory.
+func (i *Installer) Version(ctx context.Context, path string, fn func(ctx context.Context) (*opentofu.Version, error)) (*opentofu.Version, error) {
+	i.versionsMu.Lock()
+	defer i.versionsMu.Unlock()
+	if v, ok := i.versions[path]; ok {
+		return &v, nil
+	}
+	v, err := fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cached := *v
+	cached.Providers = nil
+	i.versions[path] = cached
+	return &cached, nil
+}
+
+// Record that the supplied version of tofu, built for the supplied platform,
+// ran a workspace.
+func (i *Installer) Record(version, platform string) {
+	i.info.WithLabelValues(version, platform).Set(1)
+}
+
+// Describe implements prometheus.Collector.
+func (i *Installer) Describe(ch chan<- *prometheus.Desc) {
+	i.info.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (i *Installer) Collect(ch chan<- prometheus.Metric) {
+	i.info.Collect(ch)
+}
+
+func (i *Installer) installed(path string) (bool, error) {
+	fi, err := i.fs.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, errStat)
+	}
+	return fi.Mode().IsRegular(), nil
+}
+
+// install downloads the supplied version from the mirror, verifies its
+// checksum, and installs its binary at the supplied path.
+func (i *Installer) install(ctx context.Context, v, path string) error {
+	sums := fmt.Sprintf("tofu_%s_SHA256SUMS", v)
+	archive := fmt.Sprintf("tofu_%s_%s_%s.zip", v, i.os, i.arch)
+
+	want, err := i.checksum(ctx, v, sums, archive)
+	if err != nil {
+		return err
+	}
+
+	if err := i.fs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, errInstall)
+	}
+	zf, err := i.fs.TempFile(filepath.Dir(path), tmpPrefix)
+	if err != nil {
+		return errors.Wrap(err, errInstall)
+	}
+	defer i.fs.Remove(zf.Name()) //nolint:errcheck // Only fails if the file doesn't exist.
+	defer zf.Close()             //nolint:errcheck // The archive is only read once it is written.
+
+	body, err := i.get(ctx, v, archive)
+	if err != nil {
+		return err
+	}
+	defer body.Close() //nolint:errcheck // Nothing was written.
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(zf, h), body)
+	if err != nil {
+		return errors.Wrapf(err, errFmtDownload, archive)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return errors.Errorf(errFmtChecksum, archive, got, want)
+	}
+
+	return errors.Wrap(i.unzip(zf, size, archive, path), errUnzip)
+}
+
+// checksum returns the sha256 checksum of the supplied archive listed by the
+// supplied SHA256SUMS file.
+func (i *Installer) checksum(ctx context.Context, v, sums, archive string) (string, error) {
+	body, err := i.get(ctx, v, sums)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close() //nolint:errcheck // Nothing was written.
+
+	s := bufio.NewScanner(body)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == archive {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", errors.Wrapf(err, errFmtDownload, sums)
+	}
+	return "", errors.Errorf(errFmtNoChecksum, sums, archive)
+}
+
+func (i *Installer) get(ctx context.Context, v, file string) (io.ReadCloser, error) {
+	u := i.mirror + "/v" + v + "/" + file
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtDownload, u)
+	}
+	rsp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtDownload, u)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		_ = rsp.Body.Close()
+		return nil, errors.Errorf(errFmtStatus, u, rsp.Status)
+	}
+	return rsp.Body, nil
+}
+
+// unzip the tofu binary from the supplied archive to the supplied path. The
+// binary is written to a temporary file and renamed, so that a partially
+// written binary is never run.
+func (i *Installer) unzip(r io.ReaderAt, size int64, archive, path string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.Name != Binary || !f.Mode().IsRegular() {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close() //nolint:errcheck // Nothing was written.
+
+		dst, err := i.fs.TempFile(filepath.Dir(path), tmpPrefix)
+		if err != nil {
+			return err
+		}
+		defer i.fs.Remove(dst.Name()) //nolint:errcheck // Fails once the file is renamed.
+		if _, err := io.Copy(dst, src); err != nil {
+			_ = dst.Close()
+			return err
+		}
+		if err := dst.Close(); err != nil {
+			return err
+		}
+		if err := i.fs.Chmod(dst.Name(), 0755); err != nil { //nolint:gosec // The binary must be executable.
+			return err
+		}
+		return i.fs.Rename(dst.Name(), path)
+	}
+	return errors.Errorf(errFmtNoBinary, archive)
+}
@@ -0,0 +1,232 @@
+/*
+SPDX-FileCopyrightText: 2025 Upbound Inc. <https://upbound.io>
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tofuversion
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/afero"
+
+	"github.com/upbound/provider-opentofu/internal/opentofu"
+)
+
+// release returns a zip archive containing the supplied files.
+func release(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	zw := zip.NewWriter(b)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestResolve(t *testing.T) {
+	zipped := release(t, map[string]string{"tofu": "binary", "LICENSE": "license"})
+	sum := sha256.Sum256(zipped)
+	bad := "sha256-of-something-else"
+
+	// mirror serves the 1.9.0 and 1.8.0 releases, but the checksum of the
+	// 1.8.0 release is wrong.
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.9.0/tofu_1.9.0_SHA256SUMS":
+			fmt.Fprintf(w, "%s  tofu_1.9.0_linux_arm64.zip\n%s  tofu_1.9.0_linux_amd64.zip\n", bad, hex.EncodeToString(sum[:]))
+		case "/v1.8.0/tofu_1.8.0_SHA256SUMS":
+			fmt.Fprintf(w, "%s  tofu_1.8.0_linux_amd64.zip\n", bad)
+		case "/v1.9.0/tofu_1.9.0_linux_amd64.zip", "/v1.8.0/tofu_1.8.0_linux_amd64.zip":
+			_, _ = w.Write(zipped)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mirror.Close()
+
+	type want struct {
+		path    string
+		version string
+		binary  string
+		err     error
+	}
+	cases := map[string]struct {
+		reason    string
+		installed map[string]string
+		mirror    string
+		version   string
+		want      want
+	}{
+		"Default": {
+			reason:  "The default binary should be used if no version is requested.",
+			version: "",
+			want:    want{path: "tofu", version: "1.10.0"},
+		},
+		"DefaultVersion": {
+			reason:  "The default binary should be used if its version is requested.",
+			version: "v1.10.0",
+			want:    want{path: "tofu", version: "1.10.0"},
+		},
+		"Installed": {
+			reason:    "An installed version should be used without downloading it.",
+			installed: map[string]string{"/versions/1.8.0/tofu": "installed"},
+			mirror:    mirror.URL,
+			version:   "1.8.0",
+			want:      want{path: "/versions/1.8.0/tofu", version: "1.8.0", binary: "installed"},
+		},
+		"InvalidVersion": {
+			reason:  "Versions that aren't valid should be rejected, rather than used in paths.",
+			version: "../1.8.0",
+			want:    want{err: errors.Errorf(errFmtVersion, "../1.8.0")},
+		},
+		"NotInstalled": {
+			reason:  "A version that isn't installed can't be used if there is no mirror.",
+			version: "1.9.0",
+			want:    want{err: errors.Errorf(errFmtNotInstalled, "1.9.0")},
+		},
+		"Download": {
+			reason:  "A version that isn't installed should be downloaded from the mirror.",
+			mirror:  mirror.URL,
+			version: "1.9.0",
+			want:    want{path: "/versions/1.9.0/tofu", version: "1.9.0", binary: "binary"},
+		},
+		"ChecksumMismatch": {
+			reason:  "A downloaded version should not be installed if its checksum doesn't match.",
+			mirror:  mirror.URL,
+			version: "1.8.0",
+			want:    want{path: "/versions/1.8.0/tofu", version: "1.8.0", err: errors.Errorf(errFmtChecksum, "tofu_1.8.0_linux_amd64.zip", hex.EncodeToString(sum[:]), bad)},
+		},
+		"NotMirrored": {
+			reason:  "A version the mirror doesn't have should not be installed.",
+			mirror:  mirror.URL,
+			version: "1.7.0",
+			want:    want{path: "/versions/1.7.0/tofu", version: "1.7.0", err: errors.Errorf(errFmtStatus, mirror.URL+"/v1.7.0/tofu_1.7.0_SHA256SUMS", "404 Not Found")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			for path, content := range tc.installed {
+				if err := fs.WriteFile(path, []byte(content), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			i := New("/versions",
+				WithFs(fs),
+				WithHTTPClient(mirror.Client()),
+				WithMirror(tc.mirror),
+				WithDefault("tofu", "v1.10.0"),
+				WithPlatform("linux", "amd64"),
+			)
+
+			path, version, err := i.Resolve(context.Background(), tc.version)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ni.Resolve(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				// Nothing should be installed if resolution fails.
+				if ok, _ := fs.Exists(tc.want.path); ok && tc.want.path != "" {
+					t.Errorf("\n%s\ni.Resolve(...): %s should not be installed", tc.reason, tc.want.path)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.path, path); diff != "" {
+				t.Errorf("\n%s\ni.Resolve(...): -want path, +got path:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.version, version); diff != "" {
+				t.Errorf("\n%s\ni.Resolve(...): -want version, +got version:\n%s", tc.reason, diff)
+			}
+			if tc.want.binary == "" {
+				return
+			}
+			got, err := fs.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.binary, string(got)); diff != "" {
+				t.Errorf("\n%s\ni.Resolve(...): -want binary, +got binary:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	i := New("/versions")
+	i.Record("1.10.0", "linux_amd64")
+	i.Record("1.9.0", "linux_amd64")
+	i.Record("1.10.0", "linux_amd64")
+
+	want := `
+# HELP opentofu_version_info Versions of tofu that ran workspaces since the provider started. The value is always 1.
+# TYPE opentofu_version_info gauge
+opentofu_version_info{platform="linux_amd64",version="1.10.0"} 1
+opentofu_version_info{platform="linux_amd64",version="1.9.0"} 1
+`
+	if err := testutil.CollectAndCompare(i, strings.NewReader(want)); err != nil {
+		t.Errorf("i.Record(...): %v", err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	i := New("/versions")
+	runs := map[string]int{}
+	run := func(path, v string) func(context.Context) (*opentofu.Version, error) {
+		return func(_ context.Context) (*opentofu.Version, error) {
+			runs[path]++
+			ver, err := opentofu.NewVersion(v, "linux_amd64")
+			if err != nil {
+				return nil, err
+			}
+			ver.Providers = map[string]string{"registry.opentofu.org/hashicorp/null": "3.2.4"}
+			return ver, nil
+		}
+	}
+
+	for _, c := range []struct{ path, version string }{
+		{path: "tofu", version: "1.10.0"},
+		{path: "/versions/1.9.0/tofu", version: "1.9.0"},
+		{path: "tofu", version: "1.10.0"},
+	} {
+		got, err := i.Version(context.Background(), c.path, run(c.path, c.version))
+		if err != nil {
+			t.Fatalf("i.Version(...): %v", err)
+		}
+		if diff := cmp.Diff(c.version, got.Version); diff != "" {
+			t.Errorf("i.Version(...): -want version, +got version:\n%s", diff)
+		}
+		if got.Providers != nil {
+			t.Errorf("i.Version(...): the version should not include the providers selected by a working directory")
+		}
+	}
+
+	// Each binary should only be run once.
+	want := map[string]int{"tofu": 1, "/versions/1.9.0/tofu": 1}
+	if diff := cmp.Diff(want, runs); diff != "" {
+		t.Errorf("i.Version(...): -want runs, +got runs:\n%s", diff)
+	}
+}